@@ -29,6 +29,10 @@ import (
 	hydra "github.com/ory/hydra/sdk/go/hydra/swagger"
 )
 
+// ExitCodeVerification is the exit code of a token which does not pass a check, such as --claims-schema. It is the
+// same as the one of the token commands.
+const ExitCodeVerification = 7
+
 func checkResponse(response *hydra.APIResponse, err error, expectedStatusCode int) {
 	pkg.Must(err, "Command failed because error \"%s\" occurred.\n", err)
 
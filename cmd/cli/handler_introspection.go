@@ -22,13 +22,17 @@ package cli
 
 import (
 	//"context"
-	//"encoding/json"
+	"encoding/json"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/ory/hydra/config"
+	"github.com/ory/hydra/pkg"
 	//"github.com/ory/hydra/oauth2"
 
 	"net/http"
+	"net/url"
 	"strings"
 
 	hydra "github.com/ory/hydra/sdk/go/hydra/swagger"
@@ -46,20 +50,122 @@ func newIntrospectionHandler(c *config.Config) *IntrospectionHandler {
 }
 
 func (h *IntrospectionHandler) IsAuthorized(cmd *cobra.Command, args []string) {
-	if len(args) != 1 {
+	var token string
+	if len(args) == 1 {
+		token = args[0]
+	} else {
 		fmt.Print(cmd.UsageString())
 		return
 	}
 
+	pairs, _ := cmd.Flags().GetStringArray("introspect-param")
+	params, err := pkg.ParseFormParams(pairs)
+	pkg.Must(err, "Invalid value for flag --introspect-param: %s", err)
+
+	var schema *pkg.JSONSchema
+	if path, _ := cmd.Flags().GetString("claims-schema"); path != "" {
+		schema, err = pkg.ReadJSONSchema(path)
+		pkg.Must(err, "Invalid value for flag --claims-schema: %s", err)
+	}
+
 	c := hydra.NewOAuth2ApiWithBasePath(h.Config.GetClusterURLWithoutTailingSlash())
 	c.Configuration.Transport = h.Config.OAuth2Client(cmd).Transport
+	if len(params) > 0 {
+		c.Configuration.Transport = &formParamsTransport{RoundTripper: c.Configuration.Transport, params: params}
+	}
 
 	if term, _ := cmd.Flags().GetBool("fake-tls-termination"); term {
 		c.Configuration.DefaultHeader["X-Forwarded-Proto"] = "https"
 	}
+	if id, _ := cmd.Flags().GetString("request-id"); id != "" {
+		c.Configuration.DefaultHeader["X-Request-ID"] = id
+		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose || cmd.Flags().Changed("request-id") {
+			fmt.Fprintf(os.Stderr, "Request ID: %s\n", id)
+		}
+	}
 
 	scopes, _ := cmd.Flags().GetStringSlice("scopes")
-	result, response, err := c.IntrospectOAuth2Token(args[0], strings.Join(scopes, " "))
+	result, response, err := c.IntrospectOAuth2Token(token, strings.Join(scopes, " "))
 	checkResponse(response, err, http.StatusOK)
 	fmt.Printf("%s\n", formatResponse(result))
+
+	if ok, _ := cmd.Flags().GetBool("decode-timestamps"); ok {
+		var raw map[string]interface{}
+		if err := json.Unmarshal(response.Payload, &raw); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not decode introspection response: %s\n", err)
+			return
+		}
+		fmt.Print(formatTimestamps(raw, time.Now()))
+	}
+
+	if schema != nil {
+		var raw interface{}
+		err := json.Unmarshal(response.Payload, &raw)
+		pkg.Must(err, "Could not decode introspection response: %s", err)
+		if failures := schema.Validate(raw); len(failures) > 0 {
+			fmt.Fprintf(os.Stderr, "The introspection response does not conform to --claims-schema:\n\t%s\n", strings.Join(failures, "\n\t"))
+			os.Exit(ExitCodeVerification)
+		}
+		fmt.Println("The introspection response conforms to --claims-schema.")
+	}
+}
+
+// formParamsTransport adds params to the body of form encoded requests, the generated SDK only sends the
+// fields defined by the API specification.
+type formParamsTransport struct {
+	http.RoundTripper
+	params url.Values
+}
+
+func (t *formParamsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request, the body is replaced on a copy.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header))
+	for key, values := range req.Header {
+		r.Header[key] = values
+	}
+	if err := pkg.AddFormParams(r, t.params); err != nil {
+		return nil, err
+	}
+	return t.RoundTripper.RoundTrip(r)
+}
+
+// introspectionTimestamps are the introspection response fields holding seconds since the unix epoch.
+var introspectionTimestamps = []string{"exp", "iat", "nbf", "auth_time"}
+
+// formatTimestamps prints the introspectionTimestamps of raw as dates. Fields which are missing or not a number,
+// decoded either as float64 or as json.Number, are skipped.
+func formatTimestamps(raw map[string]interface{}, now time.Time) string {
+	var out string
+	for _, key := range introspectionTimestamps {
+		var value int64
+		switch v := raw[key].(type) {
+		case float64:
+			value = int64(v)
+		case json.Number:
+			i, err := v.Int64()
+			if err != nil {
+				continue
+			}
+			value = i
+		default:
+			continue
+		}
+
+		t := time.Unix(value, 0).UTC()
+		out += fmt.Sprintf("\t%s: %d (%s)\n", key, value, t.Format(time.RFC3339))
+		if key == "exp" {
+			if remaining := t.Sub(now); remaining > 0 {
+				out += fmt.Sprintf("\tremaining lifetime: %s\n", remaining.Truncate(time.Second))
+			} else {
+				out += fmt.Sprintf("\tremaining lifetime: expired %s ago\n", (-remaining).Truncate(time.Second))
+			}
+		}
+	}
+
+	if out == "" {
+		return ""
+	}
+	return "Timestamps:\n" + out
 }
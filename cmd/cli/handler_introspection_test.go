@@ -0,0 +1,86 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/hydra/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormParamsTransport(t *testing.T) {
+	var form url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+	}))
+	defer ts.Close()
+
+	params, err := pkg.ParseFormParams([]string{"tenant=a", "token_type_hint=access_token"})
+	require.NoError(t, err)
+	_, err = pkg.ParseFormParams([]string{"tenant"})
+	assert.Error(t, err)
+
+	client := &http.Client{Transport: &formParamsTransport{RoundTripper: http.DefaultTransport, params: params}}
+	res, err := client.Post(ts.URL, "application/x-www-form-urlencoded", strings.NewReader("token=foo&scope="))
+	require.NoError(t, err)
+	res.Body.Close()
+
+	assert.Equal(t, "foo", form.Get("token"))
+	assert.Equal(t, "a", form.Get("tenant"))
+	assert.Equal(t, "access_token", form.Get("token_type_hint"))
+}
+
+func TestFormatTimestamps(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	for k, tc := range []struct {
+		raw    map[string]interface{}
+		expect string
+	}{
+		{
+			raw:    map[string]interface{}{"exp": float64(1500003600), "iat": float64(1499996400), "nbf": float64(1499996400)},
+			expect: "Timestamps:\n\texp: 1500003600 (2017-07-14T03:40:00Z)\n\tremaining lifetime: 1h0m0s\n\tiat: 1499996400 (2017-07-14T01:40:00Z)\n\tnbf: 1499996400 (2017-07-14T01:40:00Z)\n",
+		},
+		{
+			raw:    map[string]interface{}{"exp": json.Number("1499999990"), "iat": json.Number("1499996400")},
+			expect: "Timestamps:\n\texp: 1499999990 (2017-07-14T02:39:50Z)\n\tremaining lifetime: expired 10s ago\n\tiat: 1499996400 (2017-07-14T01:40:00Z)\n",
+		},
+		{
+			raw:    map[string]interface{}{"iat": float64(1499996400)},
+			expect: "Timestamps:\n\tiat: 1499996400 (2017-07-14T01:40:00Z)\n",
+		},
+		{
+			raw:    map[string]interface{}{"exp": "1500003600", "iat": json.Number("soon"), "nbf": true},
+			expect: "",
+		},
+		{raw: map[string]interface{}{"active": false}, expect: ""},
+	} {
+		assert.Equal(t, tc.expect, formatTimestamps(tc.raw, now), "case %d", k)
+	}
+}
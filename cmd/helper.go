@@ -23,9 +23,36 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sync"
 )
 
+// failOnWarning is set by --fail-on-warning, Execute exits with exitCodeWarning if any warning was emitted.
+var failOnWarning bool
+
+// emitted collects the warnings printed by warn.
+var emitted struct {
+	sync.Mutex
+	warnings []string
+}
+
 func fatal(message string, args ...interface{}) {
 	fmt.Printf(message+"\n", args...)
 	os.Exit(1)
 }
+
+// warn prints a warning to stderr. Warnings are heuristics and never abort the command, but they make it fail
+// once it finished if --fail-on-warning is set.
+func warn(message string, args ...interface{}) {
+	message = fmt.Sprintf(message, args...)
+	emitted.Lock()
+	emitted.warnings = append(emitted.warnings, message)
+	emitted.Unlock()
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+}
+
+// emittedWarnings returns the warnings printed by warn so far.
+func emittedWarnings() []string {
+	emitted.Lock()
+	defer emitted.Unlock()
+	return append([]string{}, emitted.warnings...)
+}
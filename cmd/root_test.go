@@ -71,6 +71,8 @@ func TestExecute(t *testing.T) {
 		{args: []string{"keys", "delete", "foo"}},
 		{args: []string{"token", "revoke", "foo"}},
 		{args: []string{"token", "client"}},
+		{args: []string{"token", "gen-key", "--alg", "ES256"}},
+		{args: []string{"token", "decode", "--token", "eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28iLCJleHAiOjE1MDAwMDAwMDB9.sig"}},
 		{args: []string{"policies", "create", "-i", "foobar", "-s", "peter,max", "-r", "blog,users", "-a", "post,ban", "--allow"}},
 		{args: []string{"policies", "actions", "add", "foobar", "update|create"}},
 		{args: []string{"policies", "actions", "remove", "foobar", "update|create"}},
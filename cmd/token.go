@@ -21,6 +21,9 @@
 package cmd
 
 import (
+	"time"
+
+	"github.com/pborman/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -28,10 +31,34 @@ import (
 var tokenCmd = &cobra.Command{
 	Use:   "token",
 	Short: "Issue and Manage OAuth2 tokens",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		checkTLSFlags()
+		checkProxyURL()
+		if tokenIssuer != "" {
+			// The administrative endpoints, for example introspection and client management, are not part of the
+			// discovery document and are served below the issuer.
+			c.ClusterURL = tokenIssuer
+		}
+		startWatchdog(cmd)
+		tokenReplayStore.path = replayCacheFile()
+		// The generated id does not mark the flag as changed, only an id set by the user is printed by default.
+		if id, _ := cmd.Flags().GetString("request-id"); id == "" {
+			cmd.Flags().Lookup("request-id").Value.Set(uuid.New())
+		}
+	},
 }
 
 func init() {
 	RootCmd.AddCommand(tokenCmd)
 	//tokenCmd.PersistentFlags().Bool("dry", false, "do not execute the command but show the corresponding curl command instead")
 	tokenCmd.PersistentFlags().Bool("fake-tls-termination", false, `fake tls termination by adding "X-Forwarded-Proto: https"" to http headers`)
+	tokenCmd.PersistentFlags().String("request-id", "", `send this value in the "X-Request-ID" header to correlate requests with the server logs, defaults to a random uuid`)
+	tokenCmd.PersistentFlags().StringVar(&tokenIssuer, "issuer", "", "resolve the authorization, token, JSON Web Key Set, userinfo, device authorization and end session endpoints from the discovery document of this issuer and use it instead of the cluster url, flags which force an endpoint still win")
+	tokenCmd.PersistentFlags().StringVar(&discoveryCachePath, "cache-discovery", "", "cache discovery documents in this file, keyed by issuer, to speed up repeated runs")
+	tokenCmd.PersistentFlags().DurationVar(&discoveryCacheTTL, "cache-discovery-ttl", time.Hour, "use discovery documents cached by --cache-discovery for this long")
+	tokenCmd.PersistentFlags().BoolVar(&refreshDiscovery, "refresh-discovery", false, "fetch the discovery document even if --cache-discovery contains it and update the cache")
+	tokenCmd.PersistentFlags().StringVar(&replayCachePath, "replay-cache", "", "remember the jti and nonce of verified tokens in this file to warn about tokens replayed across runs, defaults to replay.json in the hydra cache directory")
+	tokenCmd.PersistentFlags().Int("max-redirects", 3, "fail if a request to the cluster is redirected more often than this, the token endpoint should never redirect")
+	tokenCmd.PersistentFlags().StringVar(&harOut, "har-out", "", "record the requests to the token, introspection, discovery and jwks endpoints in this HTTP Archive (HAR) file, secrets are redacted")
+	tokenCmd.PersistentFlags().Bool("verbose", false, "dump the raw HTTP requests and responses to stderr, credentials in requests are masked")
 }
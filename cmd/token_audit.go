@@ -0,0 +1,100 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// auditEntry is the JSON line appended by --audit-log for every invocation. It must never contain tokens.
+type auditEntry struct {
+	Timestamp       time.Time `json:"timestamp"`
+	Command         string    `json:"command"`
+	ClientID        string    `json:"client_id"`
+	RequestedScopes []string  `json:"requested_scopes"`
+	GrantedScopes   []string  `json:"granted_scopes,omitempty"`
+	Subject         string    `json:"subject,omitempty"`
+	Success         bool      `json:"success"`
+	ExitCode        int       `json:"exit_code"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// newAuditEntry records the outcome of a flow, token is nil if no token was issued.
+func newAuditEntry(command, clientID string, requested []string, token *oauth2.Token, err error) *auditEntry {
+	entry := &auditEntry{
+		Timestamp:       time.Now().UTC(),
+		Command:         command,
+		ClientID:        clientID,
+		RequestedScopes: requested,
+		Success:         err == nil,
+	}
+
+	if err != nil {
+		entry.ExitCode, entry.Error = 1, err.Error()
+		if e, ok := errors.Cause(err).(*exitError); ok {
+			entry.ExitCode = e.code
+		}
+	}
+
+	if token == nil {
+		return entry
+	}
+	if scope, ok := token.Extra("scope").(string); ok {
+		entry.GrantedScopes = strings.Fields(scope)
+	}
+	if idt, ok := token.Extra("id_token").(string); ok {
+		if _, claims, err := decodeJWT(idt); err == nil {
+			entry.Subject, _ = claims["sub"].(string)
+		}
+	}
+	return entry
+}
+
+// appendAuditEntry appends the entry as a single line to path. The file is locked while writing so that
+// concurrent invocations sharing the audit log never interleave their lines.
+func appendAuditEntry(path string, entry *auditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "could not open audit log %s", path)
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return errors.Wrapf(err, "could not lock audit log %s", path)
+	}
+	defer unlockFile(f)
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return errors.Wrapf(err, "could not write audit log %s", path)
+	}
+	return nil
+}
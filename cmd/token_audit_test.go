@@ -0,0 +1,89 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestAppendAuditEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hydra-audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	// {"alg":"none"}.{"sub":"foo"}
+	token := (&oauth2.Token{AccessToken: "access-token", RefreshToken: "refresh-token"}).WithExtra(map[string]interface{}{
+		"id_token": "eyJhbGciOiJub25lIn0.eyJzdWIiOiJmb28ifQ.sig",
+		"scope":    "openid offline",
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 1 {
+				err = newExitError(exitCodeExchange, errors.New("exchange failed"))
+			}
+			assert.NoError(t, appendAuditEntry(path, newAuditEntry("user", "client", []string{"openid", "offline", "hydra"}, token, err)))
+		}(i)
+	}
+	wg.Wait()
+
+	raw, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "access-token")
+	assert.NotContains(t, string(raw), "refresh-token")
+	assert.NotContains(t, string(raw), "eyJ")
+
+	failed := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(raw)))
+	lines := 0
+	for scanner.Scan() {
+		lines++
+		var entry auditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		assert.Equal(t, "client", entry.ClientID)
+		assert.Equal(t, "foo", entry.Subject)
+		assert.Equal(t, []string{"openid", "offline"}, entry.GrantedScopes)
+		if !entry.Success {
+			failed++
+			assert.Equal(t, exitCodeExchange, entry.ExitCode)
+			assert.Equal(t, "exchange failed", entry.Error)
+		}
+	}
+	assert.Equal(t, 10, lines)
+	assert.Equal(t, 5, failed)
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// bulkClient is an entry of the file read by `hydra token client --clients-file`.
+type bulkClient struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+}
+
+// bulkResult is the outcome of the client credentials flow of a single bulkClient.
+type bulkResult struct {
+	ClientID string
+	Scope    string
+	Duration time.Duration
+	Err      error
+}
+
+// readBulkClients reads a JSON array of clients. Entries without scopes use defaultScopes.
+func readBulkClients(path string, defaultScopes []string) ([]bulkClient, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not read clients file %s", path)
+	}
+
+	var clients []bulkClient
+	if err := json.Unmarshal(raw, &clients); err != nil {
+		return nil, errors.Wrapf(err, "Could not parse clients file %s, expected a JSON array of objects with client_id, client_secret and scopes", path)
+	}
+
+	for k, client := range clients {
+		if client.ClientID == "" {
+			return nil, errors.Errorf("Entry %d of clients file %s has no client_id", k, path)
+		}
+		if client.Scopes == nil {
+			clients[k].Scopes = defaultScopes
+		}
+	}
+	return clients, nil
+}
+
+// acquireBulk runs the client credentials flow for all clients with at most concurrency flows at a time.
+// The results are in the order of clients.
+func acquireBulk(ctx context.Context, tokenURL string, clients []bulkClient, concurrency int) []bulkResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]bulkResult, len(clients))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for k, client := range clients {
+		wg.Add(1)
+		go func(k int, client bulkClient) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			oauthConfig := clientcredentials.Config{
+				ClientID:     client.ClientID,
+				ClientSecret: client.ClientSecret,
+				TokenURL:     tokenURL,
+				Scopes:       client.Scopes,
+			}
+
+			started := time.Now()
+			t, err := oauthConfig.Token(ctx)
+			results[k] = bulkResult{ClientID: client.ClientID, Duration: time.Since(started), Err: err}
+			if err == nil {
+				results[k].Scope, _ = t.Extra("scope").(string)
+			}
+		}(k, client)
+	}
+	wg.Wait()
+	return results
+}
+
+// printBulkSummary prints a table with one row per client and returns the number of failed clients.
+func printBulkSummary(w io.Writer, results []bulkResult) int {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLIENT ID\tRESULT\tDURATION\tDETAILS")
+	for _, result := range results {
+		status, details := "ok", result.Scope
+		if result.Err != nil {
+			failed++
+			status, details = "failed", strings.Replace(describeTokenError(result.Err), "\n", " ", -1)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.ClientID, status, result.Duration.Round(time.Millisecond), details)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d of %d clients acquired a token.\n", len(results)-failed, len(results))
+	return failed
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBulkClients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hydra-bulk")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "clients.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[{"client_id":"a","client_secret":"s","scopes":["foo"]},{"client_id":"b"}]`), 0600))
+
+	clients, err := readBulkClients(path, []string{"hydra"})
+	require.NoError(t, err)
+	assert.Equal(t, []bulkClient{
+		{ClientID: "a", ClientSecret: "s", Scopes: []string{"foo"}},
+		{ClientID: "b", Scopes: []string{"hydra"}},
+	}, clients)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`[{"client_secret":"s"}]`), 0600))
+	_, err = readBulkClients(path, nil)
+	assert.Error(t, err)
+}
+
+func TestAcquireBulk(t *testing.T) {
+	var running, peak int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond * 20)
+
+		id, _, _ := r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		if id == "bad" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token", "token_type": "bearer", "scope": "hydra"})
+	}))
+	defer ts.Close()
+
+	clients := []bulkClient{{ClientID: "a"}, {ClientID: "bad"}, {ClientID: "c"}, {ClientID: "d"}}
+	results := acquireBulk(context.Background(), ts.URL, clients, 2)
+	require.Len(t, results, 4)
+	assert.True(t, atomic.LoadInt32(&peak) <= 2)
+
+	for k, result := range results {
+		assert.Equal(t, clients[k].ClientID, result.ClientID)
+		if result.ClientID == "bad" {
+			assert.Error(t, result.Err)
+		} else {
+			assert.NoError(t, result.Err)
+			assert.Equal(t, "hydra", result.Scope)
+		}
+	}
+
+	var out bytes.Buffer
+	assert.Equal(t, 1, printBulkSummary(&out, results))
+	assert.Contains(t, out.String(), "3 of 4 clients acquired a token.")
+}
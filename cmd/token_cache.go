@@ -0,0 +1,242 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// tokenCacheMinLifetime is how long a cached access token must remain valid to be reused without refreshing it.
+const tokenCacheMinLifetime = time.Minute
+
+// tokenCacheBypassFlags test or shape the authorization code flow itself, assert properties of the issued token, or
+// request a token which the cache key does not describe. Runs using them neither read nor update the token cache.
+var tokenCacheBypassFlags = []string{
+	"print-authorize-only", "code-only", "response-type", "prefer-refresh", "keep-server-open", "prompt", "silent",
+	"id-token-hint", "id-token-hint-file", "max-age", "assert-fresh", "expect-consent", "expect-no-consent", "verify",
+	"verify-via-introspection", "userinfo", "dry-verify", "claims-schema", "bundle-out", "trace", "auth-param",
+	"request-config", "request-object-key", "par", "login-hint", "display", "claims-locales", "create-client",
+	"interactive", "probe-resource", "jwt-access-token-aud-check", "assert-scopes", "expected-subject",
+	"consent-threshold",
+}
+
+// tokenCacheKey identifies a cached token. Its lists are sorted so that the order of the values does not matter.
+type tokenCacheKey struct {
+	TokenURL    string   `json:"token_url"`
+	ClientID    string   `json:"client_id"`
+	Scopes      []string `json:"scopes"`
+	Audiences   []string `json:"audience,omitempty"`
+	Resources   []string `json:"resource,omitempty"`
+	TokenParams []string `json:"token_params,omitempty"`
+}
+
+// newTokenCacheKey returns the key of a token. tokenParams are the key=value pairs of --token-param, which can
+// change the issued token, for example audience=https://api.
+func newTokenCacheKey(tokenURL, clientID string, scopes, audiences, resources, tokenParams []string) tokenCacheKey {
+	key := tokenCacheKey{
+		TokenURL:    tokenURL,
+		ClientID:    clientID,
+		Scopes:      append([]string{}, scopes...),
+		Audiences:   append([]string{}, audiences...),
+		Resources:   append([]string{}, resources...),
+		TokenParams: append([]string{}, tokenParams...),
+	}
+	sort.Strings(key.Scopes)
+	sort.Strings(key.Audiences)
+	sort.Strings(key.Resources)
+	sort.Strings(key.TokenParams)
+	return key
+}
+
+// id is the key of the entry in the cache file.
+func (k tokenCacheKey) id() string {
+	raw, _ := json.Marshal(k)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedToken is an entry of the token cache file.
+type cachedToken struct {
+	Key   tokenCacheKey `json:"key"`
+	Token tokenOutput   `json:"token"`
+}
+
+// tokenCacheEligible tells if a run of `hydra token user` uses the token cache, see tokenCacheBypassFlags.
+func tokenCacheEligible(cmd *cobra.Command) bool {
+	if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache {
+		return false
+	}
+	for _, name := range tokenCacheBypassFlags {
+		if cmd.Flags().Changed(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenCachePath returns the file set by --token-cache, or tokens.json in the hydra directory of the user cache
+// directory.
+func tokenCachePath(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("token-cache"); path != "" {
+		return path, nil
+	}
+	dir := userCacheDir()
+	if dir == "" {
+		return "", errors.New("could not determine the cache directory, use --token-cache")
+	}
+	return filepath.Join(dir, "hydra", "tokens.json"), nil
+}
+
+// userCacheDir returns the directory for user specific cache data: %LocalAppData% on Windows, ~/Library/Caches on
+// macOS and $XDG_CACHE_HOME or ~/.cache elsewhere. It is empty if none of them is set.
+func userCacheDir() string {
+	switch runtime.GOOS {
+	case "windows":
+		return os.Getenv("LocalAppData")
+	case "darwin":
+		if home := userHomeDir(); home != "" {
+			return filepath.Join(home, "Library", "Caches")
+		}
+		return ""
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return dir
+	}
+	if home := userHomeDir(); home != "" {
+		return filepath.Join(home, ".cache")
+	}
+	return ""
+}
+
+// readTokenCache reads the token cache file, a missing file is an empty cache.
+func readTokenCache(path string) (map[string]cachedToken, error) {
+	cache := map[string]cachedToken{}
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "could not read token cache %s", path)
+	}
+
+	if err := json.Unmarshal(raw, &cache); err != nil {
+		return nil, errors.Wrapf(err, "could not parse token cache %s", path)
+	}
+	return cache, nil
+}
+
+// writeTokenCache replaces the token cache file atomically. The file and its directory are only accessible by the
+// current user because the cache contains refresh tokens.
+func writeTokenCache(path string, cache map[string]cachedToken) error {
+	out, err := json.MarshalIndent(cache, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "could not write token cache %s", path)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), path))
+}
+
+// updateTokenCache stores token under key, or removes the entry if token is nil.
+func updateTokenCache(path string, key tokenCacheKey, token *tokenOutput) error {
+	cache, err := readTokenCache(path)
+	if err != nil {
+		// A corrupt cache is replaced instead of failing every run.
+		cache = map[string]cachedToken{}
+	}
+	if token == nil {
+		delete(cache, key.id())
+	} else {
+		cache[key.id()] = cachedToken{Key: key, Token: *token}
+	}
+	return writeTokenCache(path, cache)
+}
+
+// usable tells if the cached access token remains valid for at least tokenCacheMinLifetime. Tokens without a
+// known expiry are never reused as is.
+func (t *cachedToken) usable(now time.Time) bool {
+	return t.Token.AccessToken != "" && !t.Token.Expiry.IsZero() && now.Add(tokenCacheMinLifetime).Before(t.Token.Expiry)
+}
+
+// lookupTokenCache returns the cached token for key. It is refreshed using conf if the access token expires within
+// tokenCacheMinLifetime. Nil is returned if there is no usable token, in which case the browser flow is run.
+func lookupTokenCache(ctx context.Context, w io.Writer, path string, key tokenCacheKey, conf *oauth2.Config) *oauth2.Token {
+	cache, err := readTokenCache(path)
+	if err != nil {
+		warn("Ignoring the token cache: %s", err)
+		return nil
+	}
+	entry, ok := cache[key.id()]
+	if !ok {
+		return nil
+	}
+
+	if entry.usable(time.Now()) {
+		fmt.Fprintf(w, "Using the cached token of %s which expires at %s, use --no-cache to run the browser flow.\n\n", path, entry.Token.Expiry.Local().Format(time.RFC3339))
+		return entry.Token.toOAuth2Token()
+	}
+
+	if entry.Token.RefreshToken != "" {
+		token, err := conf.TokenSource(ctx, &oauth2.Token{RefreshToken: entry.Token.RefreshToken}).Token()
+		if err == nil {
+			if err := updateTokenCache(path, key, newTokenOutput(token)); err != nil {
+				warn("Could not update the token cache: %s", err)
+			}
+			fmt.Fprintf(w, "Refreshed the cached token of %s, use --no-cache to run the browser flow.\n\n", path)
+			return token
+		}
+		fmt.Fprintf(w, "Could not refresh the cached token, running the browser flow: %s\n\n", describeTokenError(err))
+	}
+
+	if err := updateTokenCache(path, key, nil); err != nil {
+		warn("Could not update the token cache: %s", err)
+	}
+	return nil
+}
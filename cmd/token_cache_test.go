@@ -0,0 +1,157 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenCache(t *testing.T) {
+	key := newTokenCacheKey("https://hydra/oauth2/token", "client", []string{"openid", "offline"}, []string{"b", "a"}, nil, nil)
+	assert.Equal(t, key.id(), newTokenCacheKey("https://hydra/oauth2/token", "client", []string{"offline", "openid"}, []string{"a", "b"}, nil, nil).id())
+	assert.NotEqual(t, key.id(), newTokenCacheKey("https://hydra/oauth2/token", "client", []string{"openid"}, []string{"a", "b"}, nil, nil).id())
+	assert.NotEqual(t, key.id(), newTokenCacheKey("https://hydra/oauth2/token", "other", []string{"openid", "offline"}, []string{"a", "b"}, nil, nil).id())
+	assert.NotEqual(t, key.id(), newTokenCacheKey("https://hydra/oauth2/token", "client", []string{"openid", "offline"}, []string{"a", "b"}, []string{"https://api"}, nil).id())
+	assert.NotEqual(t, key.id(), newTokenCacheKey("https://hydra/oauth2/token", "client", []string{"openid", "offline"}, []string{"a", "b"}, nil, []string{"audience=https://api"}).id())
+
+	dir, err := ioutil.TempDir("", "hydra-token-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hydra", "tokens.json")
+
+	cache, err := readTokenCache(path)
+	require.NoError(t, err)
+	assert.Empty(t, cache)
+
+	now := time.Now()
+	require.NoError(t, updateTokenCache(path, key, &tokenOutput{AccessToken: "access-token", Expiry: now.Add(time.Hour)}))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	cache, err = readTokenCache(path)
+	require.NoError(t, err)
+	entry, ok := cache[key.id()]
+	require.True(t, ok)
+	assert.Equal(t, "access-token", entry.Token.AccessToken)
+	assert.True(t, entry.usable(now))
+	assert.False(t, entry.usable(now.Add(time.Hour-tokenCacheMinLifetime)))
+
+	entry.Token.Expiry = time.Time{}
+	assert.False(t, entry.usable(now))
+
+	require.NoError(t, updateTokenCache(path, key, nil))
+	cache, err = readTokenCache(path)
+	require.NoError(t, err)
+	assert.Empty(t, cache)
+}
+
+func TestTokenCacheEligible(t *testing.T) {
+	for _, tc := range []struct {
+		flag     string
+		value    string
+		eligible bool
+	}{
+		{eligible: true},
+		{flag: "scopes", value: "openid", eligible: true},
+		{flag: "no-cache", value: "true"},
+		{flag: "verify", value: "true"},
+		{flag: "assert-scopes", value: "exact"},
+		{flag: "expected-subject", value: "user"},
+		{flag: "consent-threshold", value: "1s"},
+	} {
+		cmd := &cobra.Command{}
+		cmd.Flags().Bool("no-cache", false, "")
+		cmd.Flags().Bool("verify", false, "")
+		cmd.Flags().StringSlice("scopes", []string{}, "")
+		cmd.Flags().String("assert-scopes", "", "")
+		cmd.Flags().String("expected-subject", "", "")
+		cmd.Flags().Duration("consent-threshold", 0, "")
+		if tc.flag != "" {
+			require.NoError(t, cmd.Flags().Set(tc.flag, tc.value))
+		}
+		assert.Equal(t, tc.eligible, tokenCacheEligible(cmd), "--%s", tc.flag)
+	}
+}
+
+func TestLookupTokenCache(t *testing.T) {
+	var refreshed int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("refresh_token") != "valid-refresh-token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		refreshed++
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "refreshed-access-token", "refresh_token": "new-refresh-token", "expires_in": 3600})
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "hydra-token-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens.json")
+
+	conf := &oauth2.Config{ClientID: "client", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: ts.URL}}
+	key := newTokenCacheKey(ts.URL, "client", []string{"openid"}, nil, nil, nil)
+	lookup := func() *oauth2.Token {
+		return lookupTokenCache(context.Background(), ioutil.Discard, path, key, conf)
+	}
+
+	assert.Nil(t, lookup(), "empty cache")
+
+	require.NoError(t, updateTokenCache(path, key, &tokenOutput{AccessToken: "cached-access-token", RefreshToken: "valid-refresh-token", Expiry: time.Now().Add(time.Hour)}))
+	token := lookup()
+	require.NotNil(t, token)
+	assert.Equal(t, "cached-access-token", token.AccessToken)
+	assert.Equal(t, 0, refreshed, "a usable token is not refreshed")
+
+	require.NoError(t, updateTokenCache(path, key, &tokenOutput{AccessToken: "cached-access-token", RefreshToken: "valid-refresh-token", Expiry: time.Now().Add(time.Second)}))
+	token = lookup()
+	require.NotNil(t, token)
+	assert.Equal(t, "refreshed-access-token", token.AccessToken)
+	assert.Equal(t, 1, refreshed)
+	cache, err := readTokenCache(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new-refresh-token", cache[key.id()].Token.RefreshToken, "the refreshed token is stored")
+
+	require.NoError(t, updateTokenCache(path, key, &tokenOutput{AccessToken: "cached-access-token", RefreshToken: "revoked-refresh-token", Expiry: time.Now().Add(time.Second)}))
+	assert.Nil(t, lookup(), "a failed refresh runs the browser flow")
+	cache, err = readTokenCache(path)
+	require.NoError(t, err)
+	assert.Empty(t, cache, "the entry is removed after a failed refresh")
+}
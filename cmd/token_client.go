@@ -22,11 +22,14 @@ package cmd
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
 
 	"github.com/ory/hydra/pkg"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
@@ -35,53 +38,190 @@ import (
 type transporter struct {
 	*http.Transport
 	FakeTLSTermination bool
+	RequestID          string
+	UserAgent          string
+
+	// TokenParams are added to the body of token requests.
+	TokenParams url.Values
+
+	// ClientAssertion replaces the client secret of token requests if set.
+	ClientAssertion *clientAssertion
+
+	// Dump receives the raw requests and responses if set.
+	Dump io.Writer
+
+	// HAR records the requests and responses if set.
+	HAR *harRecorder
 }
 
 func (t *transporter) RoundTrip(req *http.Request) (*http.Response, error) {
 	if t.FakeTLSTermination {
 		req.Header.Set("X-Forwarded-Proto", "https")
 	}
+	if t.RequestID != "" {
+		req.Header.Set("X-Request-ID", t.RequestID)
+	}
+	if t.UserAgent != "" {
+		req.Header.Set("User-Agent", t.UserAgent)
+	}
+	if err := addTokenParams(req, t.TokenParams); err != nil {
+		return nil, err
+	}
+	if t.ClientAssertion != nil {
+		if err := addClientAssertion(req, t.ClientAssertion); err != nil {
+			return nil, err
+		}
+	}
+
+	next := http.RoundTripper(t.Transport)
+	if t.HAR != nil {
+		next = &harTransport{Base: next, Recorder: t.HAR}
+	}
+	if t.Dump == nil {
+		return next.RoundTrip(req)
+	}
+
+	dumpRequest(t.Dump, req)
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		fmt.Fprintf(t.Dump, "Request failed: %s\n\n", err)
+		return nil, err
+	}
+	dumpResponse(t.Dump, res)
+	return res, nil
+}
+
+// newTokenHTTPClient returns the HTTP client used by the token commands to talk to the cluster.
+func newTokenHTTPClient(cmd *cobra.Command) *http.Client {
+	fakeTlsTermination, _ := cmd.Flags().GetBool("fake-tls-termination")
+	requestID, _ := cmd.Flags().GetString("request-id")
+	userAgent, _ := cmd.Flags().GetString("user-agent")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	if verbose || cmd.Flags().Changed("request-id") {
+		fmt.Fprintf(os.Stderr, "Request ID: %s\n", requestID)
+	}
+
+	skipTLSVerify, _ := cmd.Flags().GetBool("skip-tls-verify")
+	t := &transporter{
+		FakeTLSTermination: fakeTlsTermination,
+		RequestID:          requestID,
+		UserAgent:          userAgent,
+		Transport:          newTokenTransport(skipTLSVerify),
+	}
+
+	if verbose {
+		t.Dump = os.Stderr
+		if proxy, _ := pkg.ParseProxyURL(proxyURL); proxy != nil {
+			fmt.Fprintf(os.Stderr, "Proxy: %s\n", pkg.RedactProxyURL(proxy))
+		}
+	}
+	if harOut != "" {
+		t.HAR = tokenHAR
+	}
+
+	// Commands using --token-param validate it themselves, invalid values are ignored here.
+	if pairs, err := cmd.Flags().GetStringArray("token-param"); err == nil {
+		t.TokenParams, _ = pkg.ParseFormParams(pairs)
+	}
+
+	maxRedirects, _ := cmd.Flags().GetInt("max-redirects")
+	return &http.Client{Transport: t, CheckRedirect: limitRedirects(maxRedirects)}
+}
+
+// newTokenTransport returns the transport of the token commands. It uses the TLS flags and sends the requests
+// through --proxy-url, or the proxy of the environment if the flag is not set.
+func newTokenTransport(skipTLSVerify bool) *http.Transport {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	// The TLS flags were validated by checkTLSFlags.
+	transport.TLSClientConfig, _ = pkg.TLSConfig(skipTLSVerify, tlsMinVersion, tlsMaxVersion, tlsCipherSuites)
 
-	return t.Transport.RoundTrip(req)
+	// --proxy-url was validated by checkProxyURL. Its credentials are masked, the transport adds them to the
+	// Proxy-Authorization header after the request was dumped by --verbose.
+	if proxy, _ := pkg.ParseProxyURL(proxyURL); proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return transport
+}
+
+// limitRedirects stops following redirects after max redirects. OAuth 2.0 endpoints do not redirect, so a
+// redirect usually is a misconfiguration, for example a proxy looping between http and https.
+func limitRedirects(max int) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > max {
+			return errors.Errorf("stopped after %d redirects, the last one from %s to %s: the endpoint should not redirect, check the configuration of proxies in front of the cluster or raise --max-redirects", max, via[len(via)-1].URL, req.URL)
+		}
+		return nil
+	}
 }
 
 // tokenClientCmd represents the self command
 var tokenClientCmd = &cobra.Command{
 	Use:   "client",
 	Short: "Generate an OAuth2 token the client grant type",
-	Long:  "This command uses the CLI's credentials to create an access token.",
+	Long: `This command uses the CLI's credentials to create an access token.
+
+When --endpoint-a and --endpoint-b are set, a token is requested from both clusters instead and the claims
+of the two tokens are printed as a claim-by-claim diff. This is useful to validate that an upgraded
+deployment issues the same tokens as the old one. The command exits with 1 if the tokens differ.
+
+When --clients-file is set, a token is requested for every client in the file instead and a summary
+table is printed. The file contains a JSON array of clients, for example:
+
+	[{"client_id": "a", "client_secret": "secret", "scopes": ["hydra"]}, {"client_id": "b", "client_secret": "secret"}]
+
+Clients without scopes use --scopes. At most --concurrency clients request a token at the same time. The
+command exits with 5 if at least one client could not acquire a token.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fakeTlsTermination, _ := cmd.Flags().GetBool("fake-tls-termination")
-		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
-			Transport: &transporter{
-				FakeTLSTermination: fakeTlsTermination,
-				Transport:          &http.Transport{},
-			},
-		})
-
-		if ok, _ := cmd.Flags().GetBool("skip-tls-verify"); ok {
-			// fmt.Println("Warning: Skipping TLS Certificate Verification.")
-			ctx = context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
-				Transport: &transporter{
-					FakeTLSTermination: fakeTlsTermination,
-					Transport: &http.Transport{
-						TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-					},
-				},
-			})
-		}
+		ctx, cancel := commandContext()
+		defer cancel()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, newTokenHTTPClient(cmd))
 
 		scopes, _ := cmd.Flags().GetStringSlice("scopes")
 
+		endpointA, _ := cmd.Flags().GetString("endpoint-a")
+		endpointB, _ := cmd.Flags().GetString("endpoint-b")
+		if endpointA != "" || endpointB != "" {
+			if endpointA == "" || endpointB == "" {
+				fatal("Flags --endpoint-a and --endpoint-b must be used together")
+			}
+			ignored, _ := cmd.Flags().GetStringSlice("ignore-claims")
+			diffClusters(ctx, endpointA, endpointB, scopes, ignored)
+			return
+		}
+
+		endpoints, err := resolveClusterEndpoints(ctx, tokenIssuer, c.ClusterURL)
+		if err != nil {
+			fatal("%s", err)
+		}
+
+		if path, _ := cmd.Flags().GetString("clients-file"); path != "" {
+			clients, err := readBulkClients(path, scopes)
+			if err != nil {
+				fatal("%s", err)
+			}
+			concurrency, _ := cmd.Flags().GetInt("concurrency")
+			results := acquireBulk(ctx, endpoints.Token, clients, concurrency)
+			if failed := printBulkSummary(os.Stdout, results); failed > 0 {
+				os.Exit(exitCodeExchange)
+			}
+			return
+		}
+
 		oauthConfig := clientcredentials.Config{
 			ClientID:     c.ClientID,
 			ClientSecret: c.ClientSecret,
-			TokenURL:     pkg.JoinURLStrings(c.ClusterURL, "/oauth2/token"),
+			TokenURL:     endpoints.Token,
 			Scopes:       scopes,
 		}
 
 		t, err := oauthConfig.Token(ctx)
-		pkg.Must(err, "Could not retrieve access token because: %s", err)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				fmt.Fprintf(os.Stderr, "The command did not finish within --timeout %s\n", commandTimeout)
+				os.Exit(exitCodeTimeout)
+			}
+			fatal("Could not retrieve access token because: %s", describeTokenError(err))
+		}
 		fmt.Printf("%s\n", t.AccessToken)
 	},
 }
@@ -90,4 +230,48 @@ func init() {
 	tokenCmd.AddCommand(tokenClientCmd)
 
 	tokenClientCmd.Flags().StringSlice("scopes", []string{"hydra", "hydra.*"}, "User a specific set of scopes")
+	tokenClientCmd.Flags().String("endpoint-a", "", "Fetch a token from this cluster URL and diff its claims with the one from --endpoint-b")
+	tokenClientCmd.Flags().String("endpoint-b", "", "Fetch a token from this cluster URL and diff its claims with the one from --endpoint-a")
+	tokenClientCmd.Flags().StringSlice("ignore-claims", []string{"jti", "iat", "nbf", "exp"}, "Claims which change on every issuance and are not compared by --endpoint-a and --endpoint-b")
+	tokenClientCmd.Flags().String("clients-file", "", "Request a token for every client in this JSON file and print a summary")
+	tokenClientCmd.Flags().Int("concurrency", 4, "The maximum number of clients from --clients-file requesting a token at the same time")
+}
+
+// diffClusters runs the client credentials flow against two clusters and prints a claim-by-claim diff of
+// the issued access tokens. It exits with a non-zero code if the tokens differ.
+func diffClusters(ctx context.Context, endpointA, endpointB string, scopes, ignored []string) {
+	fetch := func(endpoint string) (map[string]interface{}, bool) {
+		oauthConfig := clientcredentials.Config{
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			TokenURL:     pkg.JoinURLStrings(endpoint, "/oauth2/token"),
+			Scopes:       scopes,
+		}
+
+		t, err := oauthConfig.Token(ctx)
+		if err != nil {
+			fatal("Could not retrieve access token from %s because: %s", endpoint, describeTokenError(err))
+		}
+
+		claims, isJWT := tokenClaims(t)
+		for _, name := range ignored {
+			delete(claims, name)
+		}
+		return claims, isJWT
+	}
+
+	a, jwtA := fetch(endpointA)
+	b, jwtB := fetch(endpointB)
+	if !jwtA || !jwtB {
+		fmt.Println("Note: At least one access token is opaque, comparing the token responses instead of the claims.")
+	}
+
+	fmt.Printf("--- %s\n+++ %s\n", endpointA, endpointB)
+	lines, equal := diffClaims(a, b)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	if !equal {
+		os.Exit(1)
+	}
 }
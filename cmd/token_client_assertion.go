@@ -0,0 +1,116 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+	"github.com/square/go-jose"
+)
+
+// clientAssertionType is the client_assertion_type of private_key_jwt client authentication, see RFC 7523.
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientAssertion authenticates the client at the token endpoint with a JWT signed by its private key instead
+// of the client secret (private_key_jwt).
+type clientAssertion struct {
+	ClientID  string
+	Key       interface{}
+	Algorithm jose.SignatureAlgorithm
+	KeyID     string
+
+	// Lifetime is the difference between the exp and iat claims.
+	Lifetime time.Duration
+
+	// Audience is the aud claim, it defaults to the url of the endpoint the assertion is sent to.
+	Audience string
+}
+
+func newClientAssertion(clientID, keyPath, kid string, lifetime time.Duration, audience string) (*clientAssertion, error) {
+	if lifetime <= 0 {
+		return nil, errors.Errorf("the client assertion lifetime must be positive, got %s", lifetime)
+	}
+	key, alg, err := loadSigningKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return &clientAssertion{ClientID: clientID, Key: key, Algorithm: alg, KeyID: kid, Lifetime: lifetime, Audience: audience}, nil
+}
+
+// sign returns a new assertion for endpoint. Every assertion has its own jti so that servers rejecting replayed
+// assertions accept retries.
+func (a *clientAssertion) sign(endpoint string, now time.Time) (string, error) {
+	audience := a.Audience
+	if audience == "" {
+		audience = endpoint
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"iss": a.ClientID,
+		"sub": a.ClientID,
+		"aud": audience,
+		"iat": now.Unix(),
+		"exp": now.Add(a.Lifetime).Unix(),
+		"jti": uuid.New(),
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: a.Algorithm, Key: &jose.JSONWebKey{Key: a.Key, KeyID: a.KeyID}}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return signed.CompactSerialize()
+}
+
+// addClientAssertion replaces the client secret of a token request by a client assertion.
+func addClientAssertion(req *http.Request, a *clientAssertion) error {
+	endpoint := *req.URL
+	endpoint.RawQuery, endpoint.Fragment = "", ""
+
+	return rewriteTokenForm(req, func(form url.Values) error {
+		return a.authenticate(form, endpoint.String())
+	})
+}
+
+// authenticate replaces the client secret in form by a new assertion for endpoint.
+func (a *clientAssertion) authenticate(form url.Values, endpoint string) error {
+	assertion, err := a.sign(endpoint, time.Now().UTC())
+	if err != nil {
+		return errors.Wrap(err, "could not sign client assertion")
+	}
+	form.Del("client_secret")
+	form.Set("client_id", a.ClientID)
+	form.Set("client_assertion_type", clientAssertionType)
+	form.Set("client_assertion", assertion)
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/hydra/jwk"
+	"github.com/square/go-jose"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddClientAssertion(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	block, err := jwk.PEMBlockForKey(key)
+	require.NoError(t, err)
+
+	f, err := ioutil.TempFile("", "hydra-client-key")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, pem.Encode(f, block))
+	require.NoError(t, f.Close())
+
+	for k, tc := range []struct {
+		audience string
+		expected string
+	}{
+		{expected: "http://127.0.0.1:4444/oauth2/token"},
+		{audience: "http://127.0.0.1:4444/", expected: "http://127.0.0.1:4444/"},
+	} {
+		a, err := newClientAssertion("client", f.Name(), "kid", 30*time.Second, tc.audience)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest("POST", "http://127.0.0.1:4444/oauth2/token?foo=bar", strings.NewReader("grant_type=authorization_code&code=code&client_id=client&client_secret=secret"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		require.NoError(t, addClientAssertion(req, a))
+
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		form, err := url.ParseQuery(string(body))
+		require.NoError(t, err)
+		assert.Empty(t, form.Get("client_secret"), "case %d", k)
+		assert.Equal(t, "client", form.Get("client_id"), "case %d", k)
+		assert.Equal(t, clientAssertionType, form.Get("client_assertion_type"), "case %d", k)
+
+		signed, err := jose.ParseSigned(form.Get("client_assertion"))
+		require.NoError(t, err)
+		assert.Equal(t, "kid", signed.Signatures[0].Header.KeyID, "case %d", k)
+		_, err = signed.Verify(&key.PublicKey)
+		require.NoError(t, err, "case %d", k)
+
+		_, claims, err := decodeJWT(form.Get("client_assertion"))
+		require.NoError(t, err)
+		assert.Equal(t, "client", claims["iss"], "case %d", k)
+		assert.Equal(t, "client", claims["sub"], "case %d", k)
+		assert.Equal(t, tc.expected, claims["aud"], "case %d", k)
+		assert.NotEmpty(t, claims["jti"], "case %d", k)
+		iat, _ := numericClaim(claims, "iat")
+		exp, _ := numericClaim(claims, "exp")
+		assert.EqualValues(t, 30, exp-iat, "case %d", k)
+	}
+
+	_, err = newClientAssertion("client", f.Name(), "", 0, "")
+	assert.Error(t, err)
+}
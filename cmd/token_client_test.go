@@ -0,0 +1,57 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestLimitRedirects(t *testing.T) {
+	redirects := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/loop" {
+			redirects++
+			http.Redirect(w, r, "/loop", http.StatusTemporaryRedirect)
+			return
+		}
+		http.Redirect(w, r, "/token", http.StatusTemporaryRedirect)
+	}))
+	defer ts.Close()
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{CheckRedirect: limitRedirects(3)})
+	_, err := requestToken(ctx, ts.URL+"/loop", "client", "secret", "header", url.Values{"grant_type": {"client_credentials"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "stopped after 3 redirects")
+	assert.Equal(t, 4, redirects)
+
+	client := &http.Client{CheckRedirect: limitRedirects(0)}
+	_, err = client.Get(ts.URL + "/once")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "stopped after 0 redirects")
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// clipboardCommands returns the commands which write their stdin to the clipboard, in the order they are tried.
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip"}}
+	}
+
+	var commands [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append(commands, []string{"wl-copy"})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		commands = append(commands, []string{"xclip", "-selection", "clipboard"}, []string{"xsel", "--clipboard", "--input"})
+	}
+	return commands
+}
+
+// copyToClipboard writes text to the clipboard using the first available command of clipboardCommands.
+func copyToClipboard(text string) error {
+	commands := clipboardCommands()
+	if len(commands) == 0 {
+		return errors.New("no display is available")
+	}
+
+	var tried []string
+	for _, command := range commands {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			tried = append(tried, command[0])
+			continue
+		}
+		c := exec.Command(command[0], command[1:]...)
+		c.Stdin = strings.NewReader(text)
+		if out, err := c.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "%s failed: %s", command[0], strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	return errors.Errorf("none of the clipboard commands %s is installed", strings.Join(tried, ", "))
+}
+
+// copyTokenToClipboard copies the access token, or the ID token with --id-token-only, to the clipboard if
+// --clipboard is set. Failures only cause a warning because the token was printed anyway.
+func copyTokenToClipboard(cmd *cobra.Command, token *oauth2.Token) {
+	if ok, _ := cmd.Flags().GetBool("clipboard"); !ok {
+		return
+	}
+
+	name, value := "access token", token.AccessToken
+	if ok, _ := cmd.Flags().GetBool("id-token-only"); ok {
+		name, value = "ID token", ""
+		if idt, ok := token.Extra("id_token").(string); ok {
+			value = idt
+		}
+	}
+	if value == "" {
+		warn("Could not copy the %s to the clipboard: the token response does not contain it", name)
+		return
+	}
+
+	if err := copyToClipboard(value); err != nil {
+		warn("Could not copy the %s to the clipboard: %s", name, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "The %s was copied to the clipboard.\n", name)
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// checkConsentExpectation implements --expect-consent and --expect-no-consent. The browser is not observable,
+// so a callback arriving within threshold after the authorization url was opened is taken as a sign that the
+// consent screen was skipped. This is a heuristic which works best if the user is already logged in.
+func checkConsentExpectation(elapsed, threshold time.Duration, expectConsent, expectNoConsent bool) error {
+	skipped := elapsed < threshold
+	switch {
+	case expectConsent && skipped:
+		return newExitError(exitCodeConsent, errors.Errorf("Expected the consent screen to be shown, but the callback arrived after %s which indicates that consent was skipped", elapsed.Truncate(time.Millisecond)))
+	case expectNoConsent && !skipped:
+		return newExitError(exitCodeConsent, errors.Errorf("Expected consent to be skipped, but the callback arrived after %s which is longer than --consent-threshold %s", elapsed.Truncate(time.Millisecond), threshold))
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ory/hydra/pkg"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// jwtTimestampClaims are printed as dates by `hydra token decode`.
+var jwtTimestampClaims = []string{"iat", "nbf", "exp", "auth_time"}
+
+// tokenDecodeCmd represents the decode command
+var tokenDecodeCmd = &cobra.Command{
+	Use:   "decode",
+	Short: "Decode a JSON Web Token",
+	Long: `This command decodes a JSON Web Token, for example an ID token or a JWT access token, and prints its header,
+its claims and its timestamps as dates. The token is read from --token or, if not set, from stdin:
+
+  hydra token decode < token.txt
+
+The signature is only verified if --jwks-url or --jwks-file is set, otherwise the output is labeled as UNVERIFIED.
+
+` + exitCodesHelp,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			raw, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return errors.Wrap(err, "Could not read the token from stdin")
+			}
+			token = string(raw)
+		}
+		token = strings.TrimSpace(token)
+		if token == "" {
+			return newExitError(exitCodeConfig, errors.New("No token given, use --token or pipe the token to stdin"))
+		}
+
+		header, claims, err := decodeJWT(token)
+		if err != nil {
+			return newExitError(exitCodeConfig, errors.Wrap(err, "Could not decode the token"))
+		}
+
+		label := " (UNVERIFIED)"
+		var verification *tokenVerification
+		jwksURL, _ := cmd.Flags().GetString("jwks-url")
+		jwksFile, _ := cmd.Flags().GetString("jwks-file")
+		if jwksURL != "" || jwksFile != "" {
+			ctx, cancel := commandContext()
+			defer cancel()
+			ctx = context.WithValue(ctx, oauth2.HTTPClient, newTokenHTTPClient(cmd))
+			jwks := getJWKSClient(jwksURL)
+			if jwksFile != "" {
+				jwks = getJWKSFile(jwksFile)
+			}
+			verification = verifyJWT(ctx, token, jwks)
+			if !verification.failed() {
+				label = ""
+			}
+		}
+
+		for _, part := range []struct {
+			name  string
+			value map[string]interface{}
+		}{{"Header", header}, {"Claims", claims}} {
+			out, err := json.MarshalIndent(part.value, "\t", "\t")
+			pkg.Must(err, "Could not encode %s: %s", part.name, err)
+			fmt.Printf("%s%s:\n\t%s\n\n", part.name, label, out)
+		}
+
+		if timestamps := claimTimestamps(claims, time.Now()); len(timestamps) > 0 {
+			fmt.Printf("Timestamps:\n\t%s\n\n", strings.Join(timestamps, "\n\t"))
+		}
+
+		if verification == nil {
+			fmt.Println("The signature was NOT verified, use --jwks-url or --jwks-file to verify it.")
+			return nil
+		}
+		verification.report(os.Stdout)
+		if verification.failed() {
+			return newExitError(exitCodeVerification, errors.New("The token verification failed"))
+		}
+		return nil
+	},
+}
+
+// verifyJWT verifies the signature and the expiry of a JWT using the JSON Web Key Set of jwks.
+func verifyJWT(ctx context.Context, token string, jwks *jwksClient) *tokenVerification {
+	v := new(tokenVerification)
+
+	if err := checkSigningAlg(token, ""); err != nil {
+		v.check("signed", err)
+		return v
+	}
+
+	keys, err := jwks.keySet(ctx)
+	if err != nil {
+		v.check("fetch JSON Web Keys", err)
+		return v
+	}
+
+	claims, err := jwks.verify(ctx, token, keys)
+	v.check("signature", err)
+	if err != nil {
+		return v
+	}
+
+	warnReplay("token", claims, "")
+	if _, ok := numericClaim(claims, "exp"); ok {
+		v.check("not expired", checkExpiry(claims, time.Now()))
+	}
+	return v
+}
+
+// claimTimestamps formats the NumericDate claims as RFC3339 dates.
+func claimTimestamps(claims map[string]interface{}, now time.Time) []string {
+	var lines []string
+	for _, name := range jwtTimestampClaims {
+		value, ok := numericClaim(claims, name)
+		if !ok {
+			continue
+		}
+
+		t := time.Unix(value, 0).UTC()
+		line := fmt.Sprintf("%s: %d (%s)", name, value, t.Format(time.RFC3339))
+		if name == "exp" {
+			if remaining := t.Sub(now); remaining > 0 {
+				line += fmt.Sprintf(", expires in %s", remaining.Truncate(time.Second))
+			} else {
+				line += fmt.Sprintf(", expired %s ago", (-remaining).Truncate(time.Second))
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenDecodeCmd)
+
+	tokenDecodeCmd.Flags().String("token", "", "The token to decode, read from stdin if not set")
+	tokenDecodeCmd.Flags().String("jwks-url", "", "Verify the signature using the JSON Web Key Set at this url, for example /.well-known/jwks.json of the cluster")
+	tokenDecodeCmd.Flags().String("jwks-file", "", "Verify the signature using the JSON Web Key Set stored in this file, for verification without network access")
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// deviceAuthorization is the response of the device authorization endpoint as defined in RFC 8628 section 3.2.
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// tokenDeviceCmd represents the device command
+var tokenDeviceCmd = &cobra.Command{
+	Use:   "device",
+	Short: "Generate an OAuth2 token using the device authorization grant (RFC 8628)",
+	Long: `This command requests a user code from the device authorization endpoint and polls the token endpoint
+until the user has approved the request.
+
+If the server returns a "verification_uri_complete", it is opened in the browser so that the user code does
+not have to be typed in.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext()
+		defer cancel()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, newTokenHTTPClient(cmd))
+
+		scopes := requestedScopes(cmd)
+		clientID, _ := cmd.Flags().GetString("id")
+		clientSecret, _ := cmd.Flags().GetString("secret")
+		authStyle, _ := cmd.Flags().GetString("auth-style")
+		deviceURL, _ := cmd.Flags().GetString("device-auth-url")
+		tokenURL, _ := cmd.Flags().GetString("token-url")
+
+		if err := checkFormatFlag(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if err := checkFieldFlag(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if err := checkAuthStyle(authStyle); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if err := checkBrowserCommand(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if fields, _ := cmd.Flags().GetStringArray("field"); len(fields) > 0 {
+			defer stdoutToStderr()()
+		}
+		if clientID == "" {
+			clientID = c.ClientID
+		}
+		if clientSecret == "" {
+			clientSecret = c.ClientSecret
+		}
+		endpoints, err := resolveClusterEndpoints(ctx, tokenIssuer, c.ClusterURL)
+		if err != nil {
+			return newContextExitError(ctx, exitCodeConfig, err)
+		}
+		if deviceURL == "" {
+			deviceURL = endpoints.DeviceAuth
+		}
+		if tokenURL == "" {
+			tokenURL = endpoints.Token
+		}
+
+		token, err := runDeviceFlow(ctx, cmd, deviceURL, tokenURL, clientID, clientSecret, authStyle, scopes)
+		if err != nil {
+			return err
+		}
+
+		return printToken(cmd, token)
+	},
+}
+
+// runDeviceFlow performs the device authorization grant and returns the issued token.
+func runDeviceFlow(ctx context.Context, cmd *cobra.Command, deviceURL, tokenURL, clientID, clientSecret, authStyle string, scopes []string) (*oauth2.Token, error) {
+	var auth deviceAuthorization
+	values := audienceParams(cmd)
+	values.Set("scope", strings.Join(scopes, " "))
+	if err := postForm(ctx, deviceURL, clientID, clientSecret, authStyle, values, &auth); err != nil {
+		return nil, newContextExitError(ctx, exitCodeCallbackError, errors.Wrap(err, "Could not start the device authorization"))
+	}
+
+	info := os.Stderr
+	noOpen, _ := cmd.Flags().GetBool("no-open")
+	if auth.VerificationURIComplete != "" {
+		fmt.Fprintf(info, "To authorize this device, navigate to:\n\n\t%s\n\nand confirm the code %s\n\n", auth.VerificationURIComplete, auth.UserCode)
+		if !noOpen {
+			openBrowser(cmd, auth.VerificationURIComplete)
+		}
+	} else {
+		fmt.Fprintf(info, "To authorize this device, navigate to:\n\n\t%s\n\nand enter the code %s\n\n", auth.VerificationURI, auth.UserCode)
+	}
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	expiresIn := time.Duration(auth.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = 10 * time.Minute
+	}
+	deadline := time.Now().Add(expiresIn)
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, newContextExitError(ctx, exitCodeExchange, errors.Wrap(ctx.Err(), "Stopped waiting for the device authorization"))
+		}
+
+		token, err := requestToken(ctx, tokenURL, clientID, clientSecret, authStyle, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {auth.DeviceCode},
+		})
+		if err == nil {
+			return token, nil
+		}
+
+		if e, ok := err.(*oauth2Error); ok {
+			switch e.Code {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += 5 * time.Second
+				continue
+			case "access_denied", "expired_token":
+				return nil, newExitError(exitCodeCallbackError, errors.Wrap(err, "The device authorization failed"))
+			}
+		}
+		return nil, newContextExitError(ctx, exitCodeExchange, errors.Wrap(err, "Could not exchange the device code for a token"))
+	}
+
+	return nil, newExitError(exitCodeTimeout, errors.New("The device code expired before the authorization was completed"))
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenDeviceCmd)
+	tokenDeviceCmd.Flags().Bool("no-open", false, "Do not open the verification url in the browser automatically")
+	tokenDeviceCmd.Flags().String("browser-command", "", "Open the verification url using this command instead of the default browser, the url is appended as the last argument")
+	tokenDeviceCmd.Flags().StringSlice("scopes", []string{"hydra", "offline", "openid"}, "Force scopes, defaults to default_scopes from the config file if set")
+	tokenDeviceCmd.Flags().StringArray("scope", []string{}, "Request this scope, can be repeated and is merged with --scopes")
+	tokenDeviceCmd.Flags().String("id", "", "Force a client id, defaults to value from config file")
+	tokenDeviceCmd.Flags().String("secret", "", "Force a client secret, defaults to value from config file")
+	tokenDeviceCmd.Flags().String("auth-style", "header", "Set how client credentials are sent to the token endpoint, one of: header (client_secret_basic), body (client_secret_post)")
+	tokenDeviceCmd.Flags().String("device-auth-url", "", "Force the device authorization url, defaults to /oauth2/device/auth of the cluster url value from config file")
+	tokenDeviceCmd.Flags().String("token-url", "", "Force a token url, defaults to /oauth2/token of the cluster url value from config file")
+	tokenDeviceCmd.Flags().String("format", "text", "Set the output format, one of: text, json, env, curl, kubectl. The kubectl format prints an ExecCredential for client-go credential plugins")
+	tokenDeviceCmd.Flags().StringArray("field", []string{}, "Print only this field of the token instead, one per line or as JSON object with --format json, can be repeated. Dotted paths select claims of the ID token or the access token, for example claims.sub or access_token_claims.scp")
+	tokenDeviceCmd.Flags().String("resource-url", "", "The resource url used in the example request printed by --format curl")
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenClaims returns the claims of a JWT access token. Opaque access tokens have no claims which could be
+// compared, so the properties of the token response are returned instead.
+func tokenClaims(token *oauth2.Token) (map[string]interface{}, bool) {
+	if accessTokenFormat(token.AccessToken) == accessTokenFormatJWT {
+		if _, claims, err := decodeJWT(token.AccessToken); err == nil {
+			return claims, true
+		}
+	}
+
+	claims := map[string]interface{}{"token_type": token.TokenType}
+	if scope, ok := token.Extra("scope").(string); ok {
+		claims["scope"] = scope
+	}
+	if !token.Expiry.IsZero() {
+		claims["expires_in"] = int64(time.Until(token.Expiry).Round(time.Minute).Seconds())
+	}
+	return claims, false
+}
+
+// diffClaims compares two claim sets claim by claim. Lines start with "=" if the claim is equal, "~" if
+// the values differ, "-" if the claim is only present in a and "+" if it is only present in b.
+func diffClaims(a, b map[string]interface{}) (lines []string, equal bool) {
+	names := map[string]bool{}
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	equal = true
+	for _, name := range sorted {
+		va, inA := a[name]
+		vb, inB := b[name]
+		switch {
+		case !inB:
+			equal = false
+			lines = append(lines, fmt.Sprintf("- %s: %s", name, claimString(va)))
+		case !inA:
+			equal = false
+			lines = append(lines, fmt.Sprintf("+ %s: %s", name, claimString(vb)))
+		case claimString(va) != claimString(vb):
+			equal = false
+			lines = append(lines, fmt.Sprintf("~ %s: %s => %s", name, claimString(va), claimString(vb)))
+		default:
+			lines = append(lines, fmt.Sprintf("= %s: %s", name, claimString(va)))
+		}
+	}
+	return lines, equal
+}
+
+func claimString(v interface{}) string {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(out)
+}
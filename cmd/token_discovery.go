@@ -0,0 +1,239 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ory/hydra/pkg"
+	"github.com/pkg/errors"
+)
+
+var (
+	// discoveryCachePath is the file set by --cache-discovery, discovery documents are not cached if empty.
+	discoveryCachePath string
+
+	// discoveryCacheTTL is how long a cached discovery document is used, set by --cache-discovery-ttl.
+	discoveryCacheTTL time.Duration
+
+	// refreshDiscovery ignores cached documents but still updates the cache, set by --refresh-discovery.
+	refreshDiscovery bool
+
+	// tokenIssuer is set by --issuer, the token commands resolve their endpoints from its discovery document if set.
+	tokenIssuer string
+)
+
+// cachedDiscovery is an entry of the discovery cache file, which maps issuers to their documents.
+type cachedDiscovery struct {
+	FetchedAt time.Time         `json:"fetched_at"`
+	Document  discoveryDocument `json:"document"`
+}
+
+// discoveryDocument contains the fields of the OpenID Connect Discovery document used by the token commands.
+type discoveryDocument struct {
+	Issuer                             string   `json:"issuer"`
+	AuthorizationEndpoint              string   `json:"authorization_endpoint"`
+	TokenEndpoint                      string   `json:"token_endpoint"`
+	JWKsURI                            string   `json:"jwks_uri"`
+	UserinfoEndpoint                   string   `json:"userinfo_endpoint,omitempty"`
+	PushedAuthorizationRequestEndpoint string   `json:"pushed_authorization_request_endpoint,omitempty"`
+	EndSessionEndpoint                 string   `json:"end_session_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint        string   `json:"device_authorization_endpoint,omitempty"`
+	ScopesSupported                    []string `json:"scopes_supported,omitempty"`
+}
+
+// clusterEndpoints are the endpoints of the cluster used by the token commands.
+type clusterEndpoints struct {
+	// Discovery is the discovery document of --issuer, nil if --issuer is not set.
+	Discovery *discoveryDocument
+
+	// Source tells where the endpoints come from, it is printed by --print-client-config.
+	Source string
+
+	Auth       string
+	Token      string
+	JWKs       string
+	Userinfo   string
+	DeviceAuth string
+	EndSession string
+}
+
+// resolveClusterEndpoints returns the endpoints of the cluster. If issuer is set, the endpoints come from its discovery
+// document and optional endpoints which the document does not advertise fall back to their default path below the
+// issuer. Otherwise every endpoint is the default path below clusterURL.
+func resolveClusterEndpoints(ctx context.Context, issuer, clusterURL string) (*clusterEndpoints, error) {
+	if issuer == "" {
+		return defaultClusterEndpoints(clusterURL, "cluster url from config file"), nil
+	}
+
+	d, err := fetchDiscovery(ctx, issuer)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not resolve the endpoints of --issuer")
+	}
+
+	e := defaultClusterEndpoints(issuer, "discovery document of --issuer")
+	e.Discovery = d
+	e.Auth, e.Token, e.JWKs = d.AuthorizationEndpoint, d.TokenEndpoint, d.JWKsURI
+	for _, optional := range []struct {
+		endpoint *string
+		value    string
+	}{
+		{&e.Userinfo, d.UserinfoEndpoint},
+		{&e.DeviceAuth, d.DeviceAuthorizationEndpoint},
+		{&e.EndSession, d.EndSessionEndpoint},
+	} {
+		if optional.value != "" {
+			*optional.endpoint = optional.value
+		}
+	}
+	return e, nil
+}
+
+func defaultClusterEndpoints(base, source string) *clusterEndpoints {
+	return &clusterEndpoints{
+		Source:     source,
+		Auth:       pkg.JoinURLStrings(base, "/oauth2/auth"),
+		Token:      pkg.JoinURLStrings(base, "/oauth2/token"),
+		JWKs:       pkg.JoinURLStrings(base, "/.well-known/jwks.json"),
+		Userinfo:   pkg.JoinURLStrings(base, "/userinfo"),
+		DeviceAuth: pkg.JoinURLStrings(base, "/oauth2/device/auth"),
+		EndSession: pkg.JoinURLStrings(base, "/oauth2/sessions/logout"),
+	}
+}
+
+// fetchDiscovery fetches the OpenID Connect Discovery document of issuer and validates it. If --cache-discovery
+// is set, a cached document younger than --cache-discovery-ttl is returned instead and fetched documents are
+// added to the cache.
+func fetchDiscovery(ctx context.Context, issuer string) (*discoveryDocument, error) {
+	if discoveryCachePath == "" {
+		return fetchDiscoveryDocument(ctx, issuer)
+	}
+
+	cache, err := readDiscoveryCache(discoveryCachePath)
+	if err != nil {
+		warn("Ignoring the discovery cache: %s", err)
+		cache = map[string]cachedDiscovery{}
+	}
+
+	key := strings.TrimSuffix(issuer, "/")
+	if cached, ok := cache[key]; ok && !refreshDiscovery && time.Since(cached.FetchedAt) < discoveryCacheTTL {
+		if err := cached.Document.validate(issuer); err == nil {
+			return &cached.Document, nil
+		}
+	}
+
+	d, err := fetchDiscoveryDocument(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	cache[key] = cachedDiscovery{FetchedAt: time.Now().UTC(), Document: *d}
+	if err := writeDiscoveryCache(discoveryCachePath, cache); err != nil {
+		warn("Could not update the discovery cache: %s", err)
+	}
+	return d, nil
+}
+
+// readDiscoveryCache reads the discovery cache file, a missing file is an empty cache.
+func readDiscoveryCache(path string) (map[string]cachedDiscovery, error) {
+	cache := map[string]cachedDiscovery{}
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "could not read discovery cache %s", path)
+	}
+
+	if err := json.Unmarshal(raw, &cache); err != nil {
+		return nil, errors.Wrapf(err, "could not parse discovery cache %s", path)
+	}
+	return cache, nil
+}
+
+// writeDiscoveryCache replaces the discovery cache file atomically so that concurrent runs never read a
+// partially written file.
+func writeDiscoveryCache(path string, cache map[string]cachedDiscovery) error {
+	out, err := json.MarshalIndent(cache, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "could not write discovery cache %s", path)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), path))
+}
+
+func fetchDiscoveryDocument(ctx context.Context, issuer string) (*discoveryDocument, error) {
+	var d discoveryDocument
+	endpoint := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, endpoint, &d); err != nil {
+		return nil, errors.Wrap(err, "could not fetch the discovery document")
+	}
+	if err := d.validate(issuer); err != nil {
+		return nil, errors.Wrapf(err, "the discovery document at %s is invalid", endpoint)
+	}
+	return &d, nil
+}
+
+// validate checks that the fields required by OpenID Connect Discovery 1.0 section 3 are set and that the
+// issuer is the one the document was fetched from. A mismatch usually means that a reverse proxy rewrites
+// the host or the path, or that Hydra's ISSUER is not set to its public url.
+func (d *discoveryDocument) validate(issuer string) error {
+	var missing []string
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"issuer", d.Issuer},
+		{"authorization_endpoint", d.AuthorizationEndpoint},
+		{"token_endpoint", d.TokenEndpoint},
+		{"jwks_uri", d.JWKsURI},
+	} {
+		if field.value == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("required fields are missing: %s", strings.Join(missing, ", "))
+	}
+
+	if strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return errors.Errorf(`expected issuer "%s" but the document contains "%s"`, issuer, d.Issuer)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoveryDocumentValidate(t *testing.T) {
+	valid := discoveryDocument{
+		Issuer:                "https://hydra/",
+		AuthorizationEndpoint: "https://hydra/oauth2/auth",
+		TokenEndpoint:         "https://hydra/oauth2/token",
+		JWKsURI:               "https://hydra/.well-known/jwks.json",
+	}
+	assert.NoError(t, valid.validate("https://hydra"))
+
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "http://hydra:4444"
+	assert.EqualError(t, wrongIssuer.validate("https://hydra"), `expected issuer "https://hydra" but the document contains "http://hydra:4444"`)
+
+	missing := valid
+	missing.TokenEndpoint = ""
+	missing.JWKsURI = ""
+	assert.EqualError(t, missing.validate("https://hydra"), "required fields are missing: token_endpoint, jwks_uri")
+}
+
+func TestFetchDiscoveryCache(t *testing.T) {
+	fetched := 0
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		json.NewEncoder(w).Encode(&discoveryDocument{
+			Issuer:                ts.URL,
+			AuthorizationEndpoint: ts.URL + "/oauth2/auth",
+			TokenEndpoint:         ts.URL + "/oauth2/token",
+			JWKsURI:               ts.URL + "/.well-known/jwks.json",
+		})
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "hydra-discovery")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(path string, ttl time.Duration, refresh bool) {
+		discoveryCachePath, discoveryCacheTTL, refreshDiscovery = path, ttl, refresh
+	}(discoveryCachePath, discoveryCacheTTL, refreshDiscovery)
+	discoveryCachePath, discoveryCacheTTL, refreshDiscovery = filepath.Join(dir, "discovery.json"), time.Hour, false
+
+	for k, tc := range []struct {
+		refresh bool
+		ttl     time.Duration
+		fetched int
+	}{
+		{ttl: time.Hour, fetched: 1},
+		{ttl: time.Hour, fetched: 1},
+		{ttl: time.Hour, refresh: true, fetched: 2},
+		{ttl: 0, fetched: 3},
+	} {
+		discoveryCacheTTL, refreshDiscovery = tc.ttl, tc.refresh
+		d, err := fetchDiscovery(context.Background(), ts.URL+"/")
+		require.NoError(t, err, "case %d", k)
+		assert.Equal(t, ts.URL+"/oauth2/token", d.TokenEndpoint, "case %d", k)
+		assert.Equal(t, tc.fetched, fetched, "case %d", k)
+	}
+
+	cache, err := readDiscoveryCache(discoveryCachePath)
+	require.NoError(t, err)
+	assert.Contains(t, cache, ts.URL)
+}
+
+func TestResolveClusterEndpoints(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&discoveryDocument{
+			Issuer:                ts.URL,
+			AuthorizationEndpoint: ts.URL + "/authorize",
+			TokenEndpoint:         ts.URL + "/token",
+			JWKsURI:               ts.URL + "/keys",
+			UserinfoEndpoint:      ts.URL + "/me",
+		})
+	}))
+	defer ts.Close()
+
+	e, err := resolveClusterEndpoints(context.Background(), "", "https://hydra/")
+	require.NoError(t, err)
+	assert.Nil(t, e.Discovery)
+	assert.Equal(t, "cluster url from config file", e.Source)
+	assert.Equal(t, "https://hydra/oauth2/auth", e.Auth)
+	assert.Equal(t, "https://hydra/oauth2/token", e.Token)
+	assert.Equal(t, "https://hydra/.well-known/jwks.json", e.JWKs)
+	assert.Equal(t, "https://hydra/userinfo", e.Userinfo)
+	assert.Equal(t, "https://hydra/oauth2/device/auth", e.DeviceAuth)
+	assert.Equal(t, "https://hydra/oauth2/sessions/logout", e.EndSession)
+
+	e, err = resolveClusterEndpoints(context.Background(), ts.URL, "https://hydra/")
+	require.NoError(t, err)
+	require.NotNil(t, e.Discovery)
+	assert.Equal(t, "discovery document of --issuer", e.Source)
+	assert.Equal(t, ts.URL+"/authorize", e.Auth)
+	assert.Equal(t, ts.URL+"/token", e.Token)
+	assert.Equal(t, ts.URL+"/keys", e.JWKs)
+	assert.Equal(t, ts.URL+"/me", e.Userinfo)
+	// Optional endpoints which are not advertised fall back to the issuer, never to the cluster url.
+	assert.Equal(t, ts.URL+"/oauth2/device/auth", e.DeviceAuth)
+	assert.Equal(t, ts.URL+"/oauth2/sessions/logout", e.EndSession)
+
+	_, err = resolveClusterEndpoints(context.Background(), ts.URL+"/other", "https://hydra/")
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redactedParams are the form parameters and JSON fields holding credentials or tokens. They are replaced by
+// [REDACTED] in the requests and responses dumped by --verbose and in the HAR file written by --har-out.
+var redactedParams = map[string]bool{
+	"client_secret": true, "client_assertion": true, "password": true, "assertion": true,
+	"code": true, "code_verifier": true, "device_code": true,
+	"token": true, "refresh_token": true, "access_token": true, "id_token": true,
+	"subject_token": true, "actor_token": true,
+}
+
+// redactedHeaders are the headers holding credentials, they are redacted like redactedParams.
+var redactedHeaders = map[string]bool{"Authorization": true, "Proxy-Authorization": true, "Cookie": true, "Set-Cookie": true}
+
+var (
+	redactedHeader = regexp.MustCompile(`(?im)^((?:` + redactionAlternatives(redactedHeaders) + `):[ \t]*)(\S+ )?[^\r\n]*`)
+	redactedParam  = regexp.MustCompile(`(?i)\b(` + redactionAlternatives(redactedParams) + `)=[^&\s]*`)
+)
+
+// redactionAlternatives returns names as alternatives of a regular expression.
+func redactionAlternatives(names map[string]bool) string {
+	quoted := make([]string, 0, len(names))
+	for name := range names {
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+	sort.Strings(quoted)
+	return strings.Join(quoted, "|")
+}
+
+// redactDump masks credentials in a dumped HTTP request or response so that it can be shared safely. JSON bodies
+// are redacted like in the HAR file, form parameters are masked everywhere.
+func redactDump(dump string) string {
+	head, body := dump, ""
+	if i := strings.Index(dump, "\r\n\r\n"); i >= 0 {
+		head, body = dump[:i+4], dump[i+4:]
+	}
+	head = redactedHeader.ReplaceAllString(head, "${1}${2}[REDACTED]")
+	return redactedParam.ReplaceAllString(head+redactJSON([]byte(body)), "${1}=[REDACTED]")
+}
+
+// dumpRequest writes the raw outgoing request with credentials masked to w.
+func dumpRequest(w io.Writer, req *http.Request) {
+	dump, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		fmt.Fprintf(w, "Could not dump request: %s\n", err)
+		return
+	}
+	fmt.Fprintf(w, "> %s\n\n", strings.Replace(redactDump(strings.TrimSpace(string(dump))), "\n", "\n> ", -1))
+}
+
+// dumpResponse writes the raw response including its body with credentials masked to w. The body is read
+// separately so that chunked responses are dumped decoded and their JSON can be redacted.
+func dumpResponse(w io.Writer, res *http.Response) {
+	head, err := httputil.DumpResponse(res, false)
+	if err != nil {
+		fmt.Fprintf(w, "Could not dump response: %s\n", err)
+		return
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(w, "Could not dump response: %s\n", err)
+		return
+	}
+	dump := redactDump(string(head) + string(body))
+	fmt.Fprintf(w, "< %s\n\n", strings.Replace(strings.TrimSpace(dump), "\n", "\n< ", -1))
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactDump(t *testing.T) {
+	dump := "POST /oauth2/token HTTP/1.1\r\n" +
+		"Authorization: Basic Zm9vOmJhcg==\r\n" +
+		"Content-Type: application/x-www-form-urlencoded\r\n\r\n" +
+		"client_id=foo&client_secret=bar&grant_type=password&username=peter&password=secret"
+
+	assert.Equal(t, "POST /oauth2/token HTTP/1.1\r\n"+
+		"Authorization: Basic [REDACTED]\r\n"+
+		"Content-Type: application/x-www-form-urlencoded\r\n\r\n"+
+		"client_id=foo&client_secret=[REDACTED]&grant_type=password&username=peter&password=[REDACTED]", redactDump(dump))
+
+	assert.Equal(t, "grant_type=authorization_code&code=[REDACTED]&code_verifier=[REDACTED]&redirect_uri=http://127.0.0.1:4445/callback",
+		redactDump("grant_type=authorization_code&code=abc&code_verifier=def&redirect_uri=http://127.0.0.1:4445/callback"))
+	assert.Equal(t, "grant_type=refresh_token&refresh_token=[REDACTED]", redactDump("grant_type=refresh_token&refresh_token=abc"))
+	assert.Equal(t, "grant_type=urn:ietf:params:oauth:grant-type:jwt-bearer&assertion=[REDACTED]", redactDump("grant_type=urn:ietf:params:oauth:grant-type:jwt-bearer&assertion=eyJ"))
+	assert.Equal(t, "device_code=[REDACTED]&client_id=foo", redactDump("device_code=abc&client_id=foo"))
+}
+
+func TestDumpTokenResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret-cookie")
+		// Flushing before the body is written makes the response chunked.
+		w.(http.Flusher).Flush()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "secret-access-token",
+			"refresh_token": "secret-refresh-token",
+			"id_token":      "secret-id-token",
+			"token_type":    "bearer",
+			"expires_in":    3600,
+		})
+	}))
+	defer ts.Close()
+
+	var dump bytes.Buffer
+	client := &http.Client{Transport: &transporter{Transport: &http.Transport{}, Dump: &dump}}
+	res, err := client.PostForm(ts.URL, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"secret-old-refresh-token"}})
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	// The caller still receives the unredacted body.
+	assert.Contains(t, string(body), "secret-access-token")
+
+	out := dump.String()
+	assert.Contains(t, out, "< HTTP/1.1 200 OK")
+	assert.Contains(t, out, `"token_type":"bearer"`)
+	assert.Contains(t, out, `"access_token":"[REDACTED]"`)
+	assert.False(t, strings.Contains(out, "secret-"), "the dump contains a secret:\n%s", out)
+}
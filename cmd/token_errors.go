@@ -0,0 +1,115 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ory/hydra/cmd/cli"
+	"github.com/pkg/errors"
+)
+
+// maxErrorBodyLength is the maximum length of a non-JSON error body summary.
+const maxErrorBodyLength = 200
+
+var (
+	htmlTagPattern    = regexp.MustCompile(`(?s)<(script|style)[^>]*>.*?</(script|style)>|<[^>]+>`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// describeTokenError makes errors returned by the oauth2 library readable. The library includes the raw response
+// body in the error, which is hard to read when a proxy or gateway responds with an HTML page instead of an
+// OAuth 2.0 error.
+func describeTokenError(err error) string {
+	parts := strings.SplitN(err.Error(), "\nResponse: ", 2)
+	if len(parts) != 2 {
+		return err.Error()
+	}
+
+	status := strings.TrimPrefix(parts[0], "oauth2: cannot fetch token: ")
+	body := strings.TrimSpace(parts[1])
+	if json.Valid([]byte(body)) {
+		return fmt.Sprintf("token endpoint responded with status %s: %s", status, body)
+	}
+
+	summary := strings.TrimSpace(whitespacePattern.ReplaceAllString(htmlTagPattern.ReplaceAllString(body, " "), " "))
+	if len(summary) > maxErrorBodyLength {
+		summary = summary[:maxErrorBodyLength] + "..."
+	}
+	if summary == "" {
+		summary = "empty response body"
+	}
+	return fmt.Sprintf("token endpoint responded with status %s and a non-JSON body: %s", status, summary)
+}
+
+// Exit codes of commands which return an exitError.
+const (
+	exitCodeConfig        = 2
+	exitCodeCallbackError = 3
+	exitCodeStateMismatch = 4
+	exitCodeExchange      = 5
+	exitCodeTimeout       = 6
+	exitCodeVerification  = cli.ExitCodeVerification
+	exitCodeScopes        = 8
+	exitCodeConsent       = 9
+	exitCodeProbe         = 10
+	exitCodeWarning       = 11
+)
+
+const exitCodesHelp = `Exit codes:
+  0  The command succeeded
+  2  The configuration or the flags are invalid
+  3  The authorization server redirected to the callback with an error
+  4  The state in the callback did not match the state sent in the authorization request
+  5  The authorization code could not be exchanged for a token
+  6  The command timed out, for example because --timeout was exceeded
+  7  The token verification failed
+  8  The granted scopes did not satisfy --assert-scopes
+  9  The consent screen was not shown or skipped as expected by --expect-consent or --expect-no-consent
+  10 The protected resource did not accept the token requested by --probe-resource
+  11 A warning was emitted and --fail-on-warning is set
+Any other non-zero exit code indicates an unexpected error.`
+
+// exitError makes the CLI exit with a specific code, see Execute.
+type exitError struct {
+	code int
+	err  error
+}
+
+func newExitError(code int, err error) *exitError {
+	return &exitError{code: code, err: err}
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// newContextExitError is like newExitError but uses exitCodeTimeout if the error was caused by --timeout.
+func newContextExitError(ctx context.Context, code int, err error) *exitError {
+	if ctx.Err() == context.DeadlineExceeded {
+		return newExitError(exitCodeTimeout, errors.Wrapf(err, "The command did not finish within --timeout %s", commandTimeout))
+	}
+	return newExitError(code, err)
+}
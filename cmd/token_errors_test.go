@@ -0,0 +1,54 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeTokenError(t *testing.T) {
+	for k, tc := range []struct {
+		err    error
+		expect string
+	}{
+		{
+			err:    errors.New("oauth2: cannot fetch token: 502 Bad Gateway\nResponse: <html><head><title>502 Bad Gateway</title><style>body { color: red; }</style></head><body><h1>Bad   Gateway</h1>\n</body></html>"),
+			expect: "token endpoint responded with status 502 Bad Gateway and a non-JSON body: 502 Bad Gateway Bad Gateway",
+		},
+		{
+			err:    errors.New(`oauth2: cannot fetch token: 401 Unauthorized` + "\nResponse: " + `{"error":"invalid_client"}`),
+			expect: `token endpoint responded with status 401 Unauthorized: {"error":"invalid_client"}`,
+		},
+		{
+			err:    errors.New("oauth2: cannot fetch token: 500 Internal Server Error\nResponse: "),
+			expect: "token endpoint responded with status 500 Internal Server Error and a non-JSON body: empty response body",
+		},
+		{
+			err:    errors.New("dial tcp: connection refused"),
+			expect: "dial tcp: connection refused",
+		},
+	} {
+		assert.Equal(t, tc.expect, describeTokenError(tc.err), "case %d", k)
+	}
+}
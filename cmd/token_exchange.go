@@ -0,0 +1,185 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+const tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// tokenTypes are the token type identifiers of RFC 8693 section 3, they can be passed without the URN prefix.
+var tokenTypes = []string{"access_token", "refresh_token", "id_token", "saml1", "saml2", "jwt"}
+
+// tokenExchangeCmd represents the exchange command
+var tokenExchangeCmd = &cobra.Command{
+	Use:   "exchange",
+	Short: "Exchange a token for another token using OAuth 2.0 Token Exchange (RFC 8693)",
+	Long: `This command sends the --subject-token, and optionally the --actor-token, to the token endpoint using the
+token exchange grant and prints the issued token. Use it to test delegation and impersonation:
+
+	# impersonation, the issued token represents the subject
+	$ hydra token exchange --subject-token <token>
+
+	# delegation, the issued token represents the subject acting through the actor
+	$ hydra token exchange --subject-token <token> --actor-token <token>
+
+Token types can be given as URNs or without their "urn:ietf:params:oauth:token-type:" prefix, one of:
+` + strings.Join(tokenTypes, ", ") + `.
+
+` + exitCodesHelp,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext()
+		defer cancel()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, newTokenHTTPClient(cmd))
+
+		clientID, _ := cmd.Flags().GetString("id")
+		clientSecret, _ := cmd.Flags().GetString("secret")
+		authStyle, _ := cmd.Flags().GetString("auth-style")
+		tokenURL, _ := cmd.Flags().GetString("token-url")
+		format, _ := cmd.Flags().GetString("format")
+
+		if err := checkFormatFlag(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if err := checkFieldFlag(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if err := checkAuthStyle(authStyle); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if fields, _ := cmd.Flags().GetStringArray("field"); len(fields) > 0 {
+			defer stdoutToStderr()()
+		}
+		if clientID == "" {
+			clientID = c.ClientID
+		}
+		if clientSecret == "" {
+			clientSecret = c.ClientSecret
+		}
+		endpoints, err := resolveClusterEndpoints(ctx, tokenIssuer, c.ClusterURL)
+		if err != nil {
+			return newContextExitError(ctx, exitCodeConfig, err)
+		}
+		if tokenURL == "" {
+			tokenURL = endpoints.Token
+		}
+
+		values, err := tokenExchangeValues(cmd)
+		if err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+
+		token, err := requestToken(ctx, tokenURL, clientID, clientSecret, authStyle, values)
+		if err != nil {
+			return newContextExitError(ctx, exitCodeExchange, errors.Wrap(err, "Could not exchange the token"))
+		}
+
+		if err := printToken(cmd, token); err != nil {
+			return err
+		}
+		if issued, ok := token.Extra("issued_token_type").(string); ok {
+			fmt.Fprintf(infoWriter(format), "Issued Token Type:\n\t%s\n\n", issued)
+		}
+		return nil
+	},
+}
+
+// tokenExchangeValues builds the token exchange request of RFC 8693 section 2.1 from the flags of cmd.
+func tokenExchangeValues(cmd *cobra.Command) (url.Values, error) {
+	subjectToken, _ := cmd.Flags().GetString("subject-token")
+	subjectTokenType, _ := cmd.Flags().GetString("subject-token-type")
+	actorToken, _ := cmd.Flags().GetString("actor-token")
+	actorTokenType, _ := cmd.Flags().GetString("actor-token-type")
+	requestedTokenType, _ := cmd.Flags().GetString("requested-token-type")
+	audiences, _ := cmd.Flags().GetStringSlice("audience")
+	resources, _ := cmd.Flags().GetStringSlice("resource")
+	scopes, _ := cmd.Flags().GetStringSlice("scopes")
+
+	if subjectToken == "" {
+		return nil, errors.New("Flag --subject-token is required")
+	}
+	if actorToken == "" && cmd.Flags().Changed("actor-token-type") {
+		return nil, errors.New("Flag --actor-token-type requires --actor-token")
+	}
+
+	values := url.Values{
+		"grant_type":         {tokenExchangeGrantType},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {tokenTypeURN(subjectTokenType)},
+	}
+	if actorToken != "" {
+		values.Set("actor_token", actorToken)
+		values.Set("actor_token_type", tokenTypeURN(actorTokenType))
+	}
+	if requestedTokenType != "" {
+		values.Set("requested_token_type", tokenTypeURN(requestedTokenType))
+	}
+	if len(scopes) > 0 {
+		values.Set("scope", strings.Join(scopes, " "))
+	}
+	for _, audience := range audiences {
+		values.Add("audience", audience)
+	}
+	for _, resource := range resources {
+		values.Add("resource", resource)
+	}
+	return values, nil
+}
+
+// tokenTypeURN expands the short names of tokenTypes to their URN, other values are returned as they are.
+func tokenTypeURN(tokenType string) string {
+	for _, t := range tokenTypes {
+		if tokenType == t {
+			return "urn:ietf:params:oauth:token-type:" + t
+		}
+	}
+	return tokenType
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenExchangeCmd)
+
+	tokenExchangeCmd.Flags().String("subject-token", "", "The token representing the party on whose behalf the new token is requested")
+	tokenExchangeCmd.Flags().String("subject-token-type", "access_token", "The type of --subject-token")
+	tokenExchangeCmd.Flags().String("actor-token", "", "The token representing the acting party, requests delegation instead of impersonation")
+	tokenExchangeCmd.Flags().String("actor-token-type", "access_token", "The type of --actor-token")
+	tokenExchangeCmd.Flags().String("requested-token-type", "", "The type of the requested token, defaults to the choice of the server")
+	tokenExchangeCmd.Flags().StringSlice("audience", []string{}, "The logical names of the services the token is intended for")
+	tokenExchangeCmd.Flags().StringSlice("resource", []string{}, "The urls of the services the token is intended for")
+	tokenExchangeCmd.Flags().StringSlice("scopes", []string{}, "Request these scopes for the new token")
+	tokenExchangeCmd.Flags().String("id", "", "Force a client id, defaults to value from config file")
+	tokenExchangeCmd.Flags().String("secret", "", "Force a client secret, defaults to value from config file")
+	tokenExchangeCmd.Flags().String("auth-style", "header", "Set how client credentials are sent to the token endpoint, one of: header (client_secret_basic), body (client_secret_post)")
+	tokenExchangeCmd.Flags().String("token-url", "", "Force a token url, defaults to /oauth2/token of the cluster url value from config file")
+	tokenExchangeCmd.Flags().String("format", "text", "Set the output format, one of: text, json, env, curl, kubectl. The kubectl format prints an ExecCredential for client-go credential plugins")
+	tokenExchangeCmd.Flags().StringArray("field", []string{}, "Print only this field of the token instead, one per line or as JSON object with --format json, can be repeated. Dotted paths select claims of the ID token or the access token, for example claims.sub or access_token_claims.scp")
+	tokenExchangeCmd.Flags().String("resource-url", "", "The resource url used in the example request printed by --format curl")
+}
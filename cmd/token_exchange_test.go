@@ -0,0 +1,67 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenExchangeValues(t *testing.T) {
+	parse := func(args ...string) (url.Values, error) {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("subject-token", "", "")
+		cmd.Flags().String("subject-token-type", "access_token", "")
+		cmd.Flags().String("actor-token", "", "")
+		cmd.Flags().String("actor-token-type", "access_token", "")
+		cmd.Flags().String("requested-token-type", "", "")
+		cmd.Flags().StringSlice("audience", []string{}, "")
+		cmd.Flags().StringSlice("resource", []string{}, "")
+		cmd.Flags().StringSlice("scopes", []string{}, "")
+		require.NoError(t, cmd.Flags().Parse(args))
+		return tokenExchangeValues(cmd)
+	}
+
+	values, err := parse("--subject-token", "sub", "--requested-token-type", "jwt", "--audience", "a,b", "--scopes", "foo,bar")
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":        {"sub"},
+		"subject_token_type":   {"urn:ietf:params:oauth:token-type:access_token"},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:jwt"},
+		"audience":             {"a", "b"},
+		"scope":                {"foo bar"},
+	}, values)
+
+	values, err = parse("--subject-token", "sub", "--actor-token", "act", "--actor-token-type", "urn:example:custom")
+	require.NoError(t, err)
+	assert.Equal(t, "act", values.Get("actor_token"))
+	assert.Equal(t, "urn:example:custom", values.Get("actor_token_type"))
+
+	_, err = parse()
+	assert.EqualError(t, err, "Flag --subject-token is required")
+	_, err = parse("--subject-token", "sub", "--actor-token-type", "jwt")
+	assert.EqualError(t, err, "Flag --actor-token-type requires --actor-token")
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// tokenFieldClaims maps the roots of dotted --field paths to the token whose decoded claims they select.
+var tokenFieldClaims = map[string]func(*tokenOutput) string{
+	"claims":              func(out *tokenOutput) string { return out.IDToken },
+	"access_token_claims": func(out *tokenOutput) string { return out.AccessToken },
+}
+
+// tokenFieldNames are the fields of the JSON output which can be selected by --field.
+func tokenFieldNames() []string {
+	return []string{"access_token", "access_token_format", "token_type", "refresh_token", "id_token", "scope", "expiry", "session_state"}
+}
+
+// checkTokenFields rejects --field values which can never select anything, so that the mistake is noticed before
+// the flow is run.
+func checkTokenFields(fields []string) error {
+	for _, field := range fields {
+		root := strings.SplitN(field, ".", 2)[0]
+		if _, ok := tokenFieldClaims[root]; ok {
+			continue
+		}
+		known := false
+		for _, name := range tokenFieldNames() {
+			known = known || (name == field)
+		}
+		if !known {
+			return errors.Errorf(`unknown field "%s", expected one of: %s, or a path into the ID token or access token claims like claims.sub or access_token_claims.scp`, field, strings.Join(tokenFieldNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// checkFieldFlag validates --field before the token is requested.
+func checkFieldFlag(cmd *cobra.Command) error {
+	fields, _ := cmd.Flags().GetStringArray("field")
+	if len(fields) == 0 {
+		return nil
+	}
+	if format, _ := cmd.Flags().GetString("format"); format != "text" && format != "json" {
+		return errors.Errorf(`Flag --field prints the fields one per line or, with --format json, as JSON object and can not be used with --format %s`, format)
+	}
+	return errors.Wrap(checkTokenFields(fields), "Invalid value for flag --field")
+}
+
+// tokenField returns the value of field, a name of tokenFieldNames or a dotted path into the claims of the ID
+// token or the access token. False is returned if the token has no such value.
+func tokenField(out *tokenOutput, field string) (interface{}, bool) {
+	parts := strings.Split(field, ".")
+	if claimsOf, ok := tokenFieldClaims[parts[0]]; ok {
+		_, claims, err := decodeJWT(claimsOf(out))
+		if err != nil {
+			return nil, false
+		}
+		var value interface{} = claims
+		for _, part := range parts[1:] {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[part]; !ok {
+				return nil, false
+			}
+		}
+		return value, true
+	}
+
+	if field == "expiry" && out.Expiry.IsZero() {
+		return nil, false
+	}
+	raw, err := json.Marshal(out)
+	if err != nil {
+		return nil, false
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, false
+	}
+	value, ok := fields[field]
+	return value, ok && value != ""
+}
+
+// renderTokenFields prints the values selected by --field one per line, or as a JSON object keyed by field if
+// format is "json". Fields the token does not have are printed as empty lines, or null, so that the lines of the
+// output always match the requested fields.
+func renderTokenFields(w io.Writer, format string, fields []string, token *oauth2.Token, extras *TokenExtras) error {
+	if err := checkTokenFields(fields); err != nil {
+		return err
+	}
+
+	out := newTokenOutputWithExtras(token, extras)
+	values := make(map[string]interface{}, len(fields))
+	lines := make([]string, len(fields))
+	for k, field := range fields {
+		value, ok := tokenField(out, field)
+		if !ok {
+			warn(`The token has no field "%s"`, field)
+			values[field] = nil
+			continue
+		}
+		values[field] = value
+
+		if s, ok := value.(string); ok {
+			lines[k] = s
+		} else if encoded, err := json.Marshal(value); err == nil {
+			lines[k] = string(encoded)
+		} else {
+			return errors.WithStack(err)
+		}
+	}
+
+	if format == "json" {
+		return writeJSON(w, values)
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
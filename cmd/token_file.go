@@ -0,0 +1,140 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// readTokenFile reads a token previously written by writeTokenFile.
+func readTokenFile(path string) (*tokenOutput, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var out tokenOutput
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, errors.Wrapf(err, "could not decode token file %s", path)
+	}
+	return &out, nil
+}
+
+// storedTokenExpiry describes when the stored access token expired according to the local clock. It is empty if
+// the token is still valid or its expiry is unknown.
+func storedTokenExpiry(stored *tokenOutput, now time.Time) string {
+	if stored.Expiry.IsZero() || now.Before(stored.Expiry) {
+		return ""
+	}
+	return fmt.Sprintf("The stored access token expired at %s according to the local clock", stored.Expiry.UTC().Format(time.RFC3339))
+}
+
+// checkStoredTokenExpiry refuses to use a stored access token which expired according to the local clock, unless
+// ignoreExpiry is set to let the server decide, for example to diagnose clock skew.
+func checkStoredTokenExpiry(stored *tokenOutput, ignoreExpiry bool, now time.Time) error {
+	expired := storedTokenExpiry(stored, now)
+	if expired == "" {
+		return nil
+	}
+	if ignoreExpiry {
+		warn("%s, sending it anyway because of --ignore-expiry.", expired)
+		return nil
+	}
+	return errors.Errorf("%s, use --ignore-expiry to send it anyway and let the server decide", expired)
+}
+
+// writeTokenFile stores the token as JSON. The file is only readable by the current user because it contains secrets.
+func writeTokenFile(path string, token *oauth2.Token) error {
+	out, err := json.MarshalIndent(newTokenOutput(token), "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := ioutil.WriteFile(path, out, 0600); err != nil {
+		return errors.Wrapf(err, "could not write token file %s", path)
+	}
+	return nil
+}
+
+// toOAuth2Token converts a stored token back into an *oauth2.Token.
+func (o *tokenOutput) toOAuth2Token() *oauth2.Token {
+	token := &oauth2.Token{
+		AccessToken:  o.AccessToken,
+		TokenType:    o.TokenType,
+		RefreshToken: o.RefreshToken,
+		Expiry:       o.Expiry,
+	}
+
+	extra := map[string]interface{}{}
+	if o.IDToken != "" {
+		extra["id_token"] = o.IDToken
+	}
+	if o.Scope != "" {
+		extra["scope"] = o.Scope
+	}
+	return token.WithExtra(extra)
+}
+
+// tokenBundle is written by --bundle-out. It contains everything needed to audit or reproduce a flow
+// except for the client secret, which is never included.
+type tokenBundle struct {
+	ClientID        string                 `json:"client_id"`
+	RequestedScopes []string               `json:"requested_scopes"`
+	CreatedAt       time.Time              `json:"created_at"`
+	Token           *tokenOutput           `json:"token"`
+	IDTokenClaims   map[string]interface{} `json:"id_token_claims,omitempty"`
+	Discovery       *discoveryDocument     `json:"discovery,omitempty"`
+}
+
+func newTokenBundle(clientID string, scopes []string, token *oauth2.Token, discovery *discoveryDocument) *tokenBundle {
+	b := &tokenBundle{
+		ClientID:        clientID,
+		RequestedScopes: scopes,
+		CreatedAt:       time.Now().UTC(),
+		Token:           newTokenOutput(token),
+		Discovery:       discovery,
+	}
+	if b.Token.IDToken != "" {
+		if _, claims, err := decodeJWT(b.Token.IDToken); err == nil {
+			b.IDTokenClaims = claims
+		}
+	}
+	return b
+}
+
+// writeBundleFile stores the bundle as JSON, it is only readable by the current user because the token is included.
+func writeBundleFile(path string, bundle *tokenBundle) error {
+	out, err := json.MarshalIndent(bundle, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := ioutil.WriteFile(path, out, 0600); err != nil {
+		return errors.Wrapf(err, "could not write bundle file %s", path)
+	}
+	return nil
+}
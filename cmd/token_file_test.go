@@ -0,0 +1,70 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStoredTokenExpiry(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+
+	assert.NoError(t, checkStoredTokenExpiry(&tokenOutput{}, false, now))
+	assert.NoError(t, checkStoredTokenExpiry(&tokenOutput{Expiry: now.Add(time.Minute)}, false, now))
+	assert.Error(t, checkStoredTokenExpiry(&tokenOutput{Expiry: now.Add(-time.Minute)}, false, now))
+	assert.NoError(t, checkStoredTokenExpiry(&tokenOutput{Expiry: now.Add(-time.Minute)}, true, now))
+}
+
+func TestReadStoredAccessToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hydra-token-file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"access_token":"foo","expiry":"2017-07-14T02:40:00Z"}`), 0600))
+
+	warnings := len(emittedWarnings())
+	token, err := readStoredAccessToken(path, false, time.Date(2017, 7, 14, 2, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", token)
+	assert.Len(t, emittedWarnings(), warnings)
+
+	token, err = readStoredAccessToken(path, false, time.Date(2017, 7, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", token)
+	assert.Len(t, emittedWarnings(), warnings+1, "an expired token is introspected with a warning")
+
+	token, err = readStoredAccessToken(path, true, time.Date(2017, 7, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", token)
+	assert.Len(t, emittedWarnings(), warnings+1)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"refresh_token":"bar"}`), 0600))
+	_, err = readStoredAccessToken(path, false, time.Now())
+	assert.Error(t, err)
+}
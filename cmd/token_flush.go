@@ -31,6 +31,8 @@ var tokenFlushCmd = &cobra.Command{
 	Use:   "flush",
 	Short: "Removes inactive access tokens from the database",
 	Run:   cmdHandler.Token.FlushTokens,
+
+	Annotations: map[string]string{watchdogAnnotation: "true"},
 }
 
 func init() {
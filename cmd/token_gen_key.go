@@ -0,0 +1,83 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/pem"
+	"fmt"
+
+	"github.com/ory/hydra/jwk"
+	"github.com/ory/hydra/pkg"
+	"github.com/pborman/uuid"
+	"github.com/spf13/cobra"
+	"github.com/square/go-jose"
+)
+
+// tokenGenKeyCmd represents the gen-key command
+var tokenGenKeyCmd = &cobra.Command{
+	Use:   "gen-key",
+	Short: "Generate a key pair for testing private_key_jwt client authentication",
+	Long: `This command generates a private key and prints it PEM encoded, followed by the public key as a
+JSON Web Key Set which can be registered as the "jwks" of an OAuth 2.0 Client.
+
+The key is generated locally and is never sent to the cluster.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		alg, _ := cmd.Flags().GetString("alg")
+		kid, _ := cmd.Flags().GetString("kid")
+		if kid == "" {
+			kid = uuid.New()
+		}
+
+		var generator jwk.KeyGenerator
+		switch alg {
+		case "RS256":
+			generator = &jwk.RS256Generator{}
+		case "ES256":
+			generator = &jwk.ECDSA256Generator{}
+		default:
+			fatal(`Unknown value "%s" for flag --alg, expected one of: RS256, ES256`, alg)
+		}
+
+		keys, err := generator.Generate(kid)
+		pkg.Must(err, "Could not generate key: %s", err)
+
+		private, err := jwk.FindKeyByPrefix(keys, "private")
+		pkg.Must(err, "Could not find private key: %s", err)
+		public, err := jwk.FindKeyByPrefix(keys, "public")
+		pkg.Must(err, "Could not find public key: %s", err)
+
+		block, err := jwk.PEMBlockForKey(private.Key)
+		pkg.Must(err, "Could not encode private key: %s", err)
+
+		public.KeyID = kid
+		public.Algorithm = alg
+		public.Use = "sig"
+
+		fmt.Printf("%s\n", pem.EncodeToMemory(block))
+		printJSON(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*public}})
+	},
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenGenKeyCmd)
+	tokenGenKeyCmd.Flags().StringP("alg", "a", "RS256", "The algorithm of the key, one of: RS256, ES256")
+	tokenGenKeyCmd.Flags().String("kid", "", "The key id of the public key, defaults to a random uuid")
+}
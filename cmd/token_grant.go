@@ -0,0 +1,145 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// grantTypes are the values of `hydra token user --grant-type`.
+var grantTypes = []string{"authorization_code", "client_credentials", "refresh_token", "device"}
+
+// codeFlowFlags are the flags of `hydra token user` which only apply to the authorization code flow.
+var codeFlowFlags = []string{
+	"redirect", "auth-url", "manual", "code-fifo", "listen-fd", "print-authorize-only", "code-only", "response-type", "trace", "prefer-refresh", "par", "request-object-key", "auth-param",
+	"expect-consent", "expect-no-consent", "verify", "dry-verify", "bundle-out", "claims-locales", "login-hint", "display", "max-age", "assert-fresh", "verify-via-introspection", "userinfo", "userinfo-url", "accept-language",
+	"silent", "id-token-hint", "id-token-hint-file", "skip-preflight", "callback-response", "keep-server-open", "prompt",
+	"claims-schema", "no-cache", "token-cache",
+}
+
+// validateGrantFlags checks that the flags set on cmd can be used with grantType.
+func validateGrantFlags(cmd *cobra.Command, grantType string) error {
+	known := false
+	for _, t := range grantTypes {
+		known = known || t == grantType
+	}
+	if !known {
+		return errors.Errorf(`Unknown value "%s" for flag --grant-type, expected one of: %s`, grantType, strings.Join(grantTypes, ", "))
+	}
+
+	if grantType != "authorization_code" {
+		for _, name := range codeFlowFlags {
+			if cmd.Flags().Changed(name) {
+				return errors.Errorf("Flag --%s can only be used with --grant-type authorization_code", name)
+			}
+		}
+	}
+	if grantType != "refresh_token" && cmd.Flags().Changed("refresh-token") {
+		return errors.New("Flag --refresh-token can only be used with --grant-type refresh_token")
+	}
+	if grantType != "device" && cmd.Flags().Changed("device-auth-url") {
+		return errors.New("Flag --device-auth-url can only be used with --grant-type device")
+	}
+
+	switch grantType {
+	case "client_credentials":
+		if cmd.Flags().Changed("secret-keyring") || cmd.Flags().Changed("secret") || c.ClientSecret != "" {
+			return nil
+		}
+		return errors.New("The client_credentials grant requires a client secret, use --secret, --secret-keyring or the config file")
+	case "refresh_token":
+		refreshToken, _ := cmd.Flags().GetString("refresh-token")
+		out, _ := cmd.Flags().GetString("out")
+		if refreshToken == "" && out == "" {
+			return errors.New("The refresh_token grant requires --refresh-token or a token file written by a previous run with --out")
+		}
+	}
+	return nil
+}
+
+// audienceParams returns the audience and resource parameters requested by --audience and --resource, they are sent
+// with every grant type. Commands without these flags get no parameters.
+func audienceParams(cmd *cobra.Command) url.Values {
+	params := url.Values{}
+	if audiences, _ := cmd.Flags().GetStringSlice("audience"); len(audiences) > 0 {
+		params.Set("audience", strings.Join(audiences, " "))
+	}
+	if resources, _ := cmd.Flags().GetStringSlice("resource"); len(resources) > 0 {
+		params["resource"] = resources
+	}
+	return params
+}
+
+// runGrant obtains a token using one of the grant types of --grant-type other than authorization_code.
+func runGrant(ctx context.Context, cmd *cobra.Command, grantType, tokenURL, clientID, clientSecret, authStyle string, scopes []string) (*oauth2.Token, error) {
+	switch grantType {
+	case "client_credentials":
+		values := audienceParams(cmd)
+		values.Set("grant_type", "client_credentials")
+		values.Set("scope", strings.Join(scopes, " "))
+		token, err := requestToken(ctx, tokenURL, clientID, clientSecret, authStyle, values)
+		if err != nil {
+			return nil, newContextExitError(ctx, exitCodeExchange, errors.Wrap(err, "Could not retrieve access token"))
+		}
+		return token, nil
+	case "refresh_token":
+		stored := &tokenOutput{}
+		if refreshToken, _ := cmd.Flags().GetString("refresh-token"); refreshToken != "" {
+			stored.RefreshToken = refreshToken
+		} else {
+			out, _ := cmd.Flags().GetString("out")
+			var err error
+			if stored, err = readTokenFile(out); err != nil {
+				return nil, newExitError(exitCodeConfig, err)
+			}
+			if stored.RefreshToken == "" {
+				return nil, newExitError(exitCodeConfig, errors.Errorf("The token stored in %s has no refresh token", out))
+			}
+		}
+
+		token, _, err := refreshStoredToken(ctx, tokenURL, clientID, clientSecret, authStyle, stored, audienceParams(cmd))
+		if err != nil {
+			return nil, newContextExitError(ctx, exitCodeExchange, errors.Wrap(err, "Could not refresh the token"))
+		}
+		if idTokenMissingAfterRefresh(token, stored) {
+			format, _ := cmd.Flags().GetString("format")
+			printIDTokenRefreshNote(infoWriter(format), token)
+		}
+		return token, nil
+	case "device":
+		deviceURL, _ := cmd.Flags().GetString("device-auth-url")
+		if deviceURL == "" {
+			endpoints, err := resolveClusterEndpoints(ctx, tokenIssuer, c.ClusterURL)
+			if err != nil {
+				return nil, newContextExitError(ctx, exitCodeConfig, err)
+			}
+			deviceURL = endpoints.DeviceAuth
+		}
+		return runDeviceFlow(ctx, cmd, deviceURL, tokenURL, clientID, clientSecret, authStyle, scopes)
+	}
+	return nil, errors.Errorf("Unsupported grant type %s", grantType)
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateGrantFlags(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("secret", "", "")
+		cmd.Flags().String("secret-keyring", "", "")
+		cmd.Flags().String("out", "", "")
+		cmd.Flags().String("refresh-token", "", "")
+		cmd.Flags().String("device-auth-url", "", "")
+		for _, name := range codeFlowFlags {
+			cmd.Flags().String(name, "", "")
+		}
+		assert.NoError(t, cmd.Flags().Parse(args))
+		return cmd
+	}
+
+	for k, tc := range []struct {
+		grantType string
+		args      []string
+		expectErr string
+	}{
+		{grantType: "authorization_code", args: []string{"--par", "true"}},
+		{grantType: "implicit", expectErr: `Unknown value "implicit" for flag --grant-type, expected one of: authorization_code, client_credentials, refresh_token, device`},
+		{grantType: "client_credentials", args: []string{"--secret", "s"}},
+		{grantType: "client_credentials", args: []string{"--secret", "s", "--manual", "true"}, expectErr: "Flag --manual can only be used with --grant-type authorization_code"},
+		{grantType: "refresh_token", args: []string{"--refresh-token", "r"}},
+		{grantType: "refresh_token", args: []string{"--out", "token.json"}},
+		{grantType: "refresh_token", expectErr: "The refresh_token grant requires --refresh-token or a token file written by a previous run with --out"},
+		{grantType: "device", args: []string{"--refresh-token", "r"}, expectErr: "Flag --refresh-token can only be used with --grant-type refresh_token"},
+		{grantType: "device", args: []string{"--device-auth-url", "http://hydra/device"}},
+	} {
+		err := validateGrantFlags(newCmd(tc.args...), tc.grantType)
+		if tc.expectErr == "" {
+			assert.NoError(t, err, "case %d", k)
+		} else {
+			assert.EqualError(t, err, tc.expectErr, "case %d", k)
+		}
+	}
+}
+
+func TestRunGrantAudience(t *testing.T) {
+	var form url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "access-token", "token_type": "bearer"})
+	}))
+	defer ts.Close()
+
+	for _, grantType := range []string{"client_credentials", "refresh_token"} {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringSlice("audience", []string{}, "")
+		cmd.Flags().StringSlice("resource", []string{}, "")
+		cmd.Flags().String("refresh-token", "", "")
+		cmd.Flags().String("format", "text", "")
+		require.NoError(t, cmd.Flags().Parse([]string{"--audience", "a,b", "--resource", "https://api/1", "--resource", "https://api/2", "--refresh-token", "refresh-token"}))
+
+		_, err := runGrant(context.Background(), cmd, grantType, ts.URL, "client", "secret", "header", []string{"hydra"})
+		require.NoError(t, err, grantType)
+		assert.Equal(t, grantType, form.Get("grant_type"))
+		assert.Equal(t, "a b", form.Get("audience"), grantType)
+		assert.Equal(t, []string{"https://api/1", "https://api/2"}, form["resource"], grantType)
+	}
+
+	assert.Empty(t, audienceParams(&cobra.Command{}), "commands without --audience and --resource")
+}
@@ -0,0 +1,307 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// harOut is set by --har-out, Execute writes the recorded exchanges to it once the command finished.
+var harOut string
+
+// tokenHAR records the back-channel requests of the token commands if --har-out is set.
+var tokenHAR = new(harRecorder)
+
+// The types below are the subset of the HTTP Archive 1.2 format, see http://www.softwareishard.com/blog/har-12-spec/,
+// written by --har-out.
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harRecorder collects HAR entries, it is safe for concurrent use.
+type harRecorder struct {
+	sync.Mutex
+	entries []harEntry
+}
+
+// harTransport records every request sent through Base with recorder.
+type harTransport struct {
+	Base     http.RoundTripper
+	Recorder *harRecorder
+}
+
+func (t *harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	started := time.Now()
+	res, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	waited := time.Since(started)
+
+	content, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	t.Recorder.add(newHAREntry(req, body, res, content, started, waited, time.Since(started)-waited))
+	return res, nil
+}
+
+func (r *harRecorder) add(entry harEntry) {
+	r.Lock()
+	defer r.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+// write stores the recorded entries as HAR file. The file is only readable by the current user because not every
+// secret can be recognized.
+func (r *harRecorder) write(path string) error {
+	r.Lock()
+	entries := append([]harEntry{}, r.entries...)
+	r.Unlock()
+
+	out, err := json.MarshalIndent(&harFile{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "hydra", Version: Version},
+		Entries: entries,
+	}}, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := ioutil.WriteFile(path, out, 0600); err != nil {
+		return errors.Wrapf(err, "could not write HAR file %s", path)
+	}
+	return nil
+}
+
+func newHAREntry(req *http.Request, body []byte, res *http.Response, content []byte, started time.Time, wait, receive time.Duration) harEntry {
+	u := *req.URL
+	query := u.Query()
+	redactValues(query)
+	u.RawQuery = query.Encode()
+
+	entry := harEntry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Time:            milliseconds(wait + receive),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         u.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(req.Header),
+			QueryString: harValues(query),
+			HeadersSize: -1,
+			BodySize:    len(body),
+		},
+		Response: harResponse{
+			Status:      res.StatusCode,
+			StatusText:  http.StatusText(res.StatusCode),
+			HTTPVersion: res.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(res.Header),
+			Content: harContent{
+				Size:     len(content),
+				MimeType: res.Header.Get("Content-Type"),
+				Text:     redactJSON(content),
+			},
+			RedirectURL: res.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(content),
+		},
+		Timings: harTimings{Wait: milliseconds(wait), Receive: milliseconds(receive)},
+	}
+	if entry.Request.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+
+	if len(body) > 0 {
+		mimeType := req.Header.Get("Content-Type")
+		text := string(body)
+		if form, err := url.ParseQuery(text); err == nil && strings.HasPrefix(mimeType, "application/x-www-form-urlencoded") {
+			redactValues(form)
+			text = form.Encode()
+		} else {
+			text = redactJSON(body)
+		}
+		entry.Request.PostData = &harPostData{MimeType: mimeType, Text: text}
+	}
+	return entry
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []harNameValue{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			if redactedHeaders[http.CanonicalHeaderKey(name)] {
+				value = "[REDACTED]"
+			}
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func harValues(values url.Values) []harNameValue {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := []harNameValue{}
+	for _, name := range names {
+		for _, value := range values[name] {
+			pairs = append(pairs, harNameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+func redactValues(values url.Values) {
+	for name := range values {
+		if redactedParams[name] {
+			values[name] = []string{"[REDACTED]"}
+		}
+	}
+}
+
+// redactJSON replaces secrets in a JSON object body, other bodies are returned as they are.
+func redactJSON(body []byte) string {
+	var object map[string]interface{}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return string(body)
+	}
+
+	redacted := false
+	for name := range object {
+		if redactedParams[name] {
+			object[name] = "[REDACTED]"
+			redacted = true
+		}
+	}
+	if !redacted {
+		return string(body)
+	}
+
+	out, err := json.Marshal(object)
+	if err != nil {
+		return string(body)
+	}
+	return string(out)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHARTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"secret-access-token","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer ts.Close()
+
+	recorder := new(harRecorder)
+	client := &http.Client{Transport: &harTransport{Base: http.DefaultTransport, Recorder: recorder}}
+
+	req, err := http.NewRequest("POST", ts.URL+"/oauth2/token?foo=bar", strings.NewReader(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"secret-code"},
+		"client_secret": {"secret-client-secret"},
+	}.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("client", "secret-client-secret")
+
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Contains(t, string(body), "secret-access-token", "the caller must still receive the response body")
+
+	dir, err := ioutil.TempDir("", "hydra-har")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flow.har")
+	require.NoError(t, recorder.write(path))
+
+	raw, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret-")
+
+	var har harFile
+	require.NoError(t, json.Unmarshal(raw, &har))
+	assert.Equal(t, "1.2", har.Log.Version)
+	require.Len(t, har.Log.Entries, 1)
+
+	entry := har.Log.Entries[0]
+	assert.Equal(t, "POST", entry.Request.Method)
+	assert.Equal(t, []harNameValue{{Name: "foo", Value: "bar"}}, entry.Request.QueryString)
+	require.NotNil(t, entry.Request.PostData)
+	form, err := url.ParseQuery(entry.Request.PostData.Text)
+	require.NoError(t, err)
+	assert.Equal(t, "authorization_code", form.Get("grant_type"))
+	assert.Equal(t, "[REDACTED]", form.Get("code"))
+	assert.Contains(t, entry.Request.Headers, harNameValue{Name: "Authorization", Value: "[REDACTED]"})
+	assert.Equal(t, http.StatusOK, entry.Response.Status)
+	assert.Contains(t, entry.Response.Content.Text, `"token_type":"bearer"`)
+	assert.Contains(t, entry.Response.Content.Text, `"access_token":"[REDACTED]"`)
+}
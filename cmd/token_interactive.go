@@ -0,0 +1,149 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	hydra "github.com/ory/hydra/sdk/go/hydra/swagger"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// isInteractive tells if stdin and stdout are terminals, --interactive falls back to the flags otherwise.
+func isInteractive() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd())) && terminal.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// listClients fetches the OAuth 2.0 Clients using the administrative credentials from the config file.
+func listClients(cmd *cobra.Command) ([]hydra.OAuth2Client, error) {
+	clients, response, err := newClientAPI(cmd).ListOAuth2Clients(500, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not list OAuth 2.0 Clients")
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("Could not list OAuth 2.0 Clients, expected status code %d but got %d: %s", http.StatusOK, response.StatusCode, response.Payload)
+	}
+	if len(clients) == 0 {
+		return nil, errors.New("There are no OAuth 2.0 Clients to choose from")
+	}
+	return clients, nil
+}
+
+// selectClient lets the user pick one of clients and toggle the scopes registered for it. The scopes in
+// preselected which are registered for the client are selected initially. At least one scope must be selected,
+// the returned scopes are nil if the client has no registered scopes.
+func selectClient(r *bufio.Reader, w io.Writer, clients []hydra.OAuth2Client, preselected []string) (*hydra.OAuth2Client, []string, error) {
+	fmt.Fprintln(w, "OAuth 2.0 Clients:")
+	for k, client := range clients {
+		details := []string{}
+		if client.ClientName != "" {
+			details = append(details, client.ClientName)
+		}
+		if client.Public {
+			details = append(details, "public")
+		}
+		line := fmt.Sprintf("  %d) %s", k+1, client.Id)
+		if len(details) > 0 {
+			line += " (" + strings.Join(details, ", ") + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	var client *hydra.OAuth2Client
+	for client == nil {
+		fmt.Fprintf(w, "Select a client [1-%d]: ", len(clients))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Could not read the selected client")
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(line)); err == nil && n >= 1 && n <= len(clients) {
+			client = &clients[n-1]
+		}
+	}
+
+	registered := strings.Fields(client.Scope)
+	selected := map[string]bool{}
+	for _, scope := range preselected {
+		selected[scope] = true
+	}
+
+	for len(registered) > 0 {
+		fmt.Fprintf(w, "\nScopes of %s:\n", client.Id)
+		for k, scope := range registered {
+			mark := " "
+			if selected[scope] {
+				mark = "x"
+			}
+			fmt.Fprintf(w, "  [%s] %d) %s\n", mark, k+1, scope)
+		}
+		fmt.Fprint(w, "Toggle scopes by their numbers, or press enter to continue: ")
+
+		line, err := r.ReadString('\n')
+		if err != nil && strings.TrimSpace(line) == "" {
+			return nil, nil, errors.Wrap(err, "Could not read the selected scopes")
+		}
+		if strings.TrimSpace(line) == "" {
+			if len(selectedScopes(registered, selected)) > 0 {
+				break
+			}
+			fmt.Fprintln(w, "Select at least one scope.")
+			continue
+		}
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' }) {
+			if n, err := strconv.Atoi(field); err == nil && n >= 1 && n <= len(registered) {
+				selected[registered[n-1]] = !selected[registered[n-1]]
+			}
+		}
+	}
+
+	fmt.Fprintln(w)
+	return client, selectedScopes(registered, selected), nil
+}
+
+// selectedScopes returns the registered scopes which are selected, in the order they are registered.
+func selectedScopes(registered []string, selected map[string]bool) []string {
+	var scopes []string
+	for _, scope := range registered {
+		if selected[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// readSecret prompts for the client secret without echoing it.
+func readSecret(w io.Writer, clientID string) (string, error) {
+	fmt.Fprintf(w, "Client secret of %s: ", clientID)
+	secret, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(w)
+	if err != nil {
+		return "", errors.Wrap(err, "Could not read the client secret")
+	}
+	return string(secret), nil
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	hydra "github.com/ory/hydra/sdk/go/hydra/swagger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectClient(t *testing.T) {
+	clients := []hydra.OAuth2Client{
+		{Id: "a", ClientName: "App A", Scope: "openid offline"},
+		{Id: "b", Public: true, Scope: "openid offline hydra"},
+	}
+
+	var out bytes.Buffer
+	// An invalid choice is asked again, then "offline" is deselected and "hydra" selected.
+	input := bufio.NewReader(strings.NewReader("3\n2\n2,3\n\n"))
+	client, scopes, err := selectClient(input, &out, clients, []string{"openid", "offline", "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, "b", client.Id)
+	assert.Equal(t, []string{"openid", "hydra"}, scopes)
+	assert.Contains(t, out.String(), "1) a (App A)")
+	assert.Contains(t, out.String(), "2) b (public)")
+	assert.Contains(t, out.String(), "[x] 3) hydra")
+
+	_, _, err = selectClient(bufio.NewReader(strings.NewReader("")), &out, clients, nil)
+	assert.Error(t, err)
+}
+
+func TestSelectClientRequiresScope(t *testing.T) {
+	clients := []hydra.OAuth2Client{
+		{Id: "a", Scope: "openid offline"},
+		{Id: "b"},
+	}
+
+	var out bytes.Buffer
+	// Deselecting every scope is rejected, the prompt is shown again until a scope is selected.
+	input := bufio.NewReader(strings.NewReader("1\n1,2\n\n2\n\n"))
+	_, scopes, err := selectClient(input, &out, clients, []string{"openid", "offline"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"offline"}, scopes)
+	assert.Contains(t, out.String(), "Select at least one scope.")
+
+	_, _, err = selectClient(bufio.NewReader(strings.NewReader("1\n1,2\n\n")), &out, clients, []string{"openid", "offline"})
+	assert.Error(t, err, "the input ended without a selected scope")
+
+	// A client without registered scopes offers nothing to select.
+	client, scopes, err := selectClient(bufio.NewReader(strings.NewReader("2\n")), &out, clients, []string{"openid"})
+	require.NoError(t, err)
+	assert.Equal(t, "b", client.Id)
+	assert.Nil(t, scopes)
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/square/go-jose"
+)
+
+const (
+	// jwksCacheTTL is how long a fetched JSON Web Key Set is used before it is fetched again.
+	jwksCacheTTL = 10 * time.Minute
+
+	// jwksMinRefreshInterval limits how often an unknown kid causes the JSON Web Key Set to be fetched again.
+	jwksMinRefreshInterval = 30 * time.Second
+)
+
+var (
+	jwksClientsLock sync.Mutex
+	jwksClients     = map[string]*jwksClient{}
+)
+
+// unknownKeyIDError is returned by verifyJWTSignature if the key set has no key with the kid of the token.
+type unknownKeyIDError struct {
+	kid string
+}
+
+func (e *unknownKeyIDError) Error() string {
+	return "no JSON Web Key with kid " + e.kid + " was found"
+}
+
+// jwksClient fetches and caches the JSON Web Key Set at url. Tokens signed with a key which is not in the
+// cached set cause the set to be fetched again, at most once per jwksMinRefreshInterval, so that verifications
+// keep working when the cluster rotates its keys. If path is set, the set is read from that file once instead.
+type jwksClient struct {
+	url                string
+	path               string
+	ttl                time.Duration
+	minRefreshInterval time.Duration
+
+	sync.Mutex
+	keys    *jose.JSONWebKeySet
+	fetched time.Time
+}
+
+// getJWKSClient returns the client of url shared by all verifications of this process.
+func getJWKSClient(url string) *jwksClient {
+	jwksClientsLock.Lock()
+	defer jwksClientsLock.Unlock()
+
+	if client, ok := jwksClients[url]; ok {
+		return client
+	}
+	client := &jwksClient{url: url, ttl: jwksCacheTTL, minRefreshInterval: jwksMinRefreshInterval}
+	jwksClients[url] = client
+	return client
+}
+
+// getJWKSFile returns a client verifying tokens with the JSON Web Key Set stored at path, for example a copy of
+// /.well-known/jwks.json of the cluster. It never accesses the network.
+func getJWKSFile(path string) *jwksClient {
+	return &jwksClient{path: path}
+}
+
+// keySet returns the cached key set, it is fetched if it is missing or older than the TTL.
+func (c *jwksClient) keySet(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.keys != nil && (c.path != "" || time.Since(c.fetched) < c.ttl) {
+		return c.keys, nil
+	}
+	return c.fetch(ctx)
+}
+
+// verify verifies the signature of token using keys. If the kid of the token is unknown, the key set is fetched
+// again and the token is verified once more.
+func (c *jwksClient) verify(ctx context.Context, token string, keys *jose.JSONWebKeySet) (map[string]interface{}, error) {
+	claims, err := verifyJWTSignature(token, keys)
+	if _, ok := errors.Cause(err).(*unknownKeyIDError); !ok {
+		return claims, err
+	} else if c.path != "" {
+		return nil, errors.Wrapf(err, "%s does not contain the key", c.path)
+	}
+
+	c.Lock()
+	if c.keys != keys {
+		// The set was refreshed since keys was returned, try the current one.
+		keys = c.keys
+	} else if time.Since(c.fetched) >= c.minRefreshInterval {
+		var ferr error
+		if keys, ferr = c.fetch(ctx); ferr != nil {
+			c.Unlock()
+			return nil, errors.Wrapf(ferr, "%s and the JSON Web Key Set could not be refreshed", err)
+		}
+	} else {
+		c.Unlock()
+		return nil, err
+	}
+	c.Unlock()
+
+	return verifyJWTSignature(token, keys)
+}
+
+// fetch must be called with the lock held.
+func (c *jwksClient) fetch(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	var keys jose.JSONWebKeySet
+	if c.path != "" {
+		raw, err := ioutil.ReadFile(c.path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := json.Unmarshal(raw, &keys); err != nil {
+			return nil, errors.Wrapf(err, "file %s does not contain a JSON Web Key Set", c.path)
+		}
+	} else if err := getJSON(ctx, c.url, &keys); err != nil {
+		return nil, err
+	}
+	c.keys, c.fetched = &keys, time.Now()
+	return c.keys, nil
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/square/go-jose"
+	"golang.org/x/oauth2"
+)
+
+// tokenJWKSCmd represents the jwks command
+var tokenJWKSCmd = &cobra.Command{
+	Use:   "jwks",
+	Short: "Print the public keys the cluster signs tokens with",
+	Long: `This command resolves the jwks_uri from the OpenID Connect Discovery document of the cluster, fetches the
+JSON Web Key Set and prints the key id, algorithm, use and RFC 7638 SHA-256 thumbprint of every key:
+
+	$ hydra token jwks
+	$ hydra token jwks --format json
+
+This helps to configure resource servers which verify the tokens of the cluster and to check which keys are
+active after a rotation.
+
+` + exitCodesHelp,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if format != "text" && format != "json" {
+			return newExitError(exitCodeConfig, errors.Errorf(`Unknown value "%s" for flag --format, expected one of: text, json`, format))
+		}
+
+		ctx, cancel := commandContext()
+		defer cancel()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, newTokenHTTPClient(cmd))
+
+		// The cluster url is the issuer if --issuer is set.
+		discovery, err := fetchDiscovery(ctx, c.ClusterURL)
+		if err != nil {
+			return newContextExitError(ctx, exitCodeConfig, err)
+		}
+
+		var keys jose.JSONWebKeySet
+		if err := getJSON(ctx, discovery.JWKsURI, &keys); err != nil {
+			return newContextExitError(ctx, exitCodeConfig, errors.Wrap(err, "could not fetch the JSON Web Key Set"))
+		}
+
+		summaries := make([]jwkSummary, len(keys.Keys))
+		for k := range keys.Keys {
+			summaries[k] = newJWKSummary(&keys.Keys[k])
+			if summaries[k].Private {
+				warn(`The key "%s" at %s contains private key material, it must not be published`, summaries[k].KeyID, discovery.JWKsURI)
+			}
+		}
+
+		if format == "json" {
+			printJSON(summaries)
+			return nil
+		}
+		fmt.Printf("JSON Web Key Set:\n\t%s\n\n", discovery.JWKsURI)
+		printJWKSummaries(os.Stdout, summaries)
+		return nil
+	},
+}
+
+// jwkSummary describes a key of the JSON Web Key Set printed by `hydra token jwks`.
+type jwkSummary struct {
+	KeyID      string          `json:"kid"`
+	Algorithm  string          `json:"alg,omitempty"`
+	Use        string          `json:"use,omitempty"`
+	KeyType    string          `json:"kty"`
+	Size       int             `json:"size,omitempty"`
+	Thumbprint string          `json:"thumbprint_sha256,omitempty"`
+	Private    bool            `json:"private,omitempty"`
+	Key        jose.JSONWebKey `json:"key"`
+}
+
+// newJWKSummary computes the summary of key. The thumbprint is left empty for key types go-jose can not compute
+// it for, such as symmetric keys.
+func newJWKSummary(key *jose.JSONWebKey) jwkSummary {
+	summary := jwkSummary{
+		KeyID:     key.KeyID,
+		Algorithm: key.Algorithm,
+		Use:       key.Use,
+		Private:   !key.IsPublic(),
+		Key:       *key,
+	}
+
+	switch k := key.Key.(type) {
+	case *rsa.PublicKey:
+		summary.KeyType, summary.Size = "RSA", k.N.BitLen()
+	case *rsa.PrivateKey:
+		summary.KeyType, summary.Size = "RSA", k.N.BitLen()
+	case *ecdsa.PublicKey:
+		summary.KeyType, summary.Size = "EC", k.Curve.Params().BitSize
+	case *ecdsa.PrivateKey:
+		summary.KeyType, summary.Size = "EC", k.Curve.Params().BitSize
+	case []byte:
+		summary.KeyType = "oct"
+	default:
+		summary.KeyType = fmt.Sprintf("%T", k)
+	}
+
+	if thumbprint, err := key.Thumbprint(crypto.SHA256); err == nil {
+		summary.Thumbprint = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+	return summary
+}
+
+func printJWKSummaries(w io.Writer, summaries []jwkSummary) {
+	if len(summaries) == 0 {
+		fmt.Fprintf(w, "The JSON Web Key Set contains no keys.\n")
+		return
+	}
+
+	for _, s := range summaries {
+		fmt.Fprintf(w, "Key %s:\n", s.KeyID)
+		fmt.Fprintf(w, "\tAlgorithm:  %s\n", orNone(s.Algorithm))
+		fmt.Fprintf(w, "\tUse:        %s\n", orNone(s.Use))
+		if s.Size > 0 {
+			fmt.Fprintf(w, "\tType:       %s (%d bits)\n", s.KeyType, s.Size)
+		} else {
+			fmt.Fprintf(w, "\tType:       %s\n", s.KeyType)
+		}
+		fmt.Fprintf(w, "\tThumbprint: %s\n\n", orNone(s.Thumbprint))
+	}
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenJWKSCmd)
+	tokenJWKSCmd.Flags().String("format", "text", "Set the output format, one of: text, json")
+}
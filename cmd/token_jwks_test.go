@@ -0,0 +1,153 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/square/go-jose"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJWK(t *testing.T, kid string) (*rsa.PrivateKey, jose.JSONWebKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	return key, jose.JSONWebKey{Key: &key.PublicKey, KeyID: kid, Algorithm: "RS256", Use: "sig"}
+}
+
+func signTestJWT(t *testing.T, key *rsa.PrivateKey, kid string) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: &jose.JSONWebKey{Key: key, KeyID: kid}}, nil)
+	require.NoError(t, err)
+	signed, err := signer.Sign([]byte(`{"sub":"foo"}`))
+	require.NoError(t, err)
+	token, err := signed.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestJWKSClientRotation(t *testing.T) {
+	newKey := func(kid string) (*rsa.PrivateKey, jose.JSONWebKey) { return newTestJWK(t, kid) }
+	sign := func(key *rsa.PrivateKey, kid string) string { return signTestJWT(t, key, kid) }
+
+	oldKey, oldPublic := newKey("old")
+	newPrivate, newPublic := newKey("new")
+
+	var lock sync.Mutex
+	fetched := 0
+	published := []jose.JSONWebKey{oldPublic}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		fetched++
+		json.NewEncoder(w).Encode(&jose.JSONWebKeySet{Keys: published})
+	}))
+	defer ts.Close()
+
+	client := &jwksClient{url: ts.URL, ttl: time.Hour, minRefreshInterval: 0}
+	ctx := context.Background()
+
+	keys, err := client.keySet(ctx)
+	require.NoError(t, err)
+	claims, err := client.verify(ctx, sign(oldKey, "old"), keys)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", claims["sub"])
+
+	_, err = client.keySet(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fetched, "the cached key set is used within the TTL")
+
+	lock.Lock()
+	published = []jose.JSONWebKey{oldPublic, newPublic}
+	lock.Unlock()
+
+	claims, err = client.verify(ctx, sign(newPrivate, "new"), keys)
+	require.NoError(t, err, "an unknown kid refreshes the key set")
+	assert.Equal(t, "foo", claims["sub"])
+	assert.Equal(t, 2, fetched)
+
+	_, err = client.verify(ctx, sign(newPrivate, "unknown"), client.keys)
+	assert.EqualError(t, err, "no JSON Web Key with kid unknown was found")
+	assert.Equal(t, 3, fetched, "the key set is refreshed only once per verification")
+
+	client.minRefreshInterval = time.Hour
+	_, err = client.verify(ctx, sign(newPrivate, "unknown"), client.keys)
+	assert.EqualError(t, err, "no JSON Web Key with kid unknown was found")
+	assert.Equal(t, 3, fetched, "the key set is not refreshed more often than the minimum refresh interval")
+}
+
+func TestJWKSFile(t *testing.T) {
+	key, public := newTestJWK(t, "a")
+	_, es256 := newTestJWK(t, "b")
+	es256.Algorithm = "ES256"
+
+	f, err := ioutil.TempFile("", "hydra-jwks")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, json.NewEncoder(f).Encode(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{public, es256}}))
+	require.NoError(t, f.Close())
+
+	client := getJWKSFile(f.Name())
+	ctx := context.Background()
+	keys, err := client.keySet(ctx)
+	require.NoError(t, err)
+
+	claims, err := client.verify(ctx, signTestJWT(t, key, "a"), keys)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", claims["sub"])
+
+	_, err = client.verify(ctx, signTestJWT(t, key, "unknown"), keys)
+	assert.EqualError(t, err, f.Name()+" does not contain the key: no JSON Web Key with kid unknown was found")
+
+	_, err = client.verify(ctx, signTestJWT(t, key, "b"), keys)
+	assert.EqualError(t, err, "the JSON Web Key with kid b can not be used with alg RS256")
+
+	_, err = getJWKSFile(f.Name() + ".missing").keySet(ctx)
+	assert.Error(t, err)
+}
+
+func TestJWKSummary(t *testing.T) {
+	// The example key of RFC 7638 section 3.1.
+	var key jose.JSONWebKey
+	require.NoError(t, json.Unmarshal([]byte(`{"kty":"RSA","kid":"2011-04-29","alg":"RS256","e":"AQAB","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}`), &key))
+
+	summary := newJWKSummary(&key)
+	assert.Equal(t, "2011-04-29", summary.KeyID)
+	assert.Equal(t, "RS256", summary.Algorithm)
+	assert.Equal(t, "RSA", summary.KeyType)
+	assert.Equal(t, 2048, summary.Size)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", summary.Thumbprint)
+	assert.False(t, summary.Private)
+
+	private, _ := newTestJWK(t, "private")
+	summary = newJWKSummary(&jose.JSONWebKey{Key: private, KeyID: "private"})
+	assert.True(t, summary.Private)
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// decodeJWT decodes the header and the claims of a compact serialized JWT. The signature is NOT verified.
+func decodeJWT(token string) (header map[string]interface{}, claims map[string]interface{}, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, errors.Errorf("expected the token to have 3 dot-separated segments, got %d", len(parts))
+	}
+
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, nil, errors.Wrap(err, "could not decode header")
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, nil, errors.Wrap(err, "could not decode claims")
+	}
+	return header, claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	d := json.NewDecoder(strings.NewReader(string(raw)))
+	d.UseNumber()
+	return errors.WithStack(d.Decode(v))
+}
+
+// hashForAlg returns the hash function belonging to a JWS algorithm such as RS256 or ES384.
+func hashForAlg(alg string) (crypto.Hash, error) {
+	switch {
+	case strings.HasSuffix(alg, "256"):
+		return crypto.SHA256, nil
+	case strings.HasSuffix(alg, "384"):
+		return crypto.SHA384, nil
+	case strings.HasSuffix(alg, "512"):
+		return crypto.SHA512, nil
+	}
+	return 0, errors.Errorf("unable to pick a hash function for algorithm %s", alg)
+}
+
+// leftHalfHash computes the at_hash or c_hash of value as defined in OpenID Connect Core 1.0 section 3.1.3.6.
+func leftHalfHash(alg, value string) (string, error) {
+	h, err := hashForAlg(alg)
+	if err != nil {
+		return "", err
+	}
+
+	hasher := h.New()
+	hasher.Write([]byte(value))
+	sum := hasher.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
+
+// checkTokenHashes prints the report of tokenHashReport to info. A failed check is an error with exit code 7 if
+// verify is set, a warning otherwise.
+func checkTokenHashes(info io.Writer, idToken, accessToken, code string, verify bool) error {
+	report, ok := tokenHashReport(idToken, accessToken, code)
+	if len(report) > 0 {
+		fmt.Fprintf(info, "Token Hash Check:\n\t%s\n\n", strings.Join(report, "\n\t"))
+	}
+	if ok {
+		return nil
+	}
+	if verify {
+		return newExitError(exitCodeVerification, errors.New("The at_hash or c_hash claim of the ID token does not match the access token or the authorization code"))
+	}
+	warn("The at_hash or c_hash claim of the ID token does not match the access token or the authorization code, use --verify to fail instead")
+	return nil
+}
+
+// tokenHashReport checks the at_hash and c_hash claims of an ID token against the access token and the
+// authorization code. Empty values are skipped.
+func tokenHashReport(idToken, accessToken, code string) ([]string, bool) {
+	header, claims, err := decodeJWT(idToken)
+	if err != nil {
+		return []string{fmt.Sprintf("FAIL could not decode ID token: %s", err)}, false
+	}
+
+	alg, _ := header["alg"].(string)
+	var (
+		report []string
+		ok     = true
+	)
+	for _, check := range []struct {
+		claim string
+		value string
+	}{
+		{claim: "at_hash", value: accessToken},
+		{claim: "c_hash", value: code},
+	} {
+		if check.value == "" {
+			continue
+		}
+
+		expected, present := claims[check.claim].(string)
+		if !present {
+			report = append(report, fmt.Sprintf("SKIP %s is not set in the ID token", check.claim))
+			continue
+		}
+
+		actual, err := leftHalfHash(alg, check.value)
+		if err != nil {
+			report = append(report, fmt.Sprintf("FAIL %s: %s", check.claim, err))
+			ok = false
+		} else if actual != expected {
+			report = append(report, fmt.Sprintf("FAIL %s: expected %s, computed %s using %s", check.claim, expected, actual, alg))
+			ok = false
+		} else {
+			report = append(report, fmt.Sprintf("PASS %s", check.claim))
+		}
+	}
+	return report, ok
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unsignedJWT(t *testing.T, header, claims map[string]interface{}) string {
+	h, err := json.Marshal(header)
+	require.NoError(t, err)
+	c, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c) + ".c2ln"
+}
+
+func TestDecodeJWT(t *testing.T) {
+	header, claims, err := decodeJWT(unsignedJWT(t, map[string]interface{}{"alg": "RS256"}, map[string]interface{}{"sub": "peter"}))
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", header["alg"])
+	assert.Equal(t, "peter", claims["sub"])
+
+	_, _, err = decodeJWT("foo.bar")
+	assert.Error(t, err)
+}
+
+func TestLeftHalfHash(t *testing.T) {
+	// Example from OpenID Connect Core 1.0 Appendix A.3
+	actual, err := leftHalfHash("RS256", "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y")
+	require.NoError(t, err)
+	assert.Equal(t, "77QmUPtjPfzWtF2AnpK9RQ", actual)
+
+	_, err = leftHalfHash("none", "foo")
+	assert.Error(t, err)
+}
+
+func TestTokenHashReport(t *testing.T) {
+	atHash, err := leftHalfHash("RS256", "access-token")
+	require.NoError(t, err)
+
+	idToken := unsignedJWT(t, map[string]interface{}{"alg": "RS256"}, map[string]interface{}{"at_hash": atHash, "c_hash": "invalid"})
+
+	report, ok := tokenHashReport(idToken, "access-token", "")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"PASS at_hash"}, report)
+
+	report, ok = tokenHashReport(idToken, "access-token", "code")
+	assert.False(t, ok)
+	assert.Len(t, report, 2)
+}
+
+func TestCheckTokenHashes(t *testing.T) {
+	atHash, err := leftHalfHash("RS256", "access-token")
+	require.NoError(t, err)
+
+	idToken := unsignedJWT(t, map[string]interface{}{"alg": "RS256"}, map[string]interface{}{"at_hash": atHash})
+
+	var info bytes.Buffer
+	require.NoError(t, checkTokenHashes(&info, idToken, "access-token", "", true))
+	assert.Contains(t, info.String(), "PASS at_hash")
+
+	warnings := len(emittedWarnings())
+	require.NoError(t, checkTokenHashes(ioutil.Discard, idToken, "other-access-token", "", false))
+	assert.Len(t, emittedWarnings(), warnings+1, "a mismatch is a warning without --verify")
+
+	err = checkTokenHashes(ioutil.Discard, idToken, "other-access-token", "", true)
+	require.Error(t, err)
+	exit, ok := errors.Cause(err).(*exitError)
+	require.True(t, ok)
+	assert.Equal(t, exitCodeVerification, exit.code)
+	assert.NotEqual(t, 0, exit.code)
+}
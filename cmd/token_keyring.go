@@ -0,0 +1,69 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// keyringService is the service under which client secrets are stored in the keyring of the operating system.
+const keyringService = "hydra"
+
+const keyringHelp = `Client secrets can be read from the keyring of the operating system using --secret-keyring <name>.
+Store the secret under the service "hydra" first:
+
+  macOS: security add-generic-password -s hydra -a <name> -w
+  Linux: secret-tool store --label "hydra <name>" service hydra account <name>`
+
+// keyringSecret reads the secret stored under name from the keyring of the operating system. It uses the
+// "security" tool on macOS and "secret-tool" (libsecret) on Linux.
+func keyringSecret(name string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", name, "-w")
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", name)
+	default:
+		return "", errors.Errorf("reading secrets from the keyring is not supported on %s", runtime.GOOS)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			err = errors.Errorf("%s: %s", err, detail)
+		}
+		return "", errors.Wrapf(err, "could not read secret %s from the keyring", name)
+	}
+
+	secret := strings.TrimRight(string(out), "\r\n")
+	if secret == "" {
+		return "", errors.Errorf("the keyring does not contain a secret for %s", name)
+	}
+	return secret, nil
+}
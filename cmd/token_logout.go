@@ -0,0 +1,210 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ory/hydra/pkg"
+	"github.com/ory/hydra/rand/sequence"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// tokenLogoutCmd represents the logout command
+var tokenLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Test RP-initiated logout",
+	Long: `This command opens the end_session_endpoint of the cluster in the browser as defined by OpenID Connect
+RP-Initiated Logout 1.0 and waits for the redirect to --post-logout-redirect.
+
+Use --post-logout-redirect to check whether the cluster accepts a post logout redirect uri: registered uris
+are redirected to, the cluster shows an error instead of redirecting to uris which are not registered for
+the client. The command only waits for the redirect if --post-logout-redirect points to localhost, combine
+it with --timeout to detect a rejected uri in scripts.
+
+` + exitCodesHelp,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext()
+		defer cancel()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, newTokenHTTPClient(cmd))
+
+		clientID, _ := cmd.Flags().GetString("id")
+		idToken, _ := cmd.Flags().GetString("id-token")
+		tokenFile, _ := cmd.Flags().GetString("token-file")
+		redirect, _ := cmd.Flags().GetString("post-logout-redirect")
+		endpoint, _ := cmd.Flags().GetString("end-session-url")
+
+		if err := checkBrowserCommand(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if clientID == "" {
+			clientID = c.ClientID
+		}
+		if idToken == "" && tokenFile != "" {
+			stored, err := readTokenFile(tokenFile)
+			if err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+			idToken = stored.IDToken
+		}
+		if endpoint == "" {
+			endpoints, err := resolveClusterEndpoints(ctx, tokenIssuer, c.ClusterURL)
+			if err != nil {
+				return newContextExitError(ctx, exitCodeConfig, err)
+			}
+			endpoint = endpoints.EndSession
+			if endpoints.Discovery == nil {
+				// Without --issuer, the end_session_endpoint of the cluster is still preferred if it advertises one.
+				if discovery, err := fetchDiscovery(ctx, c.ClusterURL); err == nil && discovery.EndSessionEndpoint != "" {
+					endpoint = discovery.EndSessionEndpoint
+				}
+			}
+		}
+
+		state, err := sequence.RuneSequence(24, sequence.AlphaLower)
+		pkg.Must(err, "Could not generate random state: %s", err)
+
+		location, err := endSessionURL(endpoint, idToken, clientID, redirect, string(state))
+		if err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+
+		fmt.Printf("If your browser does not open automatically, navigate to:\n\n\t%s\n\n", location)
+		if ok, _ := cmd.Flags().GetBool("no-open"); !ok {
+			openBrowser(cmd, location)
+		}
+
+		u, err := url.Parse(redirect)
+		if err != nil {
+			return newExitError(exitCodeConfig, errors.Wrap(err, "Could not parse --post-logout-redirect"))
+		}
+		if !isLoopback(u.Hostname()) {
+			fmt.Printf("Not waiting for the redirect because %s is not served by this command.\n", redirect)
+			return nil
+		}
+
+		bindAll, _ := cmd.Flags().GetBool("bind-all")
+		if err := waitForLogoutRedirect(ctx, u, string(state), bindAll); err != nil {
+			return err
+		}
+		fmt.Printf("The cluster accepted the post logout redirect uri %s and redirected back after the logout.\n", redirect)
+		return nil
+	},
+}
+
+// endSessionURL builds the logout url as defined in OpenID Connect RP-Initiated Logout 1.0 section 2.
+func endSessionURL(endpoint, idToken, clientID, redirect, state string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", errors.Wrap(err, "Could not parse the end session url")
+	}
+
+	query := u.Query()
+	if idToken != "" {
+		query.Set("id_token_hint", idToken)
+	}
+	if clientID != "" {
+		query.Set("client_id", clientID)
+	}
+	if redirect != "" {
+		query.Set("post_logout_redirect_uri", redirect)
+		query.Set("state", state)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// waitForLogoutRedirect serves the post logout redirect uri, which must point to a loopback address, until the browser
+// was redirected to it.
+func waitForLogoutRedirect(ctx context.Context, redirect *url.URL, state string, bindAll bool) error {
+	port := redirect.Port()
+	if port == "" {
+		port = "80"
+	}
+
+	results := make(chan error, 1)
+	mux := http.NewServeMux()
+	path := redirect.Path
+	if path == "" {
+		path = "/"
+	}
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if got := r.URL.Query().Get("state"); got != state {
+			err = newExitError(exitCodeStateMismatch, errors.Errorf("States do not match. Expected %s, got %s", state, got))
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.Write([]byte("<html><head></head><body>You have been logged out, you may close this window.</body></html>"))
+		}
+
+		select {
+		case results <- err:
+		default:
+		}
+	})
+
+	addr := callbackAddress(bindAll, port)
+	if host := redirect.Hostname(); !bindAll && host != "localhost" {
+		// Loopback addresses such as ::1 are bound as they are, localhost is bound on 127.0.0.1.
+		addr = net.JoinHostPort(host, port)
+	}
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			results <- errors.Wrap(err, "Could not start the post logout redirect listener")
+		}
+	}()
+
+	var err error
+	select {
+	case err = <-results:
+	case <-ctx.Done():
+		err = newContextExitError(ctx, exitCodeCallbackError, errors.Wrap(ctx.Err(), "Stopped waiting for the post logout redirect"))
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	server.Shutdown(shutdown)
+	return err
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenLogoutCmd)
+
+	tokenLogoutCmd.Flags().Bool("no-open", false, "Do not open the browser window automatically")
+	tokenLogoutCmd.Flags().String("browser-command", "", "Open the logout url using this command instead of the default browser, the url is appended as the last argument")
+	tokenLogoutCmd.Flags().String("id", "", "Force a client id, defaults to value from config file")
+	tokenLogoutCmd.Flags().String("id-token", "", "The ID token sent as id_token_hint")
+	tokenLogoutCmd.Flags().String("token-file", "", "Read the id_token_hint from a token file written by \"hydra token user --out\"")
+	tokenLogoutCmd.Flags().Bool("bind-all", false, "Bind the post logout redirect listener on all interfaces instead of the loopback address of --post-logout-redirect only")
+	tokenLogoutCmd.Flags().String("post-logout-redirect", "http://localhost:4445/logout", "The post_logout_redirect_uri, test unregistered uris to check that the cluster rejects them")
+	tokenLogoutCmd.Flags().String("end-session-url", "", "Force the end session url, defaults to the end_session_endpoint of the discovery document or /oauth2/sessions/logout of the cluster url value from config file")
+}
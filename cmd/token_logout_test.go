@@ -0,0 +1,140 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndSessionURL(t *testing.T) {
+	location, err := endSessionURL("https://hydra/oauth2/sessions/logout?ui=dark", "id-token", "client", "http://localhost:4445/logout", "state")
+	require.NoError(t, err)
+	u, err := url.Parse(location)
+	require.NoError(t, err)
+	assert.Equal(t, "/oauth2/sessions/logout", u.Path)
+	assert.Equal(t, url.Values{
+		"ui":                       {"dark"},
+		"id_token_hint":            {"id-token"},
+		"client_id":                {"client"},
+		"post_logout_redirect_uri": {"http://localhost:4445/logout"},
+		"state":                    {"state"},
+	}, u.Query())
+
+	// The state is only sent along with a post logout redirect uri.
+	location, err = endSessionURL("https://hydra/oauth2/sessions/logout", "", "", "", "state")
+	require.NoError(t, err)
+	assert.Equal(t, "https://hydra/oauth2/sessions/logout", location)
+
+	_, err = endSessionURL("://hydra", "", "", "", "state")
+	assert.Error(t, err)
+}
+
+// logoutRedirect returns a post logout redirect uri on a free port of host.
+func logoutRedirect(t *testing.T, host string) *url.URL {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		t.Skipf("Could not listen on %s: %s", host, err)
+	}
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	return &url.URL{Scheme: "http", Host: net.JoinHostPort(host, port), Path: "/logout"}
+}
+
+// redirectBack requests location until the post logout redirect listener is up.
+func redirectBack(t *testing.T, location string) int {
+	for i := 0; i < 50; i++ {
+		if response, err := http.Get(location); err == nil {
+			response.Body.Close()
+			return response.StatusCode
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("The post logout redirect listener did not start")
+	return 0
+}
+
+func TestWaitForLogoutRedirect(t *testing.T) {
+	for k, tc := range []struct {
+		state        string
+		expectStatus int
+		expectCode   int
+	}{
+		{state: "state", expectStatus: http.StatusOK},
+		{state: "other", expectStatus: http.StatusInternalServerError, expectCode: exitCodeStateMismatch},
+	} {
+		redirect := logoutRedirect(t, "127.0.0.1")
+		done := make(chan error, 1)
+		go func() { done <- waitForLogoutRedirect(context.Background(), redirect, "state", false) }()
+
+		assert.Equal(t, tc.expectStatus, redirectBack(t, redirect.String()+"?state="+tc.state), "case %d", k)
+		err := <-done
+		if tc.expectCode == 0 {
+			assert.NoError(t, err, "case %d", k)
+			continue
+		}
+		require.Error(t, err, "case %d", k)
+		e, ok := errors.Cause(err).(*exitError)
+		require.True(t, ok, "case %d", k)
+		assert.Equal(t, tc.expectCode, e.code, "case %d", k)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := waitForLogoutRedirect(ctx, logoutRedirect(t, "127.0.0.1"), "state", false)
+	require.Error(t, err)
+	e, ok := errors.Cause(err).(*exitError)
+	require.True(t, ok)
+	assert.Equal(t, exitCodeTimeout, e.code)
+}
+
+func TestWaitForLogoutRedirectBindsHost(t *testing.T) {
+	redirect := logoutRedirect(t, "::1")
+	done := make(chan error, 1)
+	go func() { done <- waitForLogoutRedirect(context.Background(), redirect, "state", false) }()
+
+	var conn net.Conn
+	var err error
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", redirect.Host); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.NoError(t, err, "The post logout redirect listener did not start")
+	conn.Close()
+
+	// Only the loopback address of the redirect uri is bound, not 127.0.0.1.
+	_, err = net.Dial("tcp", net.JoinHostPort("127.0.0.1", redirect.Port()))
+	assert.Error(t, err)
+
+	assert.Equal(t, http.StatusOK, redirectBack(t, redirect.String()+"?state=state"))
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// tokenMetrics are the results of a token flow written by --metrics-file in the Prometheus text format, for
+// example to be picked up by the textfile collector of the node exporter.
+type tokenMetrics struct {
+	Command  string
+	ClientID string
+	Scopes   []string
+	Started  time.Time
+	Finished time.Time
+	Err      error
+}
+
+func (m *tokenMetrics) labels() string {
+	return fmt.Sprintf(`client_id="%s",scopes="%s"`, escapeLabelValue(m.ClientID), escapeLabelValue(strings.Join(m.Scopes, " ")))
+}
+
+func (m *tokenMetrics) encode() []byte {
+	var b bytes.Buffer
+	labels := m.labels()
+	prefix := "hydra_token_" + strings.Replace(m.Command, "-", "_", -1)
+
+	success, code := 1, 0
+	if m.Err != nil {
+		success, code = 0, 1
+		if e, ok := errors.Cause(m.Err).(*exitError); ok {
+			code = e.code
+		}
+	}
+
+	fmt.Fprintf(&b, "# HELP %s_success Whether the last flow succeeded.\n# TYPE %s_success gauge\n", prefix, prefix)
+	fmt.Fprintf(&b, "%s_success{%s} %d\n", prefix, labels, success)
+	fmt.Fprintf(&b, "# HELP %s_exit_code The exit code of the last flow.\n# TYPE %s_exit_code gauge\n", prefix, prefix)
+	fmt.Fprintf(&b, "%s_exit_code{%s} %d\n", prefix, labels, code)
+	fmt.Fprintf(&b, "# HELP %s_duration_seconds The duration of the last flow.\n# TYPE %s_duration_seconds gauge\n", prefix, prefix)
+	fmt.Fprintf(&b, "%s_duration_seconds{%s} %f\n", prefix, labels, m.Finished.Sub(m.Started).Seconds())
+	fmt.Fprintf(&b, "# HELP %s_last_run_timestamp_seconds When the last flow finished.\n# TYPE %s_last_run_timestamp_seconds gauge\n", prefix, prefix)
+	fmt.Fprintf(&b, "%s_last_run_timestamp_seconds{%s} %d\n", prefix, labels, m.Finished.Unix())
+	return b.Bytes()
+}
+
+// write writes the metrics to path. The file is replaced atomically so that collectors never read a partial file.
+func (m *tokenMetrics) write(path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(m.encode()); err != nil {
+		tmp.Close()
+		return errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), path))
+}
+
+// escapeLabelValue escapes a label value as required by the Prometheus text format.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
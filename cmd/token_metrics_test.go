@@ -0,0 +1,52 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeLabelValue(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\nd`, escapeLabelValue("a\"b\\c\nd"))
+}
+
+func TestTokenMetricsEncode(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	m := &tokenMetrics{
+		Command:  "user",
+		ClientID: "my-app",
+		Scopes:   []string{"openid", "offline"},
+		Started:  now.Add(-2 * time.Second),
+		Finished: now,
+		Err:      newExitError(exitCodeStateMismatch, errors.New("state mismatch")),
+	}
+
+	out := string(m.encode())
+	assert.True(t, strings.Contains(out, `hydra_token_user_success{client_id="my-app",scopes="openid offline"} 0`), out)
+	assert.True(t, strings.Contains(out, `hydra_token_user_exit_code{client_id="my-app",scopes="openid offline"} 4`), out)
+	assert.True(t, strings.Contains(out, `hydra_token_user_duration_seconds{client_id="my-app",scopes="openid offline"} 2.000000`), out)
+	assert.True(t, strings.Contains(out, `hydra_token_user_last_run_timestamp_seconds{client_id="my-app",scopes="openid offline"} 1500000000`), out)
+}
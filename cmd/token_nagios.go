@@ -0,0 +1,113 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// Exit codes of monitoring plugins as defined by the Nagios plugin guidelines, used by --format nagios.
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+var nagiosStatus = map[int]string{
+	nagiosOK:       "OK",
+	nagiosWarning:  "WARNING",
+	nagiosCritical: "CRITICAL",
+	nagiosUnknown:  "UNKNOWN",
+}
+
+// nagiosCheck is the result of `hydra token user --format nagios`.
+type nagiosCheck struct {
+	code    int
+	message string
+
+	// ttl is the remaining lifetime of the access token, negative if it is unknown.
+	ttl      time.Duration
+	warning  time.Duration
+	critical time.Duration
+	duration time.Duration
+}
+
+// newNagiosCheck turns the outcome of a flow into a check result. Failed flows are CRITICAL, or UNKNOWN if the
+// check itself is misconfigured. Tokens expiring within the warning or critical threshold are WARNING or CRITICAL.
+func newNagiosCheck(token *oauth2.Token, err error, warning, critical, duration time.Duration, now time.Time) *nagiosCheck {
+	check := &nagiosCheck{ttl: -1, warning: warning, critical: critical, duration: duration}
+
+	if err != nil {
+		check.code, check.message = nagiosCritical, err.Error()
+		if e, ok := errors.Cause(err).(*exitError); ok && e.code == exitCodeConfig {
+			check.code = nagiosUnknown
+		}
+		return check
+	}
+	if token == nil {
+		check.code, check.message = nagiosUnknown, "no token was issued"
+		return check
+	}
+
+	if token.Expiry.IsZero() {
+		check.code, check.message = nagiosOK, "the access token does not expire"
+		return check
+	}
+
+	check.ttl = token.Expiry.Sub(now).Truncate(time.Second)
+	check.message = fmt.Sprintf("the access token expires in %s", check.ttl)
+	switch {
+	case check.ttl <= critical:
+		check.code = nagiosCritical
+	case check.ttl <= warning:
+		check.code = nagiosWarning
+	default:
+		check.code = nagiosOK
+	}
+	return check
+}
+
+// String formats the check as the first line of plugin output including performance data.
+func (n *nagiosCheck) String() string {
+	perfdata := []string{}
+	if n.ttl >= 0 {
+		perfdata = append(perfdata, fmt.Sprintf("ttl=%ds;%d;%d;0", int64(n.ttl.Seconds()), int64(n.warning.Seconds()), int64(n.critical.Seconds())))
+	}
+	perfdata = append(perfdata, fmt.Sprintf("duration=%.3fs", n.duration.Seconds()))
+
+	// The pipe separates the message from the performance data and newlines start the long output.
+	message := strings.NewReplacer("|", "/", "\n", " ").Replace(n.message)
+	return fmt.Sprintf("HYDRA TOKEN %s - %s | %s", nagiosStatus[n.code], message, strings.Join(perfdata, " "))
+}
+
+// err returns the error making the command exit with the plugin exit code of the check, nil if it is OK.
+func (n *nagiosCheck) err() error {
+	if n.code == nagiosOK {
+		return nil
+	}
+	return newExitError(n.code, errors.New(n.message))
+}
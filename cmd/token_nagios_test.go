@@ -0,0 +1,83 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestNagiosCheck(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiring := func(in time.Duration) *oauth2.Token {
+		return &oauth2.Token{AccessToken: "foo", Expiry: now.Add(in)}
+	}
+
+	for k, tc := range []struct {
+		token  *oauth2.Token
+		err    error
+		code   int
+		output string
+	}{
+		{
+			token:  expiring(time.Hour),
+			code:   nagiosOK,
+			output: "HYDRA TOKEN OK - the access token expires in 1h0m0s | ttl=3600s;600;60;0 duration=1.500s",
+		},
+		{
+			token:  expiring(5 * time.Minute),
+			code:   nagiosWarning,
+			output: "HYDRA TOKEN WARNING - the access token expires in 5m0s | ttl=300s;600;60;0 duration=1.500s",
+		},
+		{
+			token:  expiring(30 * time.Second),
+			code:   nagiosCritical,
+			output: "HYDRA TOKEN CRITICAL - the access token expires in 30s | ttl=30s;600;60;0 duration=1.500s",
+		},
+		{
+			token:  &oauth2.Token{AccessToken: "foo"},
+			code:   nagiosOK,
+			output: "HYDRA TOKEN OK - the access token does not expire | duration=1.500s",
+		},
+		{
+			err:    newExitError(exitCodeExchange, errors.New("invalid_client | bad\ncredentials")),
+			code:   nagiosCritical,
+			output: "HYDRA TOKEN CRITICAL - invalid_client / bad credentials | duration=1.500s",
+		},
+		{
+			err:    newExitError(exitCodeConfig, errors.New("unknown flag")),
+			code:   nagiosUnknown,
+			output: "HYDRA TOKEN UNKNOWN - unknown flag | duration=1.500s",
+		},
+	} {
+		check := newNagiosCheck(tc.token, tc.err, 10*time.Minute, time.Minute, 1500*time.Millisecond, now)
+		assert.Equal(t, tc.output, check.String(), "case %d", k)
+		if tc.code == nagiosOK {
+			assert.NoError(t, check.err(), "case %d", k)
+		} else {
+			assert.Equal(t, tc.code, check.err().(*exitError).code, "case %d", k)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ory/hydra/pkg"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+const (
+	accessTokenFormatJWT    = "jwt"
+	accessTokenFormatOpaque = "opaque"
+)
+
+// tokenOutput is the JSON representation of a token printed by `hydra token user --format json`.
+type tokenOutput struct {
+	AccessToken       string    `json:"access_token"`
+	AccessTokenFormat string    `json:"access_token_format"`
+	TokenType         string    `json:"token_type,omitempty"`
+	RefreshToken      string    `json:"refresh_token,omitempty"`
+	IDToken           string    `json:"id_token,omitempty"`
+	Scope             string    `json:"scope,omitempty"`
+	Expiry            time.Time `json:"expiry"`
+	SessionState      string    `json:"session_state,omitempty"`
+}
+
+// callbackErrorOutput is the JSON representation of an error returned to the callback.
+type callbackErrorOutput struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+	ErrorURI         string `json:"error_uri,omitempty"`
+}
+
+// codeOutput is the authorize response printed by --code-only and --response-type none.
+type codeOutput struct {
+	Code         string `json:"code,omitempty"`
+	State        string `json:"state"`
+	SessionState string `json:"session_state,omitempty"`
+}
+
+// callbackResponseOutput is the body returned by the callback with --callback-response json.
+type callbackResponseOutput struct {
+	Status       string       `json:"status"`
+	Error        string       `json:"error,omitempty"`
+	Code         string       `json:"code,omitempty"`
+	SessionState string       `json:"session_state,omitempty"`
+	Token        *tokenOutput `json:"token,omitempty"`
+}
+
+// execCredential is the ExecCredential object of the client.authentication.k8s.io/v1beta1 API read by
+// client-go credential plugins, see https://kubernetes.io/docs/reference/access-authn-authz/authentication/#client-go-credential-plugins
+type execCredential struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Status     execCredentialStatus `json:"status"`
+}
+
+type execCredentialStatus struct {
+	Token               string `json:"token"`
+	ExpirationTimestamp string `json:"expirationTimestamp,omitempty"`
+}
+
+// newExecCredential uses the ID token as the bearer token, as expected by the OpenID Connect authenticator of
+// the Kubernetes API server. The credential expires with the ID token.
+func newExecCredential(out *tokenOutput) (*execCredential, error) {
+	if out.IDToken == "" {
+		return nil, errors.New(`the token response does not contain an ID token, make sure to request the "openid" scope`)
+	}
+
+	expiry := out.Expiry
+	if _, claims, err := decodeJWT(out.IDToken); err == nil {
+		if exp, ok := numericClaim(claims, "exp"); ok {
+			expiry = time.Unix(exp, 0)
+		}
+	}
+
+	credential := &execCredential{
+		APIVersion: "client.authentication.k8s.io/v1beta1",
+		Kind:       "ExecCredential",
+		Status:     execCredentialStatus{Token: out.IDToken},
+	}
+	if !expiry.IsZero() {
+		credential.Status.ExpirationTimestamp = expiry.UTC().Format(time.RFC3339)
+	}
+	return credential, nil
+}
+
+// accessTokenFormat tells if an access token looks like a JWT (three dot-separated segments) or is opaque.
+func accessTokenFormat(token string) string {
+	if parts := strings.Split(token, "."); len(parts) == 3 && parts[0] != "" && parts[1] != "" {
+		return accessTokenFormatJWT
+	}
+	return accessTokenFormatOpaque
+}
+
+func newTokenOutput(token *oauth2.Token) *tokenOutput {
+	out := &tokenOutput{
+		AccessToken:       token.AccessToken,
+		AccessTokenFormat: accessTokenFormat(token.AccessToken),
+		TokenType:         token.TokenType,
+		RefreshToken:      token.RefreshToken,
+		Expiry:            token.Expiry,
+	}
+	if idt, ok := token.Extra("id_token").(string); ok {
+		out.IDToken = idt
+	}
+	if scope, ok := token.Extra("scope").(string); ok {
+		out.Scope = scope
+	}
+	return out
+}
+
+// tokenStdout is where the token is printed. --stdout-token-only and --field point os.Stdout to stderr while the
+// command runs, so that nothing else printed to stdout, not even by other packages, ends up next to the token.
+var tokenStdout io.Writer = os.Stdout
+
+// stdoutToStderr points os.Stdout to stderr, so that only what is written to tokenStdout ends up on stdout. The
+// returned function restores os.Stdout.
+func stdoutToStderr() func() {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	return func() { os.Stdout = stdout }
+}
+
+// infoWriter returns where informational messages are written to. They go to stderr
+// for machine readable formats so that stdout only contains the requested output.
+func infoWriter(format string) io.Writer {
+	if format == "text" {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+func printToken(cmd *cobra.Command, token *oauth2.Token) error {
+	return printTokenOutput(cmd, token, &TokenExtras{})
+}
+
+// printTokenOutput prints the token to stdout using the renderer selected by --format, only the access token
+// if --stdout-token-only is set, or only the fields selected by --field.
+func printTokenOutput(cmd *cobra.Command, token *oauth2.Token, extras *TokenExtras) error {
+	if ok, _ := cmd.Flags().GetBool("stdout-token-only"); ok {
+		fmt.Fprintln(tokenStdout, token.AccessToken)
+		return nil
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if fields, _ := cmd.Flags().GetStringArray("field"); len(fields) > 0 {
+		return errors.Wrap(renderTokenFields(tokenStdout, format, fields, token, extras), "Could not print the fields of the token")
+	}
+
+	renderer, ok := tokenRenderer(format)
+	if !ok {
+		return newExitError(exitCodeConfig, checkFormat(format))
+	}
+	if extras.ResourceURL == "" {
+		extras.ResourceURL, _ = cmd.Flags().GetString("resource-url")
+	}
+	return errors.Wrapf(renderer.Render(token, extras, tokenStdout), "Could not print the token as %s", format)
+}
+
+// printCodeOutput prints the authorize response captured by --code-only or --response-type none.
+func printCodeOutput(format string, out *codeOutput) {
+	if format == "json" {
+		printJSON(out)
+		return
+	}
+
+	if out.Code != "" {
+		fmt.Printf("Authorization Code:\n\t%s\n\n", out.Code)
+	}
+	fmt.Printf("State:\n\t%s\n\n", out.State)
+	if out.SessionState != "" {
+		fmt.Printf("Session State:\n\t%s\n\n", out.SessionState)
+	}
+}
+
+// clientConfigOutput is the resolved client configuration printed by --print-client-config.
+type clientConfigOutput struct {
+	ClientID     string            `json:"client_id"`
+	ClientSecret string            `json:"client_secret"`
+	AuthURL      string            `json:"auth_url"`
+	TokenURL     string            `json:"token_url"`
+	RedirectURL  string            `json:"redirect_url"`
+	Scopes       []string          `json:"scopes"`
+	AuthStyle    string            `json:"auth_style"`
+	Sources      map[string]string `json:"sources"`
+}
+
+// printClientConfig prints the effective oauth2.Config to w. The client secret is never printed.
+func printClientConfig(w io.Writer, conf *oauth2.Config, authStyle string, sources map[string]string) {
+	out := &clientConfigOutput{
+		ClientID:    conf.ClientID,
+		AuthURL:     conf.Endpoint.AuthURL,
+		TokenURL:    conf.Endpoint.TokenURL,
+		RedirectURL: conf.RedirectURL,
+		Scopes:      conf.Scopes,
+		AuthStyle:   authStyle,
+		Sources:     sources,
+	}
+	if conf.ClientSecret != "" {
+		out.ClientSecret = "[REDACTED]"
+	}
+
+	encoded, err := json.MarshalIndent(out, "\t", "\t")
+	pkg.Must(err, "Could not encode client configuration: %s", err)
+	fmt.Fprintf(w, "Client Configuration:\n\t%s\n\n", encoded)
+}
+
+func printJSON(v interface{}) {
+	err := writeJSON(os.Stdout, v)
+	pkg.Must(err, "Could not encode output to JSON: %s", err)
+}
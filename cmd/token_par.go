@@ -0,0 +1,64 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// pushedAuthorizationResponse is the response of a pushed authorization request as defined in RFC 9126.
+type pushedAuthorizationResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int    `json:"expires_in"`
+}
+
+// pushAuthorizationRequest pushes the query parameters of the authorization url location to the pushed
+// authorization request endpoint and returns the url the user agent has to be sent to instead. The client
+// authenticates as it does at the token endpoint: authStyle is the value of --auth-style and assertion is only
+// used with "private_key_jwt".
+func pushAuthorizationRequest(ctx context.Context, endpoint, location, clientID, clientSecret, authStyle string, assertion *clientAssertion) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	values := u.Query()
+	if authStyle == "private_key_jwt" {
+		if err := assertion.authenticate(values, endpoint); err != nil {
+			return "", err
+		}
+		clientSecret = ""
+	}
+
+	var res pushedAuthorizationResponse
+	if err := postForm(ctx, endpoint, clientID, clientSecret, authStyle, values, &res); err != nil {
+		return "", errors.Wrap(err, "pushed authorization request failed")
+	}
+	if res.RequestURI == "" {
+		return "", errors.New("pushed authorization request endpoint did not return a request_uri")
+	}
+
+	u.RawQuery = url.Values{"client_id": {clientID}, "request_uri": {res.RequestURI}}.Encode()
+	return u.String(), nil
+}
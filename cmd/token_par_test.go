@@ -0,0 +1,121 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ory/hydra/jwk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushAuthorizationRequest(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	block, err := jwk.PEMBlockForKey(key)
+	require.NoError(t, err)
+	f, err := ioutil.TempFile("", "hydra-client-key")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, pem.Encode(f, block))
+	require.NoError(t, f.Close())
+	assertion, err := newClientAssertion("client", f.Name(), "", 30*time.Second, "")
+	require.NoError(t, err)
+
+	var pushed *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		pushed = r
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&pushedAuthorizationResponse{RequestURI: "urn:ietf:params:oauth:request_uri:abc", ExpiresIn: 60})
+	}))
+	defer ts.Close()
+
+	location := "https://hydra/oauth2/auth?client_id=client&response_type=code&scope=openid&state=state"
+	for k, tc := range []struct {
+		authStyle    string
+		clientSecret string
+		assertion    *clientAssertion
+		check        func(r *http.Request)
+	}{
+		{
+			authStyle:    "header",
+			clientSecret: "secret",
+			check: func(r *http.Request) {
+				id, secret, ok := r.BasicAuth()
+				assert.True(t, ok)
+				assert.Equal(t, "client", id)
+				assert.Equal(t, "secret", secret)
+				assert.Empty(t, r.PostForm.Get("client_secret"))
+			},
+		},
+		{
+			authStyle:    "body",
+			clientSecret: "secret",
+			check: func(r *http.Request) {
+				_, _, ok := r.BasicAuth()
+				assert.False(t, ok)
+				assert.Equal(t, "client", r.PostForm.Get("client_id"))
+				assert.Equal(t, "secret", r.PostForm.Get("client_secret"))
+			},
+		},
+		{
+			authStyle: "private_key_jwt",
+			assertion: assertion,
+			check: func(r *http.Request) {
+				_, _, ok := r.BasicAuth()
+				assert.False(t, ok)
+				assert.Empty(t, r.PostForm.Get("client_secret"))
+				assert.Equal(t, clientAssertionType, r.PostForm.Get("client_assertion_type"))
+				_, claims, err := decodeJWT(r.PostForm.Get("client_assertion"))
+				require.NoError(t, err)
+				assert.Equal(t, ts.URL, claims["aud"])
+			},
+		},
+	} {
+		redirect, err := pushAuthorizationRequest(context.Background(), ts.URL, location, "client", tc.clientSecret, tc.authStyle, tc.assertion)
+		require.NoError(t, err, "case %d", k)
+
+		u, err := url.Parse(redirect)
+		require.NoError(t, err, "case %d", k)
+		assert.Equal(t, "/oauth2/auth", u.Path, "case %d", k)
+		assert.Equal(t, url.Values{"client_id": {"client"}, "request_uri": {"urn:ietf:params:oauth:request_uri:abc"}}, u.Query(), "case %d", k)
+
+		require.NotNil(t, pushed, "case %d", k)
+		assert.Equal(t, "code", pushed.PostForm.Get("response_type"), "case %d", k)
+		assert.Equal(t, "state", pushed.PostForm.Get("state"), "case %d", k)
+		tc.check(pushed)
+		pushed = nil
+	}
+}
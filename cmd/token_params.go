@@ -0,0 +1,52 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/ory/hydra/pkg"
+)
+
+// addTokenParams adds params to the form body of token requests, which are recognized by their grant_type.
+// This is needed because the oauth2 library does not support custom parameters when exchanging the code.
+func addTokenParams(req *http.Request, params url.Values) error {
+	if len(params) == 0 {
+		return nil
+	}
+	return rewriteTokenForm(req, func(form url.Values) error {
+		for key, values := range params {
+			form[key] = append(form[key], values...)
+		}
+		return nil
+	})
+}
+
+// rewriteTokenForm lets rewrite change the form of req if it is a token request, other requests are sent unchanged.
+func rewriteTokenForm(req *http.Request, rewrite func(url.Values) error) error {
+	return pkg.RewriteForm(req, func(form url.Values) (bool, error) {
+		if form.Get("grant_type") == "" {
+			return false, nil
+		}
+		return true, rewrite(form)
+	})
+}
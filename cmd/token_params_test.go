@@ -0,0 +1,62 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddTokenParams(t *testing.T) {
+	params := url.Values{"audience": {"https://api"}}
+
+	for k, tc := range []struct {
+		body   string
+		expect url.Values
+	}{
+		{
+			body:   "grant_type=authorization_code&code=foo",
+			expect: url.Values{"grant_type": {"authorization_code"}, "code": {"foo"}, "audience": {"https://api"}},
+		},
+		{
+			body:   "token=foo",
+			expect: url.Values{"token": {"foo"}},
+		},
+	} {
+		req, err := http.NewRequest("POST", "http://hydra/oauth2/token", strings.NewReader(tc.body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		require.NoError(t, addTokenParams(req, params), "case %d", k)
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		form, err := url.ParseQuery(string(body))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expect, form, "case %d", k)
+		assert.EqualValues(t, len(body), req.ContentLength, "case %d", k)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/oauth2"
+)
+
+// schemeWarning returns a warning about combinations of the redirect url and the authorization url schemes
+// which are known to break the authorize code flow in subtle ways.
+func schemeWarning(redirectURL, authURL string) string {
+	redirect, err := url.Parse(redirectURL)
+	if err != nil {
+		return ""
+	}
+	auth, err := url.Parse(authURL)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case redirect.Scheme == "http" && auth.Scheme == "https" && !isLoopback(redirect.Hostname()):
+		return "The redirect url " + redirectURL + " uses http while the cluster uses https. The authorization code is sent over an unencrypted connection and Hydra only accepts http redirect urls for loopback hosts."
+	case redirect.Scheme == "http" && auth.Scheme == "https":
+		return "The redirect url " + redirectURL + " uses http while the cluster uses https. Cookies set with the Secure flag are not available to the callback and some browsers warn when leaving https."
+	case redirect.Scheme == "https" && auth.Scheme == "http" && !isLoopback(auth.Hostname()):
+		return "The cluster " + authURL + " uses http while the redirect url uses https. Cookies marked as Secure are not sent over http, which commonly breaks login and consent with CSRF errors."
+	}
+	return ""
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// htmlTags strips the markup of error pages returned by preflightAuthorize.
+var htmlTags = regexp.MustCompile(`(?s)<[^>]*>|\s+`)
+
+// preflightAuthorize requests the authorization url without following redirects. A valid request is redirected
+// to the login provider or, with an error, back to the redirect url. If the server answers with an error status
+// instead, typically because the redirect url is not registered for the client, the browser shows an error page
+// and the callback is never called.
+func preflightAuthorize(ctx context.Context, location, redirectURL string) error {
+	client, _ := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	if client == nil {
+		client = http.DefaultClient
+	}
+	noRedirects := *client
+	noRedirects.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	res, err := ctxhttp.Get(ctx, &noRedirects, location)
+	if err != nil {
+		// The browser may be able to reach the authorization url even if this host can not.
+		warn("Could not check the authorization url before opening it: %s", err)
+		return nil
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 && res.StatusCode < 400 {
+		target, err := res.Location()
+		if err != nil || strings.HasPrefix(target.String(), redirectURL) {
+			return nil
+		}
+		// Hydra redirects to its error url if it can not redirect to the client.
+		if code := target.Query().Get("error"); code != "" {
+			return describePreflightError(&oauth2Error{StatusCode: res.StatusCode, Code: code, Description: target.Query().Get("error_description")})
+		}
+		return nil
+	}
+	if res.StatusCode < 400 {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<16))
+	e := &oauth2Error{StatusCode: res.StatusCode}
+	if err := json.Unmarshal(body, e); err != nil || e.Code == "" {
+		e.Code = http.StatusText(res.StatusCode)
+		e.Description = strings.TrimSpace(htmlTags.ReplaceAllString(string(body), " "))
+		if len(e.Description) > 200 {
+			e.Description = e.Description[:200] + "..."
+		}
+	}
+	return describePreflightError(e)
+}
+
+func describePreflightError(e *oauth2Error) error {
+	message := "The authorization server rejected the authorization request, the browser will show an error page instead of redirecting back"
+	if strings.Contains(strings.ToLower(e.Code+" "+e.Description), "redirect") {
+		message += ". Make sure that the redirect url is registered for the client or set --redirect"
+	}
+	return errors.Wrap(e, message)
+}
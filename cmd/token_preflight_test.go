@@ -0,0 +1,72 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflightAuthorize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("case") {
+		case "login":
+			http.Redirect(w, r, "/login?login_challenge=foo", http.StatusFound)
+		case "callback":
+			http.Redirect(w, r, "http://127.0.0.1:4445/callback?error=access_denied", http.StatusFound)
+		case "error-url":
+			http.Redirect(w, r, "/error?error=invalid_request&error_description=The+redirect_uri+is+not+whitelisted", http.StatusFound)
+		case "json":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_request","error_description":"redirect_uri does not match"}`))
+		case "html":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`<html><body><h1>Something went wrong</h1></body></html>`))
+		}
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	redirect := "http://127.0.0.1:4445/callback"
+	assert.NoError(t, preflightAuthorize(ctx, ts.URL+"?case=login", redirect))
+	assert.NoError(t, preflightAuthorize(ctx, ts.URL+"?case=callback", redirect))
+
+	err := preflightAuthorize(ctx, ts.URL+"?case=error-url", redirect)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Make sure that the redirect url is registered")
+		assert.Contains(t, err.Error(), "The redirect_uri is not whitelisted")
+	}
+
+	err = preflightAuthorize(ctx, ts.URL+"?case=json", redirect)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid_request (status 400): redirect_uri does not match")
+	}
+
+	err = preflightAuthorize(ctx, ts.URL+"?case=html", redirect)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Internal Server Error (status 500): Something went wrong")
+		assert.NotContains(t, err.Error(), "registered")
+	}
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/oauth2"
+)
+
+// tokenProbeCmd represents the probe command
+var tokenProbeCmd = &cobra.Command{
+	Use:   "probe <resource-url>",
+	Short: "Request a protected resource using a stored access token",
+	Long: `This command sends a GET request to the protected resource using the access token of a token file written
+by "hydra token user --out", or --token, as bearer token and prints the response:
+
+	$ hydra token probe --token-file token.json https://api.example.com/me
+
+Access tokens which expired according to the local clock are not sent unless --ignore-expiry is set, which
+makes the resource server's opinion authoritative. This helps to diagnose clock skew.
+
+` + exitCodesHelp,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return newExitError(exitCodeConfig, errors.New("Expected exactly one argument, the url of the protected resource"))
+		}
+
+		accessToken, _ := cmd.Flags().GetString("token")
+		path, _ := cmd.Flags().GetString("token-file")
+		if accessToken == "" {
+			if path == "" {
+				return newExitError(exitCodeConfig, errors.New("One of the flags --token or --token-file is required"))
+			}
+			stored, err := readTokenFile(path)
+			if err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+			ignoreExpiry, _ := cmd.Flags().GetBool("ignore-expiry")
+			if err := checkStoredTokenExpiry(stored, ignoreExpiry, time.Now()); err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+			accessToken = stored.AccessToken
+		}
+
+		ctx, cancel := commandContext()
+		defer cancel()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, newTokenHTTPClient(cmd))
+
+		follow, _ := cmd.Flags().GetBool("follow-redirects")
+		probe, err := probeResource(ctx, args[0], accessToken, follow)
+		if err != nil {
+			return newContextExitError(ctx, exitCodeProbe, err)
+		}
+		probe.report(os.Stdout)
+		if !probe.succeeded() {
+			return newExitError(exitCodeProbe, errors.Errorf("The resource responded with status %s", probe.Status))
+		}
+		return nil
+	},
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenProbeCmd)
+	tokenProbeCmd.Flags().String("token", "", "The access token, takes precedence over --token-file")
+	tokenProbeCmd.Flags().String("token-file", "", "Read the access token from a token file written by \"hydra token user --out\"")
+	tokenProbeCmd.Flags().Bool("ignore-expiry", false, "Send the access token of --token-file even if it expired according to the local clock")
+	tokenProbeCmd.Flags().Bool("follow-redirects", false, "Follow redirects of the resource instead of reporting the first response")
+}
+
+// maxProbeBodyLength is the maximum length of the response body printed by --probe-resource.
+const maxProbeBodyLength = 500
+
+// probeResult is the response of the protected resource requested by --probe-resource.
+type probeResult struct {
+	URL    string
+	Status string
+	Code   int
+	Body   string
+}
+
+func (p *probeResult) succeeded() bool {
+	return p.Code >= 200 && p.Code <= 299
+}
+
+func (p *probeResult) report(w io.Writer) {
+	fmt.Fprintf(w, "Resource Probe:\n\tGET %s\n\tStatus: %s\n", p.URL, p.Status)
+	if p.Body != "" {
+		fmt.Fprintf(w, "\tBody: %s\n", p.Body)
+	}
+	fmt.Fprintln(w)
+}
+
+// probeResource sends a GET request with the access token as bearer token to endpoint. Redirects are only
+// followed if follow is set, the first response is reported otherwise.
+func probeResource(ctx context.Context, endpoint, accessToken string, follow bool) (*probeResult, error) {
+	client, _ := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	probe := *client
+	if !follow {
+		probe.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := ctxhttp.Do(ctx, &probe, req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not request %s", endpoint)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxProbeBodyLength+utf8.UTFMax))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &probeResult{
+		URL:    res.Request.URL.String(),
+		Status: res.Status,
+		Code:   res.StatusCode,
+		Body:   trimProbeBody(string(body)),
+	}, nil
+}
+
+// trimProbeBody collapses whitespace and cuts the body at maxProbeBodyLength without splitting a character.
+func trimProbeBody(body string) string {
+	body = strings.TrimSpace(whitespacePattern.ReplaceAllString(body, " "))
+	if len(body) <= maxProbeBodyLength {
+		return body
+	}
+
+	cut := maxProbeBodyLength
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return body[:cut] + "..."
+}
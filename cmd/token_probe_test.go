@@ -0,0 +1,95 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeResource(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/me", http.StatusFound)
+		case "/me":
+			if r.Header.Get("Authorization") != "Bearer access-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error": "invalid_token"}`))
+				return
+			}
+			w.Write([]byte("  {\n\t\"sub\": \"peter\"\n}\n"))
+		}
+	}))
+	defer ts.Close()
+
+	probe, err := probeResource(context.Background(), ts.URL+"/me", "access-token", false)
+	require.NoError(t, err)
+	assert.True(t, probe.succeeded())
+	assert.Equal(t, ts.URL+"/me", probe.URL)
+	assert.Equal(t, http.StatusOK, probe.Code)
+	assert.Equal(t, `{ "sub": "peter" }`, probe.Body)
+
+	var out bytes.Buffer
+	probe.report(&out)
+	assert.Equal(t, "Resource Probe:\n\tGET "+ts.URL+"/me\n\tStatus: 200 OK\n\tBody: { \"sub\": \"peter\" }\n\n", out.String())
+
+	probe, err = probeResource(context.Background(), ts.URL+"/me", "other-token", false)
+	require.NoError(t, err)
+	assert.False(t, probe.succeeded())
+	assert.Equal(t, http.StatusUnauthorized, probe.Code)
+	assert.Equal(t, `{"error": "invalid_token"}`, probe.Body)
+
+	probe, err = probeResource(context.Background(), ts.URL+"/redirect", "access-token", false)
+	require.NoError(t, err)
+	assert.False(t, probe.succeeded(), "redirects are reported unless they are followed")
+	assert.Equal(t, http.StatusFound, probe.Code)
+	assert.Equal(t, ts.URL+"/redirect", probe.URL)
+
+	probe, err = probeResource(context.Background(), ts.URL+"/redirect", "access-token", true)
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"/me", probe.URL)
+
+	_, err = probeResource(context.Background(), "http://127.0.0.1:0/me", "access-token", false)
+	assert.Error(t, err)
+}
+
+func TestTrimProbeBody(t *testing.T) {
+	assert.Equal(t, "", trimProbeBody(" \n\t "))
+	assert.Equal(t, "a b c", trimProbeBody(" a\n\tb  c "))
+
+	exact := strings.Repeat("a", maxProbeBodyLength)
+	assert.Equal(t, exact, trimProbeBody(exact))
+	assert.Equal(t, exact+"...", trimProbeBody(exact+"b"))
+
+	// The cut never splits a multi-byte character.
+	trimmed := trimProbeBody("a" + strings.Repeat("é", maxProbeBodyLength))
+	assert.True(t, utf8.ValidString(trimmed))
+	assert.Equal(t, "a"+strings.Repeat("é", (maxProbeBodyLength-2)/2)+"...", trimmed)
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// promptValues are the values of the prompt parameter defined by OpenID Connect Core 1.0 section 3.1.2.1, and
+// "create" of Initiating User Registration via OpenID Connect 1.0.
+var promptValues = map[string]bool{"none": true, "login": true, "consent": true, "select_account": true, "create": true}
+
+// checkPrompt validates the space separated values of --prompt. Unknown values only cause a warning because
+// servers may support custom ones.
+func checkPrompt(prompt string) error {
+	values := strings.Fields(prompt)
+	if len(values) == 0 {
+		return errors.Errorf(`Invalid value "%s" for flag --prompt, expected one or more space separated values`, prompt)
+	}
+
+	for _, value := range values {
+		if value == "none" && len(values) > 1 {
+			return errors.New(`Flag --prompt can not combine "none" with other values`)
+		}
+		if !promptValues[value] {
+			warn(`Unknown value "%s" for flag --prompt, it is sent anyway. Known values are: none, login, consent, select_account, create`, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPrompt(t *testing.T) {
+	for k, tc := range []struct {
+		prompt       string
+		expectErr    string
+		expectWarned bool
+	}{
+		{prompt: "none"},
+		{prompt: "login"},
+		{prompt: "login consent select_account"},
+		{prompt: "  consent \t login "},
+		{prompt: "create"},
+		{prompt: "none login", expectErr: `Flag --prompt can not combine "none" with other values`},
+		{prompt: "consent none", expectErr: `Flag --prompt can not combine "none" with other values`},
+		{prompt: "", expectErr: `Invalid value "" for flag --prompt, expected one or more space separated values`},
+		{prompt: "  ", expectErr: `Invalid value "  " for flag --prompt, expected one or more space separated values`},
+		{prompt: "custom", expectWarned: true},
+		{prompt: "login custom", expectWarned: true},
+	} {
+		warnings := len(emittedWarnings())
+		err := checkPrompt(tc.prompt)
+		if tc.expectErr != "" {
+			assert.EqualError(t, err, tc.expectErr, "case %d", k)
+		} else {
+			assert.NoError(t, err, "case %d", k)
+		}
+
+		if tc.expectWarned {
+			if assert.Len(t, emittedWarnings(), warnings+1, "case %d", k) {
+				assert.Contains(t, emittedWarnings()[warnings], `Unknown value "custom" for flag --prompt`, "case %d", k)
+			}
+		} else {
+			assert.Len(t, emittedWarnings(), warnings, "case %d", k)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// tokenRefreshCmd represents the refresh command
+var tokenRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Refresh a token stored by \"hydra token user --out\"",
+	Long: `This command uses the refresh token of a token file written by "hydra token user --out" to fetch a new
+access token and stores the result in the same file.
+
+Servers which rotate refresh tokens invalidate the old refresh token once it was used. The new refresh token
+is therefore always written back to the token file, using --refresh-rotation-check additionally reports whether
+the refresh token was rotated.
+
+To test the refresh token lifetime and rotation limits of the server, --refresh-until-error refreshes the token
+again and again, always with the latest refresh token, until the server rejects it and reports how many
+refreshes succeeded and how long it took. --max-iterations caps the number of refreshes and --refresh-interval
+waits between them, for example to reach the maximum lifetime of refresh tokens:
+
+	$ hydra token refresh --token-file token.json --refresh-until-error --refresh-interval 1m --max-iterations 120
+
+A rejection is the expected outcome of this mode, the command only fails if it was interrupted by --timeout.
+The server accepting all refreshes is reported as a warning.
+
+--reuse-check tests the refresh token reuse detection of the server: it rotates the refresh token, uses the
+rotated-out refresh token again like an attacker who stole it would, and checks that the server rejects it and
+revokes the whole token family, including the refresh token issued by the rotation. The command exits with
+code 7 if the reuse was not detected. The token written to --token-file is unusable afterwards if the server
+detected the reuse, which is the expected outcome.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext()
+		defer cancel()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, newTokenHTTPClient(cmd))
+
+		path, _ := cmd.Flags().GetString("token-file")
+		clientID, _ := cmd.Flags().GetString("id")
+		clientSecret, _ := cmd.Flags().GetString("secret")
+		authStyle, _ := cmd.Flags().GetString("auth-style")
+		tokenURL, _ := cmd.Flags().GetString("token-url")
+		format, _ := cmd.Flags().GetString("format")
+
+		if err := checkFormatFlag(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if err := checkFieldFlag(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if err := checkAuthStyle(authStyle); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if fields, _ := cmd.Flags().GetStringArray("field"); len(fields) > 0 {
+			defer stdoutToStderr()()
+		}
+		if path == "" {
+			return newExitError(exitCodeConfig, errors.New("Flag --token-file is required"))
+		}
+		if clientID == "" {
+			clientID = c.ClientID
+		}
+		if clientSecret == "" {
+			clientSecret = c.ClientSecret
+		}
+		endpoints, err := resolveClusterEndpoints(ctx, tokenIssuer, c.ClusterURL)
+		if err != nil {
+			return newContextExitError(ctx, exitCodeConfig, err)
+		}
+		if tokenURL == "" {
+			tokenURL = endpoints.Token
+		}
+
+		stored, err := readTokenFile(path)
+		if err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if stored.RefreshToken == "" {
+			return newExitError(exitCodeConfig, errors.Errorf("The token stored in %s has no refresh token", path))
+		}
+
+		if ok, _ := cmd.Flags().GetBool("reuse-check"); ok {
+			if until, _ := cmd.Flags().GetBool("refresh-until-error"); until {
+				return newExitError(exitCodeConfig, errors.New("Flags --reuse-check and --refresh-until-error can not be used together"))
+			}
+			result, err := checkRefreshTokenReuse(ctx, cmd, tokenURL, clientID, clientSecret, authStyle, path, stored)
+			if err != nil {
+				return newContextExitError(ctx, exitCodeExchange, err)
+			}
+			result.report(infoWriter(format))
+			if result.failed() {
+				return newExitError(exitCodeVerification, errors.New("The server did not detect the reuse of the rotated-out refresh token"))
+			}
+			return nil
+		}
+
+		if ok, _ := cmd.Flags().GetBool("refresh-until-error"); ok {
+			if format != "text" && format != "json" {
+				return newExitError(exitCodeConfig, errors.Errorf(`Flag --refresh-until-error only supports --format text and json but got "%s"`, format))
+			}
+			maxIterations, _ := cmd.Flags().GetInt("max-iterations")
+			if maxIterations < 1 {
+				return newExitError(exitCodeConfig, errors.New("Flag --max-iterations must be at least 1"))
+			}
+			interval, _ := cmd.Flags().GetDuration("refresh-interval")
+
+			result, err := refreshUntilError(ctx, tokenURL, clientID, clientSecret, authStyle, path, stored, maxIterations, interval)
+			if err != nil {
+				return err
+			}
+			if format == "json" {
+				printJSON(result)
+			} else {
+				result.report(os.Stdout, maxIterations)
+			}
+			if result.Capped {
+				warn("The server accepted all %d refreshes, increase --max-iterations or --refresh-interval to reach its limits", maxIterations)
+			}
+			if ctx.Err() != nil {
+				return newContextExitError(ctx, exitCodeExchange, errors.Wrap(result.err, "The refreshes were interrupted"))
+			}
+			return nil
+		} else if cmd.Flags().Changed("max-iterations") || cmd.Flags().Changed("refresh-interval") {
+			return newExitError(exitCodeConfig, errors.New("Flags --max-iterations and --refresh-interval require --refresh-until-error"))
+		}
+
+		token, rotated, err := refreshStoredToken(ctx, tokenURL, clientID, clientSecret, authStyle, stored, nil)
+		if err != nil {
+			return newContextExitError(ctx, exitCodeExchange, errors.Wrap(err, "Could not refresh the token"))
+		}
+
+		// The old refresh token must not be used again if it was rotated, so the file is always updated.
+		if err := writeTokenFile(path, token); err != nil {
+			return err
+		}
+
+		if err := printToken(cmd, token); err != nil {
+			return err
+		}
+		if idTokenMissingAfterRefresh(token, stored) {
+			printIDTokenRefreshNote(infoWriter(format), token)
+		}
+		if ok, _ := cmd.Flags().GetBool("refresh-rotation-check"); ok {
+			if rotated {
+				fmt.Fprintf(infoWriter(format), "Refresh Token Rotation:\n\tThe server rotated the refresh token, the new refresh token was stored in %s.\n\n", path)
+			} else {
+				fmt.Fprintf(infoWriter(format), "Refresh Token Rotation:\n\tThe server did not rotate the refresh token, the old refresh token remains valid.\n\n")
+			}
+		}
+		return nil
+	},
+}
+
+// refreshStoredToken performs the refresh token grant. The token request is sent directly instead of
+// using the oauth2 library because the library silently keeps the old refresh token if the response
+// does not contain one, which hides whether the server rotated the refresh token. params are added to the
+// request, for example the audience.
+func refreshStoredToken(ctx context.Context, tokenURL, clientID, clientSecret, authStyle string, stored *tokenOutput, params url.Values) (*oauth2.Token, bool, error) {
+	values := url.Values{}
+	for key, value := range params {
+		values[key] = value
+	}
+	values.Set("grant_type", "refresh_token")
+	values.Set("refresh_token", stored.RefreshToken)
+	token, err := requestToken(ctx, tokenURL, clientID, clientSecret, authStyle, values)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rotated := token.RefreshToken != "" && token.RefreshToken != stored.RefreshToken
+	if token.RefreshToken == "" {
+		token.RefreshToken = stored.RefreshToken
+	}
+
+	extra := map[string]interface{}{}
+	for _, name := range []string{"id_token", "scope"} {
+		if value, ok := token.Extra(name).(string); ok {
+			extra[name] = value
+		}
+	}
+	if _, ok := extra["id_token"]; !ok && stored.IDToken != "" {
+		// Refresh responses may omit the ID token, keep the one from the original flow.
+		extra["id_token"] = stored.IDToken
+	}
+	if _, ok := extra["scope"]; !ok && stored.Scope != "" {
+		extra["scope"] = stored.Scope
+	}
+	return token.WithExtra(extra), rotated, nil
+}
+
+// idTokenMissingAfterRefresh tells if the openid scope was granted but the refresh response contained no new ID
+// token, which OpenID Connect Core 1.0 section 12.2 allows. refreshStoredToken keeps the ID token of stored if
+// the response has none, so an unchanged ID token was not issued again either.
+func idTokenMissingAfterRefresh(token *oauth2.Token, stored *tokenOutput) bool {
+	scope, _ := token.Extra("scope").(string)
+	if !hasScope(scope, "openid") && !hasScope(stored.Scope, "openid") {
+		return false
+	}
+	idt, _ := token.Extra("id_token").(string)
+	return idt == "" || idt == stored.IDToken
+}
+
+// printIDTokenRefreshNote explains the missing ID token instead of printing a blank one.
+func printIDTokenRefreshNote(w io.Writer, token *oauth2.Token) {
+	if idt, _ := token.Extra("id_token").(string); idt != "" {
+		fmt.Fprintln(w, "Note: The server did not issue a new ID token when refreshing, the ID token shown is the one from the original flow.")
+	} else {
+		fmt.Fprintln(w, "Note: The server did not issue a new ID token when refreshing although the openid scope was granted.")
+	}
+	fmt.Fprintln(w)
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenRefreshCmd)
+
+	tokenRefreshCmd.Flags().String("token-file", "", "The token file written by \"hydra token user --out\", it is updated with the refreshed token")
+	tokenRefreshCmd.Flags().String("id", "", "Force a client id, defaults to value from config file")
+	tokenRefreshCmd.Flags().String("secret", "", "Force a client secret, defaults to value from config file")
+	tokenRefreshCmd.Flags().String("auth-style", "header", "Set how client credentials are sent to the token endpoint, one of: header (client_secret_basic), body (client_secret_post)")
+	tokenRefreshCmd.Flags().String("token-url", "", "Force a token url, defaults to /oauth2/token of the cluster url value from config file")
+	tokenRefreshCmd.Flags().String("format", "text", "Set the output format, one of: text, json, env, curl, kubectl. The kubectl format prints an ExecCredential for client-go credential plugins")
+	tokenRefreshCmd.Flags().StringArray("field", []string{}, "Print only this field of the token instead, one per line or as JSON object with --format json, can be repeated. Dotted paths select claims of the ID token or the access token, for example claims.sub or access_token_claims.scp")
+	tokenRefreshCmd.Flags().String("resource-url", "", "The resource url used in the example request printed by --format curl")
+	tokenRefreshCmd.Flags().Bool("refresh-rotation-check", false, "Report whether the server rotated the refresh token")
+	tokenRefreshCmd.Flags().Bool("reuse-check", false, "Use a rotated-out refresh token again and check that the server rejects it and revokes the token family, this revokes the stored token")
+	tokenRefreshCmd.Flags().Bool("refresh-until-error", false, "Refresh the token repeatedly until the server rejects the refresh token and report how many refreshes succeeded")
+	tokenRefreshCmd.Flags().Int("max-iterations", 100, "The maximum number of refreshes of --refresh-until-error")
+	tokenRefreshCmd.Flags().Duration("refresh-interval", 0, "Wait this long between the refreshes of --refresh-until-error, for example 1m")
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// checkRefreshTokenReuse rotates the refresh token of stored once and then replays the rotated-out refresh token,
+// as an attacker who stole it would. Servers implementing refresh token rotation must reject the replayed token
+// and revoke the whole token family, so the refresh token issued by the rotation must stop working as well
+// (OAuth 2.0 Security Best Current Practice section 4.14). The last token the server issued is written to path,
+// it is unusable afterwards if the server detected the reuse.
+func checkRefreshTokenReuse(ctx context.Context, cmd *cobra.Command, tokenURL, clientID, clientSecret, authStyle, path string, stored *tokenOutput) (*selfTest, error) {
+	t := &selfTest{title: "Refresh Token Reuse Detection"}
+
+	token, rotated, err := refreshStoredToken(ctx, tokenURL, clientID, clientSecret, authStyle, stored, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not refresh the token")
+	}
+	if err := writeTokenFile(path, token); err != nil {
+		return nil, err
+	}
+
+	const rejected = "the rotated-out refresh token is rejected"
+	const revoked = "the refresh token issued by the rotation is revoked"
+	if !rotated {
+		t.warn("the server rotates refresh tokens", "the refresh token was not rotated, so using it again is not a breach")
+		t.skip(rejected, "no rotation")
+		t.skip(revoked, "no rotation")
+		return t, nil
+	}
+	t.pass("the server rotates refresh tokens")
+
+	if _, _, err := refreshStoredToken(ctx, tokenURL, clientID, clientSecret, authStyle, stored, nil); err == nil {
+		t.fail(rejected, errors.New("the server issued a new token, refresh token reuse is not detected"))
+	} else if !checkInvalidGrant(t, rejected, err) {
+		return t, nil
+	}
+
+	rotatedOut := newTokenOutput(token)
+	if again, _, err := refreshStoredToken(ctx, tokenURL, clientID, clientSecret, authStyle, rotatedOut, nil); err == nil {
+		t.fail(revoked, errors.New("the server issued a new token, the token family was not revoked"))
+		// Keep the token which is still valid.
+		if err := writeTokenFile(path, again); err != nil {
+			return nil, err
+		}
+	} else {
+		checkInvalidGrant(t, revoked, err)
+	}
+
+	const accessRevoked = "the access token issued by the rotation is revoked"
+	if c.ClientID == "" || c.ClientSecret == "" {
+		t.skip(accessRevoked, "the config file contains no credentials to introspect tokens")
+		return t, nil
+	}
+	switch introspection, err := introspectAccessToken(cmd, token.AccessToken); {
+	case introspection == "":
+		t.skip(accessRevoked, err.Error())
+	case err == nil:
+		t.warn(accessRevoked, "the introspection endpoint reports the access token as active, it remains usable until it expires")
+	default:
+		t.pass(accessRevoked)
+	}
+	return t, nil
+}
+
+// checkInvalidGrant records the rejection of a refresh token. RFC 6749 section 5.2 requires the invalid_grant error
+// code for refresh tokens which are invalid or revoked. It returns false if the request failed for other reasons,
+// in which case the outcome of the remaining checks is meaningless.
+func checkInvalidGrant(t *selfTest, name string, err error) bool {
+	e, ok := errors.Cause(err).(*oauth2Error)
+	if !ok {
+		t.fail(name, errors.Wrap(err, "the token request failed"))
+		return false
+	}
+	if e.Code != "invalid_grant" {
+		t.warn(name, "rejected with "+e.Error()+", expected invalid_grant")
+		return true
+	}
+	t.pass(name)
+	return true
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// refreshStress is the result of --refresh-until-error.
+type refreshStress struct {
+	Refreshes int           `json:"refreshes"`
+	Rotations int           `json:"rotations"`
+	Elapsed   time.Duration `json:"-"`
+	ElapsedMS int64         `json:"elapsed_ms"`
+	Error     string        `json:"error,omitempty"`
+	Capped    bool          `json:"max_iterations_reached"`
+
+	// err is the error which made the server reject the last refresh, or the error of the context.
+	err error
+}
+
+// refreshUntilError refreshes stored again and again until the server rejects the refresh token, at most
+// maxIterations times. The refresh token of the previous response is always used for the next refresh, so that
+// rotation is respected, and every refreshed token is written to path. It waits interval between refreshes, which
+// allows to reach a refresh token max-lifetime without issuing thousands of tokens. The error is only set if the
+// token file could not be written, the rejection of the server is part of the result.
+func refreshUntilError(ctx context.Context, tokenURL, clientID, clientSecret, authStyle, path string, stored *tokenOutput, maxIterations int, interval time.Duration) (*refreshStress, error) {
+	result := new(refreshStress)
+	start := time.Now()
+	defer func() {
+		result.Elapsed = time.Since(start)
+		result.ElapsedMS = int64(result.Elapsed / time.Millisecond)
+		if result.err != nil {
+			result.Error = result.err.Error()
+		}
+	}()
+
+	for i := 0; i < maxIterations; i++ {
+		if i > 0 && interval > 0 {
+			select {
+			case <-ctx.Done():
+				result.err = ctx.Err()
+				return result, nil
+			case <-time.After(interval):
+			}
+		}
+
+		token, rotated, err := refreshStoredToken(ctx, tokenURL, clientID, clientSecret, authStyle, stored, nil)
+		if err != nil {
+			result.err = err
+			if ctx.Err() != nil {
+				result.err = ctx.Err()
+			}
+			return result, nil
+		}
+
+		result.Refreshes++
+		if rotated {
+			result.Rotations++
+		}
+		// The old refresh token must not be used again if it was rotated, so the file is always updated.
+		if err := writeTokenFile(path, token); err != nil {
+			return result, err
+		}
+		stored = newTokenOutput(token)
+	}
+
+	result.Capped = true
+	return result, nil
+}
+
+func (r *refreshStress) report(w io.Writer, maxIterations int) {
+	fmt.Fprintf(w, "Refresh Until Error:\n")
+	fmt.Fprintf(w, "\tSuccessful refreshes: %d\n", r.Refreshes)
+	fmt.Fprintf(w, "\tRotated refresh tokens: %d\n", r.Rotations)
+	fmt.Fprintf(w, "\tElapsed: %s\n", r.Elapsed.Truncate(time.Millisecond))
+	if r.Capped {
+		fmt.Fprintf(w, "\tStopped: the server accepted all %d refreshes of --max-iterations\n\n", maxIterations)
+	} else if r.err == context.Canceled || r.err == context.DeadlineExceeded {
+		fmt.Fprintf(w, "\tStopped: interrupted before refresh %d: %s\n\n", r.Refreshes+1, r.Error)
+	} else {
+		fmt.Fprintf(w, "\tStopped: refresh %d failed: %s\n\n", r.Refreshes+1, r.Error)
+	}
+}
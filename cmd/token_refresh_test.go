@@ -0,0 +1,205 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestRefreshStoredToken(t *testing.T) {
+	var response map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "old-refresh-token", r.PostForm.Get("refresh_token"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer ts.Close()
+
+	stored := &tokenOutput{AccessToken: "old-access-token", RefreshToken: "old-refresh-token", IDToken: "id-token", Scope: "openid offline"}
+
+	for k, tc := range []struct {
+		response      map[string]interface{}
+		expectRotated bool
+		expectRefresh string
+		expectIDToken string
+		expectScope   string
+	}{
+		{
+			response:      map[string]interface{}{"access_token": "new-access-token", "refresh_token": "new-refresh-token", "scope": "openid"},
+			expectRotated: true,
+			expectRefresh: "new-refresh-token",
+			expectIDToken: "id-token",
+			expectScope:   "openid",
+		},
+		{
+			response:      map[string]interface{}{"access_token": "new-access-token"},
+			expectRefresh: "old-refresh-token",
+			expectIDToken: "id-token",
+			expectScope:   "openid offline",
+		},
+		{
+			response:      map[string]interface{}{"access_token": "new-access-token", "refresh_token": "old-refresh-token", "id_token": "new-id-token"},
+			expectRefresh: "old-refresh-token",
+			expectIDToken: "new-id-token",
+			expectScope:   "openid offline",
+		},
+	} {
+		response = tc.response
+		token, rotated, err := refreshStoredToken(context.Background(), ts.URL, "client", "secret", "header", stored, nil)
+		require.NoError(t, err, "case %d", k)
+		assert.Equal(t, tc.expectRotated, rotated, "case %d", k)
+		assert.Equal(t, "new-access-token", token.AccessToken, "case %d", k)
+		assert.Equal(t, tc.expectRefresh, token.RefreshToken, "case %d", k)
+		assert.Equal(t, tc.expectIDToken, token.Extra("id_token"), "case %d", k)
+		assert.Equal(t, tc.expectScope, token.Extra("scope"), "case %d", k)
+	}
+}
+
+func TestIDTokenMissingAfterRefresh(t *testing.T) {
+	stored := &tokenOutput{IDToken: "old-id-token", Scope: "openid offline"}
+	withExtra := func(extra map[string]interface{}) *oauth2.Token {
+		return (&oauth2.Token{AccessToken: "foo"}).WithExtra(extra)
+	}
+
+	assert.True(t, idTokenMissingAfterRefresh(withExtra(map[string]interface{}{}), stored))
+	assert.True(t, idTokenMissingAfterRefresh(withExtra(map[string]interface{}{"id_token": "old-id-token"}), stored))
+	assert.False(t, idTokenMissingAfterRefresh(withExtra(map[string]interface{}{"id_token": "new-id-token"}), stored))
+	assert.False(t, idTokenMissingAfterRefresh(withExtra(map[string]interface{}{"scope": "offline"}), &tokenOutput{Scope: "offline"}))
+}
+
+func TestRefreshUntilError(t *testing.T) {
+	var refreshes int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		// Every refresh must use the refresh token of the previous response.
+		assert.Equal(t, fmt.Sprintf("refresh-%d", refreshes), r.PostForm.Get("refresh_token"))
+		w.Header().Set("Content-Type", "application/json")
+		if refreshes == 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": "invalid_grant"})
+			return
+		}
+		refreshes++
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "access-token", "refresh_token": fmt.Sprintf("refresh-%d", refreshes)})
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "hydra-refresh")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token.json")
+
+	result, err := refreshUntilError(context.Background(), ts.URL, "client", "secret", "header", path, &tokenOutput{RefreshToken: "refresh-0"}, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Refreshes)
+	assert.Equal(t, 3, result.Rotations)
+	assert.False(t, result.Capped)
+	assert.Contains(t, result.Error, "invalid_grant")
+
+	stored, err := readTokenFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "refresh-3", stored.RefreshToken)
+
+	refreshes = 0
+	result, err = refreshUntilError(context.Background(), ts.URL, "client", "secret", "header", path, &tokenOutput{RefreshToken: "refresh-0"}, 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Refreshes)
+	assert.True(t, result.Capped)
+	assert.Empty(t, result.Error)
+}
+
+func TestCheckRefreshTokenReuse(t *testing.T) {
+	// Without credentials in the config file the introspection check is skipped.
+	defer func(id, secret string) { c.ClientID, c.ClientSecret = id, secret }(c.ClientID, c.ClientSecret)
+	c.ClientID, c.ClientSecret = "", ""
+
+	var rotate, detectReuse bool
+	var current int
+	revoked := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		var generation int
+		fmt.Sscanf(r.PostForm.Get("refresh_token"), "refresh-%d", &generation)
+		w.Header().Set("Content-Type", "application/json")
+		if revoked || (detectReuse && generation != current) {
+			revoked = true
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		response := map[string]interface{}{"access_token": fmt.Sprintf("access-%d", generation+1), "expires_in": 3600}
+		if rotate {
+			current = generation + 1
+			response["refresh_token"] = fmt.Sprintf("refresh-%d", current)
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "hydra-reuse-check")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token.json")
+
+	statuses := func(test *selfTest) []string {
+		var out []string
+		for _, check := range test.checks {
+			out = append(out, check.status)
+		}
+		return out
+	}
+
+	for k, tc := range []struct {
+		rotate, detectReuse bool
+		expect              []string
+		failed              bool
+	}{
+		{rotate: true, detectReuse: true, expect: []string{"PASS", "PASS", "PASS", "SKIP"}},
+		{rotate: false, expect: []string{"WARN", "SKIP", "SKIP"}},
+		{rotate: true, detectReuse: false, expect: []string{"PASS", "FAIL", "FAIL", "SKIP"}, failed: true},
+	} {
+		rotate, detectReuse, current, revoked = tc.rotate, tc.detectReuse, 0, false
+		stored := &tokenOutput{AccessToken: "access-0", RefreshToken: "refresh-0"}
+
+		test, err := checkRefreshTokenReuse(context.Background(), nil, ts.URL, "client", "secret", "header", path, stored)
+		require.NoError(t, err, "Case %d", k)
+		assert.Equal(t, tc.expect, statuses(test), "Case %d", k)
+		assert.Equal(t, tc.failed, test.failed(), "Case %d", k)
+
+		written, err := readTokenFile(path)
+		require.NoError(t, err, "Case %d", k)
+		assert.NotEqual(t, "access-0", written.AccessToken, "Case %d", k)
+	}
+}
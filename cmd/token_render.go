@@ -0,0 +1,187 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// TokenRenderer prints a token in one of the formats selected by the --format flag of the token commands.
+type TokenRenderer interface {
+	Render(token *oauth2.Token, extras *TokenExtras, w io.Writer) error
+}
+
+// TokenRendererFunc adapts a function to a TokenRenderer.
+type TokenRendererFunc func(token *oauth2.Token, extras *TokenExtras, w io.Writer) error
+
+// Render calls f.
+func (f TokenRendererFunc) Render(token *oauth2.Token, extras *TokenExtras, w io.Writer) error {
+	return f(token, extras, w)
+}
+
+// TokenExtras is what the token commands know about a token besides the token response itself.
+type TokenExtras struct {
+	// SessionState is the session_state of the authorize response, if any.
+	SessionState string
+
+	// ResourceURL is the value of --resource-url, if any.
+	ResourceURL string
+}
+
+var (
+	tokenRenderersMutex sync.RWMutex
+	tokenRenderers      = map[string]TokenRenderer{
+		"text":    TokenRendererFunc(renderTokenText),
+		"json":    TokenRendererFunc(renderTokenJSON),
+		"env":     TokenRendererFunc(renderTokenEnv),
+		"curl":    TokenRendererFunc(renderTokenCurl),
+		"kubectl": TokenRendererFunc(renderTokenKubectl),
+		// The check result is printed once the command finished, see newNagiosCheck.
+		"nagios": TokenRendererFunc(func(*oauth2.Token, *TokenExtras, io.Writer) error { return nil }),
+	}
+)
+
+// RegisterTokenRenderer makes a renderer available as --format name, replacing any renderer registered with the
+// same name.
+func RegisterTokenRenderer(name string, renderer TokenRenderer) {
+	tokenRenderersMutex.Lock()
+	defer tokenRenderersMutex.Unlock()
+	tokenRenderers[name] = renderer
+}
+
+// tokenRenderer returns the renderer of format, false is returned if no renderer is registered as format.
+func tokenRenderer(format string) (TokenRenderer, bool) {
+	tokenRenderersMutex.RLock()
+	defer tokenRenderersMutex.RUnlock()
+	renderer, ok := tokenRenderers[format]
+	return renderer, ok
+}
+
+// tokenRendererNames returns the sorted names of the registered renderers.
+func tokenRendererNames() []string {
+	tokenRenderersMutex.RLock()
+	defer tokenRenderersMutex.RUnlock()
+	names := make([]string, 0, len(tokenRenderers))
+	for name := range tokenRenderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkFormatFlag validates --format before the token is requested.
+func checkFormatFlag(cmd *cobra.Command) error {
+	format, _ := cmd.Flags().GetString("format")
+	return checkFormat(format)
+}
+
+func checkFormat(format string) error {
+	if _, ok := tokenRenderer(format); !ok {
+		return errors.Errorf(`Unknown value "%s" for flag --format, expected one of: %s`, format, strings.Join(tokenRendererNames(), ", "))
+	}
+	return nil
+}
+
+func newTokenOutputWithExtras(token *oauth2.Token, extras *TokenExtras) *tokenOutput {
+	out := newTokenOutput(token)
+	out.SessionState = extras.SessionState
+	return out
+}
+
+func renderTokenText(token *oauth2.Token, extras *TokenExtras, w io.Writer) error {
+	out := newTokenOutputWithExtras(token, extras)
+	fmt.Fprintf(w, "Access Token:\n\t%s\n", out.AccessToken)
+	fmt.Fprintf(w, "Access Token Format:\n\t%s\n", out.AccessTokenFormat)
+	fmt.Fprintf(w, "Refresh Token:\n\t%s\n\n", out.RefreshToken)
+	fmt.Fprintf(w, "Expires in:\n\t%s\n\n", out.Expiry)
+	if out.IDToken != "" {
+		fmt.Fprintf(w, "ID Token:\n\t%s\n\n", out.IDToken)
+	}
+	if out.SessionState != "" {
+		fmt.Fprintf(w, "Session State:\n\t%s\n\n", out.SessionState)
+	}
+	return nil
+}
+
+func renderTokenJSON(token *oauth2.Token, extras *TokenExtras, w io.Writer) error {
+	return writeJSON(w, newTokenOutputWithExtras(token, extras))
+}
+
+// renderTokenEnv prints the token as shell variable assignments, for example for eval "$(hydra token user --format env)".
+func renderTokenEnv(token *oauth2.Token, extras *TokenExtras, w io.Writer) error {
+	out := newTokenOutputWithExtras(token, extras)
+	for _, v := range []struct{ name, value string }{
+		{"ACCESS_TOKEN", out.AccessToken},
+		{"REFRESH_TOKEN", out.RefreshToken},
+		{"ID_TOKEN", out.IDToken},
+		{"TOKEN_TYPE", out.TokenType},
+		{"TOKEN_SCOPE", out.Scope},
+		{"SESSION_STATE", out.SessionState},
+	} {
+		if v.value != "" {
+			fmt.Fprintf(w, "export %s=%s\n", v.name, shellQuote(v.value))
+		}
+	}
+	if !out.Expiry.IsZero() {
+		fmt.Fprintf(w, "export TOKEN_EXPIRY=%d\n", out.Expiry.Unix())
+	}
+	return nil
+}
+
+func renderTokenCurl(token *oauth2.Token, extras *TokenExtras, w io.Writer) error {
+	resourceURL := extras.ResourceURL
+	if resourceURL == "" {
+		resourceURL = "<resource-url>"
+	}
+	fmt.Fprintf(w, "curl -H \"Authorization: Bearer %s\" %s\n", token.AccessToken, resourceURL)
+	return nil
+}
+
+func renderTokenKubectl(token *oauth2.Token, extras *TokenExtras, w io.Writer) error {
+	credential, err := newExecCredential(newTokenOutputWithExtras(token, extras))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, credential)
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
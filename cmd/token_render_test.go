@@ -0,0 +1,129 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenRenderers(t *testing.T) {
+	token := (&oauth2.Token{AccessToken: "access", RefreshToken: "it's", Expiry: time.Unix(1500000000, 0)}).
+		WithExtra(map[string]interface{}{"scope": "openid offline"})
+
+	for k, tc := range []struct {
+		format string
+		extras TokenExtras
+		expect string
+	}{
+		{
+			format: "env",
+			extras: TokenExtras{SessionState: "abc"},
+			expect: "export ACCESS_TOKEN='access'\nexport REFRESH_TOKEN='it'\\''s'\nexport TOKEN_SCOPE='openid offline'\nexport SESSION_STATE='abc'\nexport TOKEN_EXPIRY=1500000000\n",
+		},
+		{
+			format: "curl",
+			extras: TokenExtras{ResourceURL: "https://api.example.com"},
+			expect: "curl -H \"Authorization: Bearer access\" https://api.example.com\n",
+		},
+		{
+			format: "curl",
+			expect: "curl -H \"Authorization: Bearer access\" <resource-url>\n",
+		},
+		{format: "nagios", expect: ""},
+	} {
+		var buf bytes.Buffer
+		renderer, ok := tokenRenderer(tc.format)
+		require.True(t, ok, "case %d", k)
+		require.NoError(t, renderer.Render(token, &tc.extras, &buf), "case %d", k)
+		assert.Equal(t, tc.expect, buf.String(), "case %d", k)
+	}
+
+	var buf bytes.Buffer
+	renderer, _ := tokenRenderer("json")
+	require.NoError(t, renderer.Render(token, &TokenExtras{}, &buf))
+	assert.Contains(t, buf.String(), `"access_token_format": "opaque"`)
+
+	renderer, _ = tokenRenderer("kubectl")
+	assert.Error(t, renderer.Render(token, &TokenExtras{}, &buf), "the token has no ID token")
+}
+
+func TestRegisterTokenRenderer(t *testing.T) {
+	RegisterTokenRenderer("test", TokenRendererFunc(func(token *oauth2.Token, _ *TokenExtras, w io.Writer) error {
+		_, err := io.WriteString(w, token.AccessToken)
+		return err
+	}))
+
+	var buf bytes.Buffer
+	renderer, ok := tokenRenderer("test")
+	require.True(t, ok)
+	require.NoError(t, renderer.Render(&oauth2.Token{AccessToken: "access"}, &TokenExtras{}, &buf))
+	assert.Equal(t, "access", buf.String())
+
+	_, ok = tokenRenderer("unknown")
+	assert.False(t, ok)
+}
+
+func TestRenderTokenFields(t *testing.T) {
+	idToken := unsignedJWT(t, map[string]interface{}{"alg": "RS256"}, map[string]interface{}{"sub": "user", "address": map[string]interface{}{"country": "DE"}, "amr": []string{"pwd"}})
+	token := (&oauth2.Token{AccessToken: "opaque", RefreshToken: "refresh"}).WithExtra(map[string]interface{}{"id_token": idToken, "scope": "openid"})
+
+	var buf bytes.Buffer
+	require.NoError(t, renderTokenFields(&buf, "text", []string{"refresh_token", "claims.sub", "claims.missing", "claims.address.country", "claims.amr", "access_token_claims.scp", "expiry"}, token, &TokenExtras{}))
+	assert.Equal(t, "refresh\nuser\n\nDE\n[\"pwd\"]\n\n\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, renderTokenFields(&buf, "json", []string{"scope", "claims.sub", "session_state"}, token, &TokenExtras{}))
+	assert.JSONEq(t, `{"scope": "openid", "claims.sub": "user", "session_state": null}`, buf.String())
+
+	assert.Error(t, renderTokenFields(&buf, "text", []string{"access-token"}, token, &TokenExtras{}))
+	assert.NoError(t, checkTokenFields([]string{"access_token", "claims", "claims.sub"}))
+	assert.Error(t, checkTokenFields([]string{"claim.sub"}))
+}
+
+func TestCheckFormatFlag(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("format", "text", "")
+	require.NoError(t, checkFormatFlag(cmd))
+
+	require.NoError(t, cmd.Flags().Set("format", "yaml"))
+	err := checkFormatFlag(cmd)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `Unknown value "yaml" for flag --format, expected one of: `)
+	assert.Contains(t, err.Error(), "json")
+
+	// The format is rejected before the token is requested.
+	defer tokenExchangeCmd.Flags().Set("format", "text")
+	RootCmd.SetArgs([]string{"token", "exchange", "--format", "yaml", "--subject-token", "token", "--token-url", "http://127.0.0.1:1/oauth2/token"})
+	err = RootCmd.Execute()
+	require.Error(t, err)
+	exit, ok := errors.Cause(err).(*exitError)
+	require.True(t, ok, "%+v", err)
+	assert.Equal(t, exitCodeConfig, exit.code)
+}
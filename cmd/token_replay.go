@@ -0,0 +1,175 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"container/list"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// replayStoreSize bounds the number of token identifiers remembered by tokenReplayStore.
+const replayStoreSize = 1024
+
+var (
+	// tokenReplayStore remembers the "jti" and "nonce" values of the verified tokens. The token commands persist
+	// it in replayCacheFile() because every run usually verifies only one token.
+	tokenReplayStore = newReplayStore(replayStoreSize)
+
+	// replayCachePath is the file set by --replay-cache.
+	replayCachePath string
+)
+
+// replayCacheFile returns the file set by --replay-cache, or replay.json in the hydra directory of the user cache
+// directory. It is empty if neither is available, the identifiers are then only remembered by this process.
+func replayCacheFile() string {
+	if replayCachePath != "" {
+		return replayCachePath
+	}
+	dir := userCacheDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "hydra", "replay.json")
+}
+
+// replayStore is an LRU set of token identifiers. A token which is verified twice with the same identifier
+// has been replayed, because a well behaved server issues every token with a fresh "jti" and every
+// authorization request uses a fresh nonce.
+type replayStore struct {
+	sync.Mutex
+	size int
+
+	// path is the file the identifiers are persisted in, they are only kept in memory if it is empty.
+	path string
+
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newReplayStore(size int) *replayStore {
+	return &replayStore{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+// seen records key and tells if it was already recorded, by this process or, if path is set, by a previous run.
+// The least recently seen key is evicted once the store is full.
+func (s *replayStore) seen(key string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.path != "" {
+		if err := s.load(); err != nil {
+			warn("Ignoring the replay cache: %s", err)
+		}
+	}
+
+	seen := s.record(key)
+	if s.path != "" {
+		if err := s.save(); err != nil {
+			warn("Could not update the replay cache: %s", err)
+		}
+	}
+	return seen
+}
+
+func (s *replayStore) record(key string) bool {
+	if e, ok := s.items[key]; ok {
+		s.order.MoveToFront(e)
+		return true
+	}
+
+	s.items[key] = s.order.PushFront(key)
+	if s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(string))
+	}
+	return false
+}
+
+// load adds the keys of the replay cache file which are not in the store yet, so that the keys recorded by
+// concurrent runs are kept too. The file lists the keys from the most to the least recently seen one, a missing
+// file is an empty cache.
+func (s *replayStore) load() error {
+	raw, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "could not read replay cache %s", s.path)
+	}
+
+	var keys []string
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return errors.Wrapf(err, "could not parse replay cache %s", s.path)
+	}
+	for _, key := range keys {
+		if _, ok := s.items[key]; !ok && s.order.Len() < s.size {
+			s.items[key] = s.order.PushBack(key)
+		}
+	}
+	return nil
+}
+
+// save replaces the replay cache file atomically so that concurrent runs never read a partially written file.
+func (s *replayStore) save() error {
+	keys := make([]string, 0, s.order.Len())
+	for e := s.order.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(string))
+	}
+	out, err := json.MarshalIndent(keys, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "could not write replay cache %s", s.path)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), s.path))
+}
+
+// warnReplay warns if the "jti" of claims, or nonce if it is not empty, was already seen by tokenReplayStore.
+func warnReplay(kind string, claims map[string]interface{}, nonce string) {
+	iss, _ := claims["iss"].(string)
+	if jti, ok := claims["jti"].(string); ok && jti != "" && tokenReplayStore.seen("jti "+iss+" "+jti) {
+		warn("The %s has the jti %s of a previously verified token, it may have been replayed", kind, jti)
+	}
+	if nonce != "" && tokenReplayStore.seen("nonce "+nonce) {
+		warn("The %s has the nonce %s of a previously verified token, it may have been replayed", kind, nonce)
+	}
+}
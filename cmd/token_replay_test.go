@@ -0,0 +1,81 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/square/go-jose"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayStore(t *testing.T) {
+	s := newReplayStore(2)
+
+	assert.False(t, s.seen("a"))
+	assert.False(t, s.seen("b"))
+	assert.True(t, s.seen("a"))
+
+	// "b" is the least recently seen key and is evicted.
+	assert.False(t, s.seen("c"))
+	assert.True(t, s.seen("a"))
+	assert.False(t, s.seen("b"))
+}
+
+func TestReplayAcrossRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hydra-replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, public := newTestJWK(t, "a")
+	jwksFile := filepath.Join(dir, "jwks.json")
+	raw, err := json.Marshal(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{public}})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(jwksFile, raw, 0600))
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: &jose.JSONWebKey{Key: key, KeyID: "a"}}, nil)
+	require.NoError(t, err)
+	signed, err := signer.Sign([]byte(`{"iss":"https://hydra.localhost/","sub":"foo","jti":"replayed"}`))
+	require.NoError(t, err)
+	token, err := signed.CompactSerialize()
+	require.NoError(t, err)
+
+	defer func(store *replayStore, path string) { tokenReplayStore, replayCachePath = store, path }(tokenReplayStore, replayCachePath)
+	decode := func() {
+		// Every run starts with an empty store, as a new process does.
+		tokenReplayStore = newReplayStore(replayStoreSize)
+		RootCmd.SetArgs([]string{"token", "decode", "--token", token, "--jwks-file", jwksFile, "--replay-cache", filepath.Join(dir, "replay.json")})
+		require.NoError(t, RootCmd.Execute())
+	}
+
+	warnings := len(emittedWarnings())
+	decode()
+	assert.Len(t, emittedWarnings(), warnings, "the first run has not seen the jti yet")
+
+	decode()
+	require.Len(t, emittedWarnings(), warnings+1, "the second run finds the jti in the replay cache")
+	assert.Contains(t, emittedWarnings()[warnings], "jti replayed")
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/oauth2"
+)
+
+// oauth2Error is an error response as defined in RFC 6749 section 5.2.
+type oauth2Error struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+}
+
+func (e *oauth2Error) Error() string {
+	message := fmt.Sprintf("%s (status %d)", e.Code, e.StatusCode)
+	if e.Description != "" {
+		message += ": " + e.Description
+	}
+	if e.URI != "" {
+		message += fmt.Sprintf(" (see %s)", e.URI)
+	}
+	return message
+}
+
+// postForm sends a form-encoded POST request with client authentication to an OAuth 2.0 endpoint and decodes
+// the JSON response into v. It is used for requests the oauth2 library does not support, for example the device
+// authorization request or custom grant types. authStyle is the value of --auth-style: the client secret is sent
+// using HTTP Basic Authorization unless it is "body", which sends it in the request body (client_secret_post).
+func postForm(ctx context.Context, endpoint, clientID, clientSecret, authStyle string, values url.Values, v interface{}) error {
+	client, _ := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if authStyle == "body" && clientSecret != "" {
+		values.Set("client_secret", clientSecret)
+		clientSecret = ""
+	}
+	if clientSecret == "" {
+		values.Set("client_id", clientID)
+	}
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	res, err := ctxhttp.Do(ctx, client, req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := &oauth2Error{StatusCode: res.StatusCode}
+		if err := json.Unmarshal(body, e); err != nil || e.Code == "" {
+			return errors.New(describeTokenError(errors.Errorf("oauth2: cannot fetch token: %s\nResponse: %s", res.Status, body)))
+		}
+		return e
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.Wrapf(err, "could not decode response from %s", endpoint)
+	}
+	return nil
+}
+
+// requestToken performs a token request and returns the result as an *oauth2.Token including all extra fields.
+func requestToken(ctx context.Context, tokenURL, clientID, clientSecret, authStyle string, values url.Values) (*oauth2.Token, error) {
+	var raw map[string]interface{}
+	if err := postForm(ctx, tokenURL, clientID, clientSecret, authStyle, values, &raw); err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{}
+	token.AccessToken, _ = raw["access_token"].(string)
+	token.TokenType, _ = raw["token_type"].(string)
+	token.RefreshToken, _ = raw["refresh_token"].(string)
+	if expiresIn, ok := raw["expires_in"].(float64); ok && expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("the token endpoint did not return an access token")
+	}
+	return token.WithExtra(raw), nil
+}
+
+// checkAuthStyle validates --auth-style of the commands which only support client secrets.
+func checkAuthStyle(authStyle string) error {
+	if authStyle != "header" && authStyle != "body" {
+		return errors.Errorf(`Unknown value "%s" for flag --auth-style, expected one of: header, body`, authStyle)
+	}
+	return nil
+}
+
+// getJSON fetches a JSON document, for example a JSON Web Key Set, using the HTTP client stored in the context.
+func getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	client, _ := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := ctxhttp.Get(ctx, client, endpoint)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("expected status code %d from %s but got %d", http.StatusOK, endpoint, res.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v); err != nil {
+		return errors.Wrapf(err, "could not decode response from %s", endpoint)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ory/hydra/pkg"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// requestConfigAnnotation marks the flags whose value was set by --request-config.
+const requestConfigAnnotation = "request-config"
+
+// requestConfig describes an authorize and token request in a file read by --request-config, which makes complex
+// test cases reproducible. Every value is optional.
+type requestConfig struct {
+	Scopes        []string          `json:"scopes"`
+	Audience      []string          `json:"audience"`
+	Resource      []string          `json:"resource"`
+	RedirectURI   string            `json:"redirect_uri"`
+	LoginHint     string            `json:"login_hint"`
+	Display       string            `json:"display"`
+	ClaimsLocales string            `json:"claims_locales"`
+	MaxAge        *int              `json:"max_age"`
+	Prompt        string            `json:"prompt"`
+	ACRValues     []string          `json:"acr_values"`
+	Claims        json.RawMessage   `json:"claims"`
+	AuthParams    map[string]string `json:"auth_params"`
+	TokenParams   map[string]string `json:"token_params"`
+}
+
+// readRequestConfig reads the file of --request-config.
+func readRequestConfig(path string) (*requestConfig, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var config requestConfig
+	if err := json.Unmarshal(body, &config); err != nil {
+		return nil, errors.Wrapf(err, "could not decode request config %s", path)
+	}
+	return &config, nil
+}
+
+// apply sets the flags of cmd from the request config. Flags given on the command line win over the file,
+// including authorization and token parameters given by --auth-param and --token-param. The flags set from the file
+// are not marked as changed, so that they are not mistaken for flags given on the command line, use
+// setByRequestConfig instead.
+func (r *requestConfig) apply(cmd *cobra.Command) error {
+	flags := map[string]string{
+		"audience":       strings.Join(r.Audience, ","),
+		"resource":       strings.Join(r.Resource, ","),
+		"redirect":       r.RedirectURI,
+		"login-hint":     r.LoginHint,
+		"display":        r.Display,
+		"claims-locales": r.ClaimsLocales,
+	}
+	if !cmd.Flags().Changed("scope") {
+		flags["scopes"] = strings.Join(r.Scopes, ",")
+	}
+	if r.MaxAge != nil {
+		flags["max-age"] = strconv.Itoa(*r.MaxAge)
+	}
+	for name, value := range flags {
+		if value == "" || cmd.Flags().Changed(name) {
+			continue
+		}
+		if err := setRequestConfigFlag(cmd, name, value); err != nil {
+			return err
+		}
+	}
+
+	authParams := map[string]string{}
+	for key, value := range r.AuthParams {
+		authParams[key] = value
+	}
+	if r.Prompt != "" && !cmd.Flags().Changed("prompt") {
+		authParams["prompt"] = r.Prompt
+	}
+	if len(r.ACRValues) > 0 {
+		authParams["acr_values"] = strings.Join(r.ACRValues, " ")
+	}
+	if len(r.Claims) > 0 && string(r.Claims) != "null" {
+		authParams["claims"] = string(r.Claims)
+	}
+
+	if err := addParamFlags(cmd, "auth-param", authParams); err != nil {
+		return err
+	}
+	return addParamFlags(cmd, "token-param", r.TokenParams)
+}
+
+// addParamFlags adds key=value to the string array flag name for every param whose key the flag does not
+// already contain.
+func addParamFlags(cmd *cobra.Command, name string, params map[string]string) error {
+	pairs, _ := cmd.Flags().GetStringArray(name)
+	given, err := pkg.ParseFormParams(pairs)
+	if err != nil {
+		return errors.Wrapf(err, "invalid value for flag --%s", name)
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := given[key]; ok {
+			continue
+		}
+		// Setting a string array flag appends to the values given on the command line.
+		if err := setRequestConfigFlag(cmd, name, key+"="+params[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setRequestConfigFlag sets the value of the flag name without marking it as changed and annotates it with
+// requestConfigAnnotation.
+func setRequestConfigFlag(cmd *cobra.Command, name, value string) error {
+	flag := cmd.Flags().Lookup(name)
+	if flag == nil {
+		return errors.Errorf("unknown flag --%s", name)
+	}
+	if err := flag.Value.Set(value); err != nil {
+		return errors.Wrapf(err, "invalid value for flag --%s", name)
+	}
+	return cmd.Flags().SetAnnotation(name, requestConfigAnnotation, []string{"true"})
+}
+
+// setByRequestConfig tells if the value of the flag name was set by --request-config.
+func setByRequestConfig(cmd *cobra.Command, name string) bool {
+	flag := cmd.Flags().Lookup(name)
+	return flag != nil && len(flag.Annotations[requestConfigAnnotation]) > 0
+}
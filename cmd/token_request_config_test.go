@@ -0,0 +1,83 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestConfigApply(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().StringSlice("scopes", []string{"hydra"}, "")
+	cmd.Flags().StringArray("scope", []string{}, "")
+	cmd.Flags().StringSlice("audience", []string{}, "")
+	cmd.Flags().StringSlice("resource", []string{}, "")
+	cmd.Flags().String("redirect", "http://localhost:4445/callback", "")
+	cmd.Flags().String("login-hint", "", "")
+	cmd.Flags().String("display", "", "")
+	cmd.Flags().String("claims-locales", "", "")
+	cmd.Flags().Int("max-age", -1, "")
+	cmd.Flags().StringArray("auth-param", []string{}, "")
+	cmd.Flags().StringArray("token-param", []string{}, "")
+	require.NoError(t, cmd.Flags().Parse([]string{"--login-hint", "flag", "--auth-param", "prompt=none"}))
+
+	var config requestConfig
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"scopes": ["openid", "offline"],
+		"audience": ["a", "b"],
+		"login_hint": "file",
+		"max_age": 0,
+		"prompt": "login",
+		"acr_values": ["silver", "gold"],
+		"claims": {"id_token": {"email": null}},
+		"token_params": {"tenant": "x"}
+	}`), &config))
+	require.NoError(t, config.apply(cmd))
+
+	scopes, _ := cmd.Flags().GetStringSlice("scopes")
+	assert.Equal(t, []string{"openid", "offline"}, scopes)
+	audience, _ := cmd.Flags().GetStringSlice("audience")
+	assert.Equal(t, []string{"a", "b"}, audience)
+	hint, _ := cmd.Flags().GetString("login-hint")
+	assert.Equal(t, "flag", hint)
+	maxAge, _ := cmd.Flags().GetInt("max-age")
+	assert.Equal(t, 0, maxAge)
+	redirect, _ := cmd.Flags().GetString("redirect")
+	assert.Equal(t, "http://localhost:4445/callback", redirect)
+
+	authParams, _ := cmd.Flags().GetStringArray("auth-param")
+	assert.Equal(t, []string{"prompt=none", "acr_values=silver gold", `claims={"id_token": {"email": null}}`}, authParams)
+	tokenParams, _ := cmd.Flags().GetStringArray("token-param")
+	assert.Equal(t, []string{"tenant=x"}, tokenParams)
+
+	for _, name := range []string{"scopes", "audience", "max-age", "token-param"} {
+		assert.False(t, cmd.Flags().Changed(name), "--%s", name)
+		assert.True(t, setByRequestConfig(cmd, name), "--%s", name)
+	}
+	assert.True(t, cmd.Flags().Changed("login-hint"))
+	assert.False(t, setByRequestConfig(cmd, "login-hint"))
+	assert.False(t, setByRequestConfig(cmd, "redirect"))
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+	"github.com/square/go-jose"
+)
+
+// loadSigningKey reads a PEM encoded RSA or ECDSA private key, such as the one printed by
+// `hydra token gen-key`, and picks the matching JWS algorithm.
+func loadSigningKey(path string) (interface{}, jose.SignatureAlgorithm, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, "", errors.Errorf("file %s does not contain a PEM encoded key", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, "", errors.Errorf("unsupported PEM block type %s", block.Type)
+	}
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, jose.RS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return k, jose.ES256, nil
+		case elliptic.P384():
+			return k, jose.ES384, nil
+		case elliptic.P521():
+			return k, jose.ES512, nil
+		}
+	}
+	return nil, "", errors.Errorf("unsupported private key type %T", key)
+}
+
+// signRequestObject builds a request object (RFC 9101) from the query parameters of the authorization url
+// and signs it using the key id kid. The issuer is the client and the audience is the authorization server.
+func signRequestObject(location, audience, keyPath, kid string) (string, error) {
+	key, alg, err := loadSigningKey(keyPath)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	now := time.Now().UTC()
+	claims := map[string]interface{}{}
+	for name, values := range u.Query() {
+		claims[name] = strings.Join(values, " ")
+	}
+	claims["iss"] = claims["client_id"]
+	claims["aud"] = audience
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	claims["jti"] = uuid.New()
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: &jose.JSONWebKey{Key: key, KeyID: kid}}, (&jose.SignerOptions{}).WithType("oauth-authz-req+jwt"))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return signed.CompactSerialize()
+}
+
+// issuerFromAuthURL derives the issuer of a Hydra cluster from its authorization endpoint.
+func issuerFromAuthURL(authURL string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(authURL, "/oauth2/auth"), "/")
+}
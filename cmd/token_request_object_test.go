@@ -0,0 +1,68 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ory/hydra/jwk"
+	"github.com/square/go-jose"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignRequestObject(t *testing.T) {
+	keys, err := (&jwk.ECDSA256Generator{}).Generate("foo")
+	require.NoError(t, err)
+	private, err := jwk.FindKeyByPrefix(keys, "private")
+	require.NoError(t, err)
+	public, err := jwk.FindKeyByPrefix(keys, "public")
+	require.NoError(t, err)
+	block, err := jwk.PEMBlockForKey(private.Key)
+	require.NoError(t, err)
+
+	f, err := ioutil.TempFile("", "request-object")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	require.NoError(t, pem.Encode(f, block))
+	require.NoError(t, f.Close())
+
+	request, err := signRequestObject("http://hydra/oauth2/auth?client_id=app&scope=openid+offline&state=abc", "http://hydra", f.Name(), "foo")
+	require.NoError(t, err)
+
+	signed, err := jose.ParseSigned(request)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", signed.Signatures[0].Header.KeyID)
+	payload, err := signed.Verify(public.Key)
+	require.NoError(t, err)
+
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	assert.Equal(t, "app", claims["iss"])
+	assert.Equal(t, "app", claims["client_id"])
+	assert.Equal(t, "http://hydra", claims["aud"])
+	assert.Equal(t, "openid offline", claims["scope"])
+	assert.Equal(t, "abc", claims["state"])
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestTokenAuthStyle(t *testing.T) {
+	var received *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		received = r
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "access-token", "expires_in": 3600})
+	}))
+	defer ts.Close()
+
+	for k, tc := range []struct {
+		authStyle    string
+		clientSecret string
+		expectBasic  bool
+		expectSecret string
+	}{
+		{authStyle: "header", clientSecret: "secret", expectBasic: true},
+		{authStyle: "body", clientSecret: "secret", expectSecret: "secret"},
+		// Public clients only send their id.
+		{authStyle: "body"},
+		{authStyle: "header"},
+	} {
+		token, err := requestToken(context.Background(), ts.URL, "client", tc.clientSecret, tc.authStyle, url.Values{"grant_type": {"client_credentials"}})
+		require.NoError(t, err, "case %d", k)
+		assert.Equal(t, "access-token", token.AccessToken, "case %d", k)
+
+		id, secret, ok := received.BasicAuth()
+		assert.Equal(t, tc.expectBasic, ok, "case %d", k)
+		if tc.expectBasic {
+			assert.Equal(t, "client", id, "case %d", k)
+			assert.Equal(t, "secret", secret, "case %d", k)
+			assert.Empty(t, received.PostForm.Get("client_id"), "case %d", k)
+		} else {
+			assert.Empty(t, received.Header.Get("Authorization"), "case %d", k)
+			assert.Equal(t, "client", received.PostForm.Get("client_id"), "case %d", k)
+		}
+		assert.Equal(t, tc.expectSecret, received.PostForm.Get("client_secret"), "case %d", k)
+	}
+}
+
+func TestCheckAuthStyle(t *testing.T) {
+	assert.NoError(t, checkAuthStyle("header"))
+	assert.NoError(t, checkAuthStyle("body"))
+	assert.EqualError(t, checkAuthStyle("private_key_jwt"), `Unknown value "private_key_jwt" for flag --auth-style, expected one of: header, body`)
+}
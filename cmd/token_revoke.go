@@ -29,6 +29,8 @@ var tokenRevokeCmd = &cobra.Command{
 	Use:   "revoke <token>",
 	Short: "Revoke an access or refresh token",
 	Run:   cmdHandler.Token.RevokeToken,
+
+	Annotations: map[string]string{watchdogAnnotation: "true"},
 }
 
 func init() {
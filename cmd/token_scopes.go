@@ -0,0 +1,273 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// requestedScopes merges the values of --scopes and the repeatable --scope flag. The default value of --scopes,
+// or default_scopes from the config file if set, is only used if neither flag was set explicitly or by
+// --request-config.
+func requestedScopes(cmd *cobra.Command) []string {
+	scopes, _ := cmd.Flags().GetStringSlice("scopes")
+	explicit := cmd.Flags().Changed("scopes") || setByRequestConfig(cmd, "scopes")
+	if !explicit && len(c.DefaultScopes) > 0 {
+		scopes = c.DefaultScopes
+	}
+	single, _ := cmd.Flags().GetStringArray("scope")
+	if len(single) > 0 && !explicit {
+		scopes = nil
+	}
+
+	return mergeScopes(scopes, single)
+}
+
+// scopesFromTokenFile returns the scopes granted to a token stored by --out.
+func scopesFromTokenFile(path string) ([]string, error) {
+	stored, err := readTokenFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read token file %s", path)
+	}
+
+	scopes := strings.Fields(stored.Scope)
+	if len(scopes) == 0 {
+		return nil, errors.Errorf("the token stored in %s contains no granted scopes", path)
+	}
+	return scopes, nil
+}
+
+// mergeScopes joins lists of scopes, removing empty and duplicate values while preserving the order.
+// Values may contain several space-separated scopes.
+func mergeScopes(lists ...[]string) []string {
+	var (
+		merged = []string{}
+		seen   = map[string]bool{}
+	)
+
+	for _, list := range lists {
+		for _, value := range list {
+			for _, scope := range strings.Fields(value) {
+				if seen[scope] {
+					continue
+				}
+				seen[scope] = true
+				merged = append(merged, scope)
+			}
+		}
+	}
+	return merged
+}
+
+// compareScopes returns the requested scopes which were not granted and the granted scopes which were not
+// requested.
+func compareScopes(requested, granted []string) (missing, extra []string) {
+	inRequested, inGranted := map[string]bool{}, map[string]bool{}
+	for _, scope := range requested {
+		inRequested[scope] = true
+	}
+	for _, scope := range granted {
+		inGranted[scope] = true
+	}
+
+	for _, scope := range requested {
+		if !inGranted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	for _, scope := range granted {
+		if !inRequested[scope] {
+			extra = append(extra, scope)
+		}
+	}
+	return missing, extra
+}
+
+// checkGrantedScopes warns about requested scopes which were not granted and enforces --assert-scopes,
+// which is either "contains" or "exact". If the token response has no scope parameter, the requested
+// scopes were granted as defined in RFC 6749 section 5.1.
+func checkGrantedScopes(requested []string, token *oauth2.Token, assert string) error {
+	scope, ok := token.Extra("scope").(string)
+	if !ok {
+		return nil
+	}
+
+	missing, extra := compareScopes(requested, strings.Fields(scope))
+	if len(missing) > 0 {
+		warn("The following requested scopes were not granted: %s", strings.Join(missing, ", "))
+	}
+
+	switch assert {
+	case "":
+		return nil
+	case "contains":
+		if len(missing) > 0 {
+			return newExitError(exitCodeScopes, errors.Errorf("The granted scopes are missing: %s", strings.Join(missing, ", ")))
+		}
+	case "exact":
+		if len(missing) > 0 || len(extra) > 0 {
+			return newExitError(exitCodeScopes, errors.Errorf("The granted scopes do not match the requested scopes, missing: [%s], extra: [%s]", strings.Join(missing, ", "), strings.Join(extra, ", ")))
+		}
+	}
+	return nil
+}
+
+// hasScope tells if the space-separated list of scopes contains scope.
+func hasScope(scopes, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hasOfflineScope(scopes []string) bool {
+	for _, scope := range scopes {
+		if scope == "offline" || scope == "offline_access" {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceOfflineScope replaces "offline" and "offline_access" with the scope which is used by the server to
+// issue refresh tokens.
+func replaceOfflineScope(scopes []string, offline string) []string {
+	replaced := make([]string, len(scopes))
+	for k, scope := range scopes {
+		if scope == "offline" || scope == "offline_access" {
+			scope = offline
+		}
+		replaced[k] = scope
+	}
+	return mergeScopes(replaced)
+}
+
+// normalizeOfflineScope picks "offline" or "offline_access", whichever the server lists in scopes_supported.
+// A warning is returned if the scopes were changed or the server supports neither of them.
+func normalizeOfflineScope(scopes, supported []string) ([]string, string) {
+	if !hasOfflineScope(scopes) || len(supported) == 0 {
+		return scopes, ""
+	}
+
+	available := map[string]bool{}
+	for _, scope := range supported {
+		available[scope] = true
+	}
+
+	for _, scope := range scopes {
+		if (scope == "offline" || scope == "offline_access") && available[scope] {
+			return scopes, ""
+		}
+	}
+
+	for _, offline := range []string{"offline", "offline_access"} {
+		if available[offline] {
+			return replaceOfflineScope(scopes, offline), fmt.Sprintf(`Requesting scope "%s" instead because the server does not support the requested offline scope.`, offline)
+		}
+	}
+	return scopes, `The server supports neither "offline" nor "offline_access", it will most likely not issue a refresh token.`
+}
+
+// autoOfflineScope implements --offline-scope auto. The discovery document is only needed if scopes contain an
+// offline scope, discovery is used if it was already fetched. Failing to fetch the document is not fatal, the
+// scopes are sent as requested. This only is a warning if explicit is set, auto is the default for every run.
+func autoOfflineScope(ctx context.Context, issuerURL string, scopes []string, discovery *discoveryDocument, explicit bool) ([]string, *discoveryDocument) {
+	if !hasOfflineScope(scopes) {
+		return scopes, discovery
+	}
+	if discovery == nil {
+		var err error
+		if discovery, err = fetchDiscovery(ctx, issuerURL); err != nil {
+			if explicit {
+				warn("Sending the offline scope as requested: %s", err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Note: Sending the offline scope as requested: %s\n", err)
+			}
+			return scopes, nil
+		}
+	}
+
+	scopes, w := normalizeOfflineScope(scopes, discovery.ScopesSupported)
+	if w != "" {
+		warn(w)
+	}
+	return scopes, discovery
+}
+
+// unsupportedScopes returns the scopes which are not listed in supported. Entries of supported ending in ".*" match
+// all scopes with that prefix, like the wildcard scope strategy of Hydra does.
+func unsupportedScopes(scopes, supported []string) []string {
+	var unsupported []string
+	for _, scope := range scopes {
+		found := false
+		for _, s := range supported {
+			if s == scope || (strings.HasSuffix(s, ".*") && strings.HasPrefix(scope, strings.TrimSuffix(s, "*"))) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unsupported = append(unsupported, scope)
+		}
+	}
+	return unsupported
+}
+
+// checkScopesSupported implements --scopes-supported-check: requested scopes which are not advertised in
+// scopes_supported of the discovery document are a warning, or fail the command before the flow is started if
+// --fail-on-warning is set. The check is skipped if the document does not advertise scopes_supported, which is
+// optional. The discovery document is fetched if it is nil and returned for later use.
+func checkScopesSupported(ctx context.Context, w io.Writer, issuer string, scopes []string, discovery *discoveryDocument) (*discoveryDocument, error) {
+	complain := func(message string) error {
+		if failOnWarning {
+			return newExitError(exitCodeWarning, errors.Errorf("%s, failing before the flow because --fail-on-warning is set", message))
+		}
+		warn(message)
+		return nil
+	}
+
+	if discovery == nil {
+		d, err := fetchDiscovery(ctx, issuer)
+		if err != nil {
+			return nil, complain(fmt.Sprintf("Could not check the requested scopes against scopes_supported: %s", err))
+		}
+		discovery = d
+	}
+	if len(discovery.ScopesSupported) == 0 {
+		fmt.Fprintf(w, "Note: The discovery document does not advertise scopes_supported, skipping --scopes-supported-check.\n\n")
+		return discovery, nil
+	}
+
+	if unsupported := unsupportedScopes(scopes, discovery.ScopesSupported); len(unsupported) > 0 {
+		return discovery, complain(fmt.Sprintf("The requested scopes are not advertised in scopes_supported of the discovery document: %s", strings.Join(unsupported, ", ")))
+	}
+	return discovery, nil
+}
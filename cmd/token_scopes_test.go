@@ -0,0 +1,136 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestMergeScopes(t *testing.T) {
+	assert.Equal(t, []string{}, mergeScopes())
+	assert.Equal(t, []string{"openid", "offline", "email"}, mergeScopes([]string{"openid", "offline"}, []string{"email", "openid"}))
+	assert.Equal(t, []string{"openid", "photos.read", "offline"}, mergeScopes([]string{"openid photos.read", ""}, []string{" offline "}))
+}
+
+func TestCheckGrantedScopes(t *testing.T) {
+	granted := (&oauth2.Token{}).WithExtra(map[string]interface{}{"scope": "openid email"})
+
+	missing, extra := compareScopes([]string{"openid", "offline"}, []string{"openid", "email"})
+	assert.Equal(t, []string{"offline"}, missing)
+	assert.Equal(t, []string{"email"}, extra)
+
+	assert.NoError(t, checkGrantedScopes([]string{"openid"}, granted, "contains"))
+	assert.Error(t, checkGrantedScopes([]string{"openid"}, granted, "exact"))
+	assert.Error(t, checkGrantedScopes([]string{"openid", "offline"}, granted, "contains"))
+	assert.NoError(t, checkGrantedScopes([]string{"openid", "offline"}, granted, ""))
+	assert.NoError(t, checkGrantedScopes([]string{"openid", "offline"}, &oauth2.Token{}, "exact"))
+}
+
+func TestNormalizeOfflineScope(t *testing.T) {
+	scopes, w := normalizeOfflineScope([]string{"openid", "offline"}, []string{"openid", "offline_access"})
+	assert.Equal(t, []string{"openid", "offline_access"}, scopes)
+	assert.NotEmpty(t, w)
+
+	scopes, w = normalizeOfflineScope([]string{"openid", "offline_access"}, []string{"openid", "offline"})
+	assert.Equal(t, []string{"openid", "offline"}, scopes)
+	assert.NotEmpty(t, w)
+
+	scopes, w = normalizeOfflineScope([]string{"openid", "offline"}, []string{"openid", "offline", "offline_access"})
+	assert.Equal(t, []string{"openid", "offline"}, scopes)
+	assert.Empty(t, w)
+
+	scopes, w = normalizeOfflineScope([]string{"openid", "offline"}, []string{"openid"})
+	assert.Equal(t, []string{"openid", "offline"}, scopes)
+	assert.NotEmpty(t, w)
+
+	scopes, w = normalizeOfflineScope([]string{"openid", "offline"}, nil)
+	assert.Equal(t, []string{"openid", "offline"}, scopes)
+	assert.Empty(t, w)
+
+	assert.Equal(t, []string{"offline_access", "openid"}, replaceOfflineScope([]string{"offline", "openid", "offline_access"}, "offline_access"))
+}
+
+func TestAutoOfflineScope(t *testing.T) {
+	fetched := 0
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		json.NewEncoder(w).Encode(&discoveryDocument{
+			Issuer:                ts.URL,
+			AuthorizationEndpoint: ts.URL + "/oauth2/auth",
+			TokenEndpoint:         ts.URL + "/oauth2/token",
+			JWKsURI:               ts.URL + "/.well-known/jwks.json",
+			ScopesSupported:       []string{"openid", "offline_access"},
+		})
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+
+	scopes, discovery := autoOfflineScope(ctx, ts.URL, []string{"openid"}, nil, false)
+	assert.Equal(t, []string{"openid"}, scopes)
+	assert.Nil(t, discovery)
+	assert.Equal(t, 0, fetched, "the discovery document is only fetched for an offline scope")
+
+	scopes, discovery = autoOfflineScope(ctx, ts.URL, []string{"openid", "offline"}, nil, false)
+	assert.Equal(t, []string{"openid", "offline_access"}, scopes)
+	require.NotNil(t, discovery)
+	assert.Equal(t, 1, fetched)
+
+	scopes, _ = autoOfflineScope(ctx, ts.URL, []string{"openid", "offline"}, discovery, false)
+	assert.Equal(t, []string{"openid", "offline_access"}, scopes)
+	assert.Equal(t, 1, fetched, "an already fetched discovery document is reused")
+}
+
+func TestUnsupportedScopes(t *testing.T) {
+	supported := []string{"openid", "offline", "hydra.*"}
+	assert.Empty(t, unsupportedScopes([]string{"openid", "offline", "hydra.clients", "hydra.keys.get"}, supported))
+	assert.Equal(t, []string{"opneid", "hydra", "photos"}, unsupportedScopes([]string{"opneid", "hydra", "photos", "offline"}, supported))
+}
+
+func TestScopesFromTokenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hydra-scopes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token.json")
+	require.NoError(t, writeTokenFile(path, (&oauth2.Token{AccessToken: "foo"}).WithExtra(map[string]interface{}{"scope": "openid offline hydra"})))
+	scopes, err := scopesFromTokenFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"openid", "offline", "hydra"}, scopes)
+
+	require.NoError(t, writeTokenFile(path, &oauth2.Token{AccessToken: "foo"}))
+	_, err = scopesFromTokenFile(path)
+	assert.Error(t, err)
+
+	_, err = scopesFromTokenFile(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
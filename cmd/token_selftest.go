@@ -0,0 +1,260 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/square/go-jose"
+	"golang.org/x/oauth2"
+)
+
+// tokenSelfTestCmd represents the self-test command
+var tokenSelfTestCmd = &cobra.Command{
+	Use:   "self-test",
+	Short: "Check the connectivity to the cluster and the configuration of the CLI",
+	Long: `This command checks the setup before running a flow and prints a checklist:
+
+	- the TLS certificate of the cluster is valid, or --skip-tls-verify is needed
+	- the discovery document is reachable and advertises all required endpoints
+	- the JSON Web Key Set is reachable
+	- the client --id, defaults to the client id of the config file, exists
+	- the redirect url --redirect is registered for the client
+
+The client is looked up using the credentials of the config file and skipped if there are none. Checks which
+only point at potential problems are reported as WARN, the command exits with 2 if any check FAILs.
+
+` + exitCodesHelp,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext()
+		defer cancel()
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, newTokenHTTPClient(cmd))
+
+		clientID, _ := cmd.Flags().GetString("id")
+		if clientID == "" {
+			clientID = c.ClientID
+		}
+		redirect, _ := cmd.Flags().GetString("redirect")
+		skipTLSVerify, _ := cmd.Flags().GetBool("skip-tls-verify")
+
+		t := new(selfTest)
+		t.checkTLS(ctx, c.ClusterURL, skipTLSVerify)
+
+		discovery, err := fetchDiscoveryDocument(ctx, c.ClusterURL)
+		if err != nil {
+			t.fail("discovery document", err)
+		} else {
+			t.pass("discovery document advertises the required endpoints")
+			var optional []string
+			if discovery.UserinfoEndpoint == "" {
+				optional = append(optional, "userinfo_endpoint")
+			}
+			if discovery.EndSessionEndpoint == "" {
+				optional = append(optional, "end_session_endpoint")
+			}
+			if len(optional) > 0 {
+				t.warn("discovery document advertises the optional endpoints", "missing "+strings.Join(optional, ", "))
+			}
+
+			var keys jose.JSONWebKeySet
+			if err := getJSON(ctx, discovery.JWKsURI, &keys); err != nil {
+				t.fail("JSON Web Key Set", err)
+			} else if len(keys.Keys) == 0 {
+				t.fail("JSON Web Key Set", errors.Errorf("%s contains no keys", discovery.JWKsURI))
+			} else {
+				t.pass(fmt.Sprintf("JSON Web Key Set contains %d keys", len(keys.Keys)))
+			}
+		}
+
+		t.checkClient(cmd, clientID, redirect)
+
+		t.report(os.Stdout)
+		if t.failed() {
+			return newExitError(exitCodeConfig, errors.New("The self test failed"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenSelfTestCmd)
+	tokenSelfTestCmd.Flags().String("id", "", "The client id to look up, defaults to the client id of the config file")
+	tokenSelfTestCmd.Flags().String("redirect", "http://localhost:4445/callback", "The redirect url which must be registered for the client")
+}
+
+// selfTestCheck is one line of the checklist printed by "hydra token self-test" and
+// "hydra token refresh --reuse-check".
+type selfTestCheck struct {
+	status string
+	name   string
+	detail string
+}
+
+type selfTest struct {
+	// title is the heading of the report, "Self Test" if empty.
+	title  string
+	checks []selfTestCheck
+}
+
+func (t *selfTest) pass(name string) {
+	t.checks = append(t.checks, selfTestCheck{status: "PASS", name: name})
+}
+
+func (t *selfTest) warn(name, detail string) {
+	t.checks = append(t.checks, selfTestCheck{status: "WARN", name: name, detail: detail})
+}
+
+func (t *selfTest) skip(name, reason string) {
+	t.checks = append(t.checks, selfTestCheck{status: "SKIP", name: name, detail: reason})
+}
+
+func (t *selfTest) fail(name string, err error) {
+	t.checks = append(t.checks, selfTestCheck{status: "FAIL", name: name, detail: err.Error()})
+}
+
+func (t *selfTest) failed() bool {
+	for _, c := range t.checks {
+		if c.status == "FAIL" {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *selfTest) report(w io.Writer) {
+	title := t.title
+	if title == "" {
+		title = "Self Test"
+	}
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, c := range t.checks {
+		if c.detail != "" {
+			fmt.Fprintf(w, "\t%s %s: %s\n", c.status, c.name, c.detail)
+		} else {
+			fmt.Fprintf(w, "\t%s %s\n", c.status, c.name)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// checkTLS verifies the certificate of the cluster even if --skip-tls-verify is set, so that the flag can be
+// dropped once the certificate is valid. The handshake respects the --tls-min-version, --tls-max-version,
+// --tls-cipher-suites and --proxy-url flags and is canceled with ctx.
+func (t *selfTest) checkTLS(ctx context.Context, clusterURL string, skipTLSVerify bool) {
+	const name = "TLS certificate of the cluster"
+	u, err := url.Parse(clusterURL)
+	if err != nil || u.Host == "" {
+		t.fail("cluster url", errors.Errorf(`"%s" is not a valid url, set cluster_url in the config file`, clusterURL))
+		return
+	}
+	if u.Scheme != "https" {
+		if !isLoopback(u.Hostname()) {
+			t.warn(name, "the cluster url uses "+u.Scheme+", tokens are sent over an unencrypted connection")
+		} else {
+			t.skip(name, "the cluster url does not use https")
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Any response proves that the handshake succeeded, the status code does not matter.
+	transport := newTokenTransport(false)
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	req, err := http.NewRequest("HEAD", clusterURL, nil)
+	if err != nil {
+		t.fail(name, err)
+		return
+	}
+	response, err := client.Do(req.WithContext(ctx))
+	if err == nil {
+		response.Body.Close()
+	}
+
+	switch {
+	case err != nil && skipTLSVerify:
+		t.warn(name, fmt.Sprintf("%s, it is only accepted because of --skip-tls-verify", err))
+	case err != nil:
+		t.fail(name, err)
+	case skipTLSVerify:
+		t.warn(name, "the certificate is valid, --skip-tls-verify is not needed")
+	default:
+		t.pass(name)
+	}
+}
+
+// checkClient looks up the client using the credentials of the config file and checks that redirect is registered.
+func (t *selfTest) checkClient(cmd *cobra.Command, clientID, redirect string) {
+	name := fmt.Sprintf("client %s exists", clientID)
+	redirectName := fmt.Sprintf("redirect url %s is registered", redirect)
+	switch {
+	case clientID == "":
+		t.fail("client id", errors.New("no client id is set, use --id or set client_id in the config file"))
+		return
+	case c.ClientID == "" || c.ClientSecret == "":
+		t.skip(name, "the config file contains no credentials to look up clients")
+		t.skip(redirectName, "the client could not be looked up")
+		return
+	}
+
+	client, response, err := newClientAPI(cmd).GetOAuth2Client(clientID)
+	switch {
+	case err != nil:
+		t.fail(name, err)
+	case response.StatusCode == http.StatusNotFound:
+		t.fail(name, errors.New("the cluster does not know the client"))
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		t.warn(name, fmt.Sprintf("the credentials of the config file may not read clients (status %d)", response.StatusCode))
+	case response.StatusCode != http.StatusOK:
+		t.fail(name, errors.Errorf("expected status code %d but got %d: %s", http.StatusOK, response.StatusCode, response.Payload))
+	default:
+		t.pass(name)
+		for _, uri := range client.RedirectUris {
+			if uri == redirect {
+				t.pass(redirectName)
+				return
+			}
+		}
+		if len(client.RedirectUris) == 0 {
+			t.fail(redirectName, errors.New("the client has no redirect urls"))
+		} else {
+			t.fail(redirectName, errors.Errorf("the client allows %s", strings.Join(client.RedirectUris, ", ")))
+		}
+		return
+	}
+	t.skip(redirectName, "the client could not be looked up")
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func selfTestStatuses(test *selfTest) []string {
+	var out []string
+	for _, check := range test.checks {
+		out = append(out, check.status)
+	}
+	return out
+}
+
+func TestSelfTestReport(t *testing.T) {
+	test := new(selfTest)
+	test.pass("discovery document")
+	test.warn("optional endpoints", "missing userinfo_endpoint")
+	test.skip("client", "no credentials")
+	assert.False(t, test.failed())
+
+	test.fail("JSON Web Key Set", errors.New("no keys"))
+	assert.True(t, test.failed())
+
+	var out bytes.Buffer
+	test.report(&out)
+	assert.Equal(t, "Self Test:\n"+
+		"\tPASS discovery document\n"+
+		"\tWARN optional endpoints: missing userinfo_endpoint\n"+
+		"\tSKIP client: no credentials\n"+
+		"\tFAIL JSON Web Key Set: no keys\n\n", out.String())
+
+	out.Reset()
+	(&selfTest{title: "Refresh Token Reuse"}).report(&out)
+	assert.Equal(t, "Refresh Token Reuse:\n\n", out.String())
+}
+
+func TestSelfTestCheckTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// The rejected handshakes are expected.
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	for k, tc := range []struct {
+		url           string
+		skipTLSVerify bool
+		expect        string
+	}{
+		{url: "http://127.0.0.1:4444", expect: "SKIP"},
+		{url: "http://hydra:4444", expect: "WARN"},
+		{url: "not a url", expect: "FAIL"},
+		// The certificate of the test server is self-signed.
+		{url: ts.URL, expect: "FAIL"},
+		{url: ts.URL, skipTLSVerify: true, expect: "WARN"},
+	} {
+		test := new(selfTest)
+		test.checkTLS(context.Background(), tc.url, tc.skipTLSVerify)
+		assert.Equal(t, []string{tc.expect}, selfTestStatuses(test), "case %d", k)
+	}
+
+	// The handshake goes through --proxy-url.
+	var connects int
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "CONNECT" {
+			connects++
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+	defer func(previous string) { proxyURL = previous }(proxyURL)
+	proxyURL = proxy.URL
+
+	test := new(selfTest)
+	test.checkTLS(context.Background(), "https://hydra.example.com", false)
+	assert.Equal(t, []string{"FAIL"}, selfTestStatuses(test))
+	assert.Equal(t, 1, connects)
+
+	// A canceled context stops the handshake.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	test = new(selfTest)
+	test.checkTLS(ctx, ts.URL, true)
+	assert.Equal(t, []string{"WARN"}, selfTestStatuses(test))
+	assert.Contains(t, test.checks[0].detail, "context canceled")
+}
+
+func TestSelfTestCheckClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/oauth2/token":
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "admin-token", "token_type": "bearer", "expires_in": 3600})
+		case "/clients/known":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "known", "redirect_uris": []string{"http://localhost:4445/callback"}})
+		case "/clients/no-redirects":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "no-redirects"})
+		case "/clients/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"forbidden"}`))
+		case "/clients/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"internal"}`))
+		case "/":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not found"}`))
+		}
+	}))
+	defer ts.Close()
+
+	defer func(cluster, id, secret string) {
+		c.ClusterURL, c.ClientID, c.ClientSecret = cluster, id, secret
+	}(c.ClusterURL, c.ClientID, c.ClientSecret)
+	c.ClusterURL, c.ClientID, c.ClientSecret = ts.URL, "admin", "pw"
+
+	for k, tc := range []struct {
+		clientID       string
+		redirect       string
+		noCredentials  bool
+		expect         []string
+		expectFailure  bool
+		expectInDetail string
+	}{
+		{clientID: "", expect: []string{"FAIL"}, expectFailure: true},
+		{clientID: "known", noCredentials: true, expect: []string{"SKIP", "SKIP"}},
+		{clientID: "known", redirect: "http://localhost:4445/callback", expect: []string{"PASS", "PASS"}},
+		{clientID: "known", redirect: "http://localhost:9999/callback", expect: []string{"PASS", "FAIL"}, expectFailure: true, expectInDetail: "the client allows http://localhost:4445/callback"},
+		{clientID: "no-redirects", redirect: "http://localhost:4445/callback", expect: []string{"PASS", "FAIL"}, expectFailure: true, expectInDetail: "the client has no redirect urls"},
+		{clientID: "missing", expect: []string{"FAIL", "SKIP"}, expectFailure: true, expectInDetail: "the cluster does not know the client"},
+		{clientID: "forbidden", expect: []string{"WARN", "SKIP"}, expectInDetail: "the credentials of the config file may not read clients (status 403)"},
+		{clientID: "broken", expect: []string{"FAIL", "SKIP"}, expectFailure: true, expectInDetail: `expected status code 200 but got 500: {"error":"internal"}`},
+	} {
+		if tc.noCredentials {
+			c.ClientID, c.ClientSecret = "", ""
+		} else {
+			c.ClientID, c.ClientSecret = "admin", "pw"
+		}
+
+		test := new(selfTest)
+		test.checkClient(&cobra.Command{}, tc.clientID, tc.redirect)
+		assert.Equal(t, tc.expect, selfTestStatuses(test), "case %d", k)
+		assert.Equal(t, tc.expectFailure, test.failed(), "case %d", k)
+		if tc.expectInDetail != "" {
+			var details []string
+			for _, check := range test.checks {
+				details = append(details, check.detail)
+			}
+			assert.Contains(t, details, tc.expectInDetail, "case %d", k)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// silentAuthErrors are the errors of OpenID Connect Core 1.0 section 3.1.2.6 returned for prompt=none if the user
+// would have to interact with the server.
+var silentAuthErrors = map[string]string{
+	"login_required":             "the user has no active session at the server",
+	"interaction_required":       "the server needs the user to interact, for example to complete the login",
+	"consent_required":           "the user has to grant consent",
+	"account_selection_required": "the user has to select one of several sessions",
+}
+
+// silentAuthFailure describes the error returned to a --silent authorization request.
+func silentAuthFailure(code, description string) string {
+	message := fmt.Sprintf("Silent authentication failed, the server returned %s", code)
+	if reason, ok := silentAuthErrors[code]; ok {
+		message = fmt.Sprintf("%s: %s", message, reason)
+	}
+	if description != "" {
+		message = fmt.Sprintf("%s (%s)", message, description)
+	}
+	return message
+}
+
+// readIDTokenHint returns the ID token of --id-token-hint, or of the token file written by --out.
+func readIDTokenHint(hint, path string) (string, error) {
+	if path == "" {
+		return hint, nil
+	}
+	if hint != "" {
+		return "", errors.New("Flags --id-token-hint and --id-token-hint-file can not be used together")
+	}
+
+	stored, err := readTokenFile(path)
+	if err != nil {
+		return "", err
+	}
+	if stored.IDToken == "" {
+		return "", errors.Errorf("the token file %s does not contain an ID token", path)
+	}
+	return stored.IDToken, nil
+}
+
+// checkHintSubject checks that the ID token issued by a silent authentication is about the same user as the
+// ID token sent as id_token_hint. Both tokens are decoded without verifying their signature.
+func checkHintSubject(hint string, token *oauth2.Token) error {
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return nil
+	}
+
+	_, hinted, err := decodeJWT(hint)
+	if err != nil {
+		return errors.Wrap(err, "could not decode the id_token_hint")
+	}
+	_, issued, err := decodeJWT(idToken)
+	if err != nil {
+		return errors.Wrap(err, "could not decode the ID token")
+	}
+
+	if fmt.Sprintf("%v", hinted["sub"]) != fmt.Sprintf("%v", issued["sub"]) {
+		return errors.Errorf("the ID token was issued for subject %v but the id_token_hint is for subject %v", issued["sub"], hinted["sub"])
+	}
+	return nil
+}
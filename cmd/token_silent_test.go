@@ -0,0 +1,81 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestSilentAuthFailure(t *testing.T) {
+	assert.Equal(t, "Silent authentication failed, the server returned login_required: the user has no active session at the server", silentAuthFailure("login_required", ""))
+	assert.Equal(t, "Silent authentication failed, the server returned consent_required: the user has to grant consent (The user has not granted the scopes)", silentAuthFailure("consent_required", "The user has not granted the scopes"))
+	assert.Equal(t, "Silent authentication failed, the server returned invalid_request", silentAuthFailure("invalid_request", ""))
+	assert.Equal(t, "Silent authentication failed, the server returned invalid_request (The prompt is malformed)", silentAuthFailure("invalid_request", "The prompt is malformed"))
+}
+
+func TestReadIDTokenHint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hydra-silent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	withIDToken := filepath.Join(dir, "with-id-token.json")
+	require.NoError(t, writeTokenFile(withIDToken, (&oauth2.Token{AccessToken: "access-token"}).WithExtra(map[string]interface{}{"id_token": "stored-id-token"})))
+	withoutIDToken := filepath.Join(dir, "without-id-token.json")
+	require.NoError(t, writeTokenFile(withoutIDToken, &oauth2.Token{AccessToken: "access-token"}))
+
+	hint, err := readIDTokenHint("flag-id-token", "")
+	require.NoError(t, err)
+	assert.Equal(t, "flag-id-token", hint)
+
+	hint, err = readIDTokenHint("", withIDToken)
+	require.NoError(t, err)
+	assert.Equal(t, "stored-id-token", hint)
+
+	_, err = readIDTokenHint("flag-id-token", withIDToken)
+	assert.EqualError(t, err, "Flags --id-token-hint and --id-token-hint-file can not be used together")
+
+	_, err = readIDTokenHint("", withoutIDToken)
+	assert.EqualError(t, err, "the token file "+withoutIDToken+" does not contain an ID token")
+
+	_, err = readIDTokenHint("", filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestCheckHintSubject(t *testing.T) {
+	header := map[string]interface{}{"alg": "RS256"}
+	hint := unsignedJWT(t, header, map[string]interface{}{"sub": "peter"})
+	issued := func(idToken string) *oauth2.Token {
+		return (&oauth2.Token{AccessToken: "access-token"}).WithExtra(map[string]interface{}{"id_token": idToken})
+	}
+
+	assert.NoError(t, checkHintSubject(hint, issued(unsignedJWT(t, header, map[string]interface{}{"sub": "peter"}))))
+	assert.NoError(t, checkHintSubject(hint, &oauth2.Token{AccessToken: "access-token"}), "a response without an ID token is not checked")
+	assert.EqualError(t, checkHintSubject(hint, issued(unsignedJWT(t, header, map[string]interface{}{"sub": "max"}))), "the ID token was issued for subject max but the id_token_hint is for subject peter")
+	assert.Error(t, checkHintSubject("not-a-jwt", issued(hint)))
+	assert.Error(t, checkHintSubject(hint, issued("not-a-jwt")))
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// spinner shows an animated message on stderr until it is stopped.
+type spinner struct {
+	message string
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// startSpinner starts a spinner if stderr is a terminal and returns nil otherwise. It is safe to call stop on a
+// nil spinner.
+func startSpinner(message string) *spinner {
+	if !terminal.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+
+	s := &spinner{message: message, done: make(chan struct{}), stopped: make(chan struct{})}
+	go s.run()
+	return s
+}
+
+func (s *spinner) run() {
+	defer close(s.stopped)
+
+	frames := []rune(`|/-\`)
+	ticker := time.NewTicker(150 * time.Millisecond)
+	defer ticker.Stop()
+
+	for i := 0; ; i++ {
+		fmt.Fprintf(os.Stderr, "\r%c %s", frames[i%len(frames)], s.message)
+		select {
+		case <-s.done:
+			// Clear the line so that the following output starts at the beginning of the line.
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop stops the spinner and clears its message.
+func (s *spinner) stop() {
+	if s == nil {
+		return
+	}
+	close(s.done)
+	<-s.stopped
+}
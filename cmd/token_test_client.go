@@ -0,0 +1,146 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ory/hydra/pkg"
+	hydra "github.com/ory/hydra/sdk/go/hydra/swagger"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// newClientAPI returns the SDK's OAuth 2.0 API, which manages clients and introspects tokens, authenticated with the
+// credentials from the config file.
+func newClientAPI(cmd *cobra.Command) *hydra.OAuth2Api {
+	m := hydra.NewOAuth2ApiWithBasePath(c.GetClusterURLWithoutTailingSlash())
+	m.Configuration.Transport = c.OAuth2Client(cmd).Transport
+	if harOut != "" {
+		m.Configuration.Transport = &harTransport{Base: m.Configuration.Transport, Recorder: tokenHAR}
+	}
+	if term, _ := cmd.Flags().GetBool("fake-tls-termination"); term {
+		m.Configuration.DefaultHeader["X-Forwarded-Proto"] = "https"
+	}
+	return m
+}
+
+// testClient returns the client used by --create-client. The client stored in configPath is reused if the file
+// exists, otherwise a client allowed to use redirect, scopes and grantType is created and, if configPath is not
+// empty, written to configPath. created tells if the client was created.
+func testClient(cmd *cobra.Command, configPath, redirect string, scopes []string, grantType string) (client *hydra.OAuth2Client, created bool, err error) {
+	if configPath != "" {
+		if client, err := readClientConfig(configPath); err == nil {
+			return client, false, nil
+		} else if !os.IsNotExist(errors.Cause(err)) {
+			return nil, false, err
+		}
+	}
+
+	grantTypes := []string{"authorization_code", "refresh_token"}
+	if grantType != "authorization_code" && grantType != "refresh_token" {
+		if grantType == "device" {
+			grantType = "urn:ietf:params:oauth:grant-type:device_code"
+		}
+		grantTypes = append(grantTypes, grantType)
+	}
+	secret, err := pkg.GenerateSecret(26)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "Could not generate the client secret")
+	}
+
+	result, response, err := newClientAPI(cmd).CreateOAuth2Client(hydra.OAuth2Client{
+		ClientName:    "Test client created by hydra token user --create-client",
+		ClientSecret:  string(secret),
+		GrantTypes:    grantTypes,
+		ResponseTypes: []string{"code", "id_token"},
+		RedirectUris:  []string{redirect},
+		Scope:         strings.Join(scopes, " "),
+	})
+	if err != nil {
+		return nil, false, errors.Wrap(err, "Could not create the OAuth 2.0 Client")
+	}
+	if response.StatusCode != http.StatusCreated {
+		return nil, false, errors.Errorf("Could not create the OAuth 2.0 Client, expected status code %d but got %d: %s", http.StatusCreated, response.StatusCode, response.Payload)
+	}
+	if result.ClientSecret == "" {
+		result.ClientSecret = string(secret)
+	}
+
+	if configPath != "" {
+		if err := writeClientConfig(configPath, result); err != nil {
+			return result, true, err
+		}
+	}
+	return result, true, nil
+}
+
+// deleteTestClient deletes the client created by --create-client and the file it was stored in, if any.
+func deleteTestClient(cmd *cobra.Command, id, configPath string) error {
+	response, err := newClientAPI(cmd).DeleteOAuth2Client(id)
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete OAuth 2.0 Client %s", id)
+	}
+	if response.StatusCode != http.StatusNoContent {
+		return errors.Errorf("Could not delete OAuth 2.0 Client %s, expected status code %d but got %d: %s", id, http.StatusNoContent, response.StatusCode, response.Payload)
+	}
+	if configPath != "" {
+		if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// readClientConfig reads a client written by writeClientConfig.
+func readClientConfig(path string) (*hydra.OAuth2Client, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var client hydra.OAuth2Client
+	if err := json.Unmarshal(body, &client); err != nil {
+		return nil, errors.Wrapf(err, "could not decode client file %s", path)
+	}
+	if client.Id == "" {
+		return nil, errors.Errorf("client file %s does not contain a client id", path)
+	}
+	return &client, nil
+}
+
+// writeClientConfig stores the client in the format read by "hydra clients import". The file is only readable by
+// the current user because it contains the client secret.
+func writeClientConfig(path string, client *hydra.OAuth2Client) error {
+	out, err := json.MarshalIndent(client, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := ioutil.WriteFile(path, out, 0600); err != nil {
+		return errors.Wrapf(err, "could not write client file %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	hydra "github.com/ory/hydra/sdk/go/hydra/swagger"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hydra-test-client")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "client.json")
+
+	_, err = readClientConfig(path)
+	assert.True(t, os.IsNotExist(errors.Cause(err)))
+
+	client := &hydra.OAuth2Client{Id: "client", ClientSecret: "secret", RedirectUris: []string{"http://localhost:4445/callback"}}
+	require.NoError(t, writeClientConfig(path, client))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	read, err := readClientConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, client, read)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"client_secret":"secret"}`), 0600))
+	_, err = readClientConfig(path)
+	assert.EqualError(t, err, "client file "+path+" does not contain a client id")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`not json`), 0600))
+	_, err = readClientConfig(path)
+	assert.Error(t, err)
+}
+
+func TestTestClient(t *testing.T) {
+	var created []hydra.OAuth2Client
+	var deleted []string
+	status := http.StatusCreated
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/oauth2/token":
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "admin-token", "token_type": "bearer", "expires_in": 3600})
+		case r.URL.Path == "/clients" && r.Method == "POST":
+			var client hydra.OAuth2Client
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&client))
+			created = append(created, client)
+			client.Id = "generated"
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(client)
+		case r.Method == "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	defer func(cluster, id, secret string) {
+		c.ClusterURL, c.ClientID, c.ClientSecret = cluster, id, secret
+	}(c.ClusterURL, c.ClientID, c.ClientSecret)
+	c.ClusterURL, c.ClientID, c.ClientSecret = ts.URL, "admin", "pw"
+
+	dir, err := ioutil.TempDir("", "hydra-test-client")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "client.json")
+
+	client, isNew, err := testClient(&cobra.Command{}, path, "http://localhost:4445/callback", []string{"openid", "offline"}, "device")
+	require.NoError(t, err)
+	assert.True(t, isNew)
+	assert.Equal(t, "generated", client.Id)
+	assert.NotEmpty(t, client.ClientSecret)
+	require.Len(t, created, 1)
+	assert.Equal(t, []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:device_code"}, created[0].GrantTypes)
+	assert.Equal(t, []string{"http://localhost:4445/callback"}, created[0].RedirectUris)
+	assert.Equal(t, "openid offline", created[0].Scope)
+
+	stored, err := readClientConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, client, stored)
+
+	// The stored client is reused.
+	reused, isNew, err := testClient(&cobra.Command{}, path, "http://localhost:4445/callback", []string{"openid"}, "authorization_code")
+	require.NoError(t, err)
+	assert.False(t, isNew)
+	assert.Equal(t, client, reused)
+	assert.Len(t, created, 1)
+
+	require.NoError(t, deleteTestClient(&cobra.Command{}, client.Id, path))
+	assert.Equal(t, []string{"/clients/generated"}, deleted)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	// Without a config path the client is not stored.
+	_, isNew, err = testClient(&cobra.Command{}, "", "http://localhost:4445/callback", []string{"openid"}, "refresh_token")
+	require.NoError(t, err)
+	assert.True(t, isNew)
+	require.Len(t, created, 2)
+	assert.Equal(t, []string{"authorization_code", "refresh_token"}, created[1].GrantTypes)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	status = http.StatusConflict
+	_, _, err = testClient(&cobra.Command{}, path, "http://localhost:4445/callback", []string{"openid"}, "authorization_code")
+	assert.Error(t, err)
+}
@@ -21,15 +21,26 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
-	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/ory/hydra/pkg"
 	"github.com/ory/hydra/rand/sequence"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/toqueteos/webbrowser"
 	"golang.org/x/oauth2"
@@ -39,33 +50,403 @@ import (
 var tokenUserCmd = &cobra.Command{
 	Use:   "user",
 	Short: "Generate an OAuth2 token using the code flow",
-	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
-		if ok, _ := cmd.Flags().GetBool("skip-tls-verify"); ok {
-			// fmt.Println("Warning: Skipping TLS Certificate Verification.")
-			ctx = context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			}})
+	Long: `This command opens the authorization url in the browser, waits for the redirect to the callback
+listener and exchanges the authorization code for an access, refresh and ID token.
+
+Use --grant-type to obtain the token using another grant type with the same flags instead:
+
+	client_credentials  requests a token for the client itself
+	refresh_token       refreshes --refresh-token or the token stored in --out and updates --out
+	device              runs the device authorization grant, see "hydra token device"
+
+Flags which only apply to the authorization code flow, for example --manual or --par, are rejected then.
+
+Tokens obtained with the authorization code flow are cached, keyed by token url, client id, scopes, audience,
+resource and token parameters.
+Later runs requesting the same token print the cached token while it is valid for at least another minute, or
+refresh it, instead of opening the browser. Use --no-cache to always run the browser flow and --clear-cache to
+remove all cached tokens. Runs which test the flow or the token, for example with --verify, --prompt,
+--code-only or --assert-scopes, bypass the cache.
+
+If the callback listener can not be used, for example on a remote machine or in a restricted network, use
+--manual and paste the url your browser was redirected to, even if the browser could not load it. If another
+process captures the redirect, for example because the browser runs in a different network namespace, it can
+write the redirect url or only its query to a named pipe read by --code-fifo instead:
+
+	$ mkfifo /tmp/hydra-code
+	$ hydra token user --code-fifo /tmp/hydra-code
+	$ echo "code=...&state=..." > /tmp/hydra-code
+
+Complex requests can be described in a JSON file read by --request-config, flags given on the command line
+win over it:
+
+	{
+	  "scopes": ["openid", "offline"],
+	  "audience": ["https://api.example.com"],
+	  "prompt": "login",
+	  "acr_values": ["urn:mace:incommon:iap:silver"],
+	  "claims": {"id_token": {"email": {"essential": true}}},
+	  "auth_params": {"ui_locales": "de"}
+	}
+
+Besides these, the file may set "resource", "redirect_uri", "login_hint", "display", "claims_locales", "max_age"
+and "token_params".
+
+` + keyringHelp + `
+
+With --format nagios the command prints a single status line with performance data and exits with 0 (OK),
+1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN) as expected by Nagios and Icinga instead of the exit codes below.
+
+` + exitCodesHelp,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		ctx, cancel := commandContext()
+		defer cancel()
+		httpClient := newTokenHTTPClient(cmd)
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+		started := time.Now()
+
+		if clear, _ := cmd.Flags().GetBool("clear-cache"); clear {
+			path, err := tokenCachePath(cmd)
+			if err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+			if err := os.Remove(path); os.IsNotExist(err) {
+				fmt.Printf("The token cache %s is empty.\n", path)
+				return nil
+			} else if err != nil {
+				return errors.WithStack(err)
+			}
+			fmt.Printf("Removed the token cache %s.\n", path)
+			return nil
+		}
+
+		if path, _ := cmd.Flags().GetString("request-config"); path != "" {
+			config, err := readRequestConfig(path)
+			if err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+			if err := config.apply(cmd); err != nil {
+				return newExitError(exitCodeConfig, errors.Wrapf(err, "Could not apply request config %s", path))
+			}
 		}
 
-		scopes, _ := cmd.Flags().GetStringSlice("scopes")
+		scopes := requestedScopes(cmd)
 		clientId, _ := cmd.Flags().GetString("id")
 		clientSecret, _ := cmd.Flags().GetString("secret")
 		redirectUrl, _ := cmd.Flags().GetString("redirect")
 		backend, _ := cmd.Flags().GetString("token-url")
 		frontend, _ := cmd.Flags().GetString("auth-url")
+		format, _ := cmd.Flags().GetString("format")
+
+		if clipboard, _ := cmd.Flags().GetBool("clipboard"); !clipboard && cmd.Flags().Changed("id-token-only") {
+			return newExitError(exitCodeConfig, errors.New("Flag --id-token-only requires --clipboard"))
+		}
+		if err := checkFormatFlag(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if err := checkFieldFlag(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if err := checkBrowserCommand(cmd); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if ok, _ := cmd.Flags().GetBool("stdout-token-only"); ok {
+			for _, name := range []string{"format", "code-only", "response-type", "print-authorize-only", "field"} {
+				if cmd.Flags().Changed(name) {
+					return newExitError(exitCodeConfig, errors.Errorf("Flag --stdout-token-only can not be used with --%s", name))
+				}
+			}
+			defer stdoutToStderr()()
+		} else if fields, _ := cmd.Flags().GetStringArray("field"); len(fields) > 0 {
+			defer stdoutToStderr()()
+		}
+
+		// issued is the token obtained by any of the flows below, it is recorded by --audit-log and --format nagios.
+		var issued *oauth2.Token
+		if format == "nagios" {
+			// This runs last so that --metrics-file and --audit-log record the actual result of the flow.
+			defer func() {
+				warning, _ := cmd.Flags().GetDuration("nagios-warning")
+				critical, _ := cmd.Flags().GetDuration("nagios-critical")
+				check := newNagiosCheck(issued, err, warning, critical, time.Since(started), time.Now())
+				fmt.Println(check.String())
+				err = check.err()
+			}()
+		}
+
+		sources := map[string]string{"client_id": "flag --id", "client_secret": "flag --secret", "auth_url": "flag --auth-url", "token_url": "flag --token-url", "scopes": "default"}
+		if cmd.Flags().Changed("scopes") || cmd.Flags().Changed("scope") {
+			sources["scopes"] = "flags --scopes and --scope"
+		} else if setByRequestConfig(cmd, "scopes") {
+			sources["scopes"] = "request config"
+		} else if len(c.DefaultScopes) > 0 {
+			sources["scopes"] = "config file"
+		}
+		if path, _ := cmd.Flags().GetString("scopes-from-token"); path != "" {
+			if cmd.Flags().Changed("scopes") || cmd.Flags().Changed("scope") {
+				return newExitError(exitCodeConfig, errors.New("Flag --scopes-from-token can not be used together with --scopes or --scope"))
+			}
+			if scopes, err = scopesFromTokenFile(path); err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+			sources["scopes"] = "granted scopes of token file " + path
+		}
+		// A public client picked by --interactive has no secret, the one from the config file must not be sent.
+		publicClient := false
+		if ok, _ := cmd.Flags().GetBool("interactive"); ok && !isInteractive() {
+			warn("Ignoring --interactive because stdin or stdout is not a terminal")
+		} else if ok {
+			clients, err := listClients(cmd)
+			if err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+			client, selected, err := selectClient(bufio.NewReader(os.Stdin), os.Stdout, clients, scopes)
+			if err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+
+			clientId, sources["client_id"] = client.Id, "interactive selection"
+			publicClient = client.Public
+			// Clients without registered scopes offer nothing to select, the requested scopes are kept.
+			if selected != nil {
+				scopes, sources["scopes"] = selected, "interactive selection"
+			}
+			if !client.Public && clientSecret == "" && !cmd.Flags().Changed("secret-keyring") {
+				if clientSecret, err = readSecret(os.Stdout, client.Id); err != nil {
+					return newExitError(exitCodeConfig, err)
+				}
+				sources["client_secret"] = "interactive prompt"
+			}
+		}
+
+		manual, _ := cmd.Flags().GetBool("manual")
+		fifo, _ := cmd.Flags().GetString("code-fifo")
+		if manual && fifo != "" {
+			return newExitError(exitCodeConfig, errors.New("Flags --manual and --code-fifo can not be used together"))
+		}
 
+		// The callback listener is started before the client is created and the authorization url is built because
+		// the redirect url depends on the port it was able to bind.
+		printAuthorizeOnly, _ := cmd.Flags().GetBool("print-authorize-only")
+		var listener net.Listener
+		if grantType, _ := cmd.Flags().GetString("grant-type"); grantType == "authorization_code" && !manual && fifo == "" && !printAuthorizeOnly {
+			if listener, redirectUrl, err = startCallbackListener(cmd, infoWriter(format), redirectUrl); err != nil {
+				return err
+			}
+			// Closes the listener if the flow finishes before it is served, for example with --prefer-refresh.
+			defer listener.Close()
+		}
+
+		if ok, _ := cmd.Flags().GetBool("create-client"); ok {
+			if clientId != "" || clientSecret != "" {
+				return newExitError(exitCodeConfig, errors.New("Flag --create-client can not be used together with --id, --secret or --interactive"))
+			}
+			configPath, _ := cmd.Flags().GetString("client-config-out")
+			grantType, _ := cmd.Flags().GetString("grant-type")
+			client, created, err := testClient(cmd, configPath, redirectUrl, scopes, grantType)
+			if err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+
+			clientId, clientSecret = client.Id, client.ClientSecret
+			if created {
+				sources["client_id"], sources["client_secret"] = "created by --create-client", "created by --create-client"
+				fmt.Fprintf(infoWriter(format), "Created OAuth 2.0 Client %s\n", client.Id)
+			} else {
+				sources["client_id"], sources["client_secret"] = "client file "+configPath, "client file "+configPath
+				fmt.Fprintf(infoWriter(format), "Reusing OAuth 2.0 Client %s from %s\n", client.Id, configPath)
+			}
+			if cleanup, _ := cmd.Flags().GetBool("cleanup-client"); cleanup {
+				defer func() {
+					if err := deleteTestClient(cmd, client.Id, configPath); err != nil {
+						warn("%s", err)
+						return
+					}
+					fmt.Fprintf(infoWriter(format), "Deleted OAuth 2.0 Client %s\n", client.Id)
+				}()
+			}
+		}
 		if clientId == "" {
-			clientId = c.ClientID
+			clientId, sources["client_id"] = c.ClientID, "config file"
 		}
-		if clientSecret == "" {
-			clientSecret = c.ClientSecret
+		if name, _ := cmd.Flags().GetString("secret-keyring"); name != "" {
+			if clientSecret != "" {
+				return newExitError(exitCodeConfig, errors.New("Flags --secret and --secret-keyring can not be used together"))
+			}
+			if clientSecret, err = keyringSecret(name); err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+			sources["client_secret"] = "keyring " + name
+		}
+		if clientSecret == "" && !publicClient {
+			clientSecret, sources["client_secret"] = c.ClientSecret, "config file"
+		}
+
+		endpoints, err := resolveClusterEndpoints(ctx, tokenIssuer, c.ClusterURL)
+		if err != nil {
+			return newContextExitError(ctx, exitCodeConfig, err)
 		}
+		discovery := endpoints.Discovery
 		if backend == "" {
-			backend = pkg.JoinURLStrings(c.ClusterURL, "/oauth2/token")
+			backend, sources["token_url"] = endpoints.Token, endpoints.Source
 		}
 		if frontend == "" {
-			frontend = pkg.JoinURLStrings(c.ClusterURL, "/oauth2/auth")
+			frontend, sources["auth_url"] = endpoints.Auth, endpoints.Source
+		}
+		issuerURL := tokenIssuer
+		if issuerURL == "" {
+			issuerURL = issuerFromAuthURL(frontend)
+		}
+
+		if metricsFile, _ := cmd.Flags().GetString("metrics-file"); metricsFile != "" {
+			defer func() {
+				m := &tokenMetrics{Command: "user", ClientID: clientId, Scopes: scopes, Started: started, Finished: time.Now(), Err: err}
+				if werr := m.write(metricsFile); werr != nil {
+					warn("Could not write metrics file: %s", werr)
+				}
+			}()
+		}
+
+		if auditLog, _ := cmd.Flags().GetString("audit-log"); auditLog != "" {
+			defer func() {
+				if werr := appendAuditEntry(auditLog, newAuditEntry("user", clientId, scopes, issued, err)); werr != nil {
+					warn("Could not write audit log: %s", werr)
+				}
+			}()
+		}
+
+		pairs, _ := cmd.Flags().GetStringArray("auth-param")
+		authParams, err := pkg.ParseFormParams(pairs)
+		if err != nil {
+			return newExitError(exitCodeConfig, errors.Wrap(err, "Invalid value for flag --auth-param"))
+		}
+		pairs, _ = cmd.Flags().GetStringArray("token-param")
+		if _, err := pkg.ParseFormParams(pairs); err != nil {
+			return newExitError(exitCodeConfig, errors.Wrap(err, "Invalid value for flag --token-param"))
+		}
+
+		if alg, _ := cmd.Flags().GetString("id-token-signing-alg"); strings.EqualFold(alg, "none") {
+			return newExitError(exitCodeConfig, errors.New(`Flag --id-token-signing-alg can not be "none", unsigned ID tokens are always rejected`))
+		}
+
+		assertScopes, _ := cmd.Flags().GetString("assert-scopes")
+		if assertScopes != "" && assertScopes != "contains" && assertScopes != "exact" {
+			return newExitError(exitCodeConfig, errors.Errorf(`Unknown value "%s" for flag --assert-scopes, expected one of: contains, exact`, assertScopes))
+		}
+
+		var claimsSchema *pkg.JSONSchema
+		if path, _ := cmd.Flags().GetString("claims-schema"); path != "" {
+			if claimsSchema, err = pkg.ReadJSONSchema(path); err != nil {
+				return newExitError(exitCodeConfig, errors.Wrap(err, "Invalid value for flag --claims-schema"))
+			}
+		}
+
+		authStyle, _ := cmd.Flags().GetString("auth-style")
+		var assertion *clientAssertion
+		switch authStyle {
+		case "header":
+			// This is the default behaviour of the oauth2 library.
+		case "body":
+			// The oauth2 library sends client credentials in the request body (client_secret_post) for
+			// providers it knows to not support HTTP Basic Authorization.
+			oauth2.RegisterBrokenAuthHeaderProvider(backend)
+		case "private_key_jwt":
+			keyPath, _ := cmd.Flags().GetString("client-key")
+			if keyPath == "" {
+				return newExitError(exitCodeConfig, errors.New("Flag --auth-style private_key_jwt requires --client-key"))
+			}
+			kid, _ := cmd.Flags().GetString("client-key-id")
+			lifetime, _ := cmd.Flags().GetDuration("client-assertion-lifetime")
+			audience, _ := cmd.Flags().GetString("client-assertion-aud")
+			if assertion, err = newClientAssertion(clientId, keyPath, kid, lifetime, audience); err != nil {
+				return newExitError(exitCodeConfig, errors.Wrap(err, "could not load --client-key"))
+			}
+			// The client id and the assertion are sent in the request body, the client secret is never sent.
+			oauth2.RegisterBrokenAuthHeaderProvider(backend)
+			httpClient.Transport.(*transporter).ClientAssertion = assertion
+			clientSecret = ""
+		default:
+			return newExitError(exitCodeConfig, errors.Errorf(`Unknown value "%s" for flag --auth-style, expected one of: header, body, private_key_jwt`, authStyle))
+		}
+		if authStyle != "private_key_jwt" {
+			for _, name := range []string{"client-key", "client-key-id", "client-assertion-lifetime", "client-assertion-aud"} {
+				if cmd.Flags().Changed(name) {
+					return newExitError(exitCodeConfig, errors.Errorf("Flag --%s requires --auth-style private_key_jwt", name))
+				}
+			}
+		}
+
+		if grantType, _ := cmd.Flags().GetString("grant-type"); grantType != "authorization_code" {
+			if err := validateGrantFlags(cmd, grantType); err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+			// The refresh token grant does not request scopes.
+			if ok, _ := cmd.Flags().GetBool("scopes-supported-check"); ok && grantType != "refresh_token" {
+				if _, err := checkScopesSupported(ctx, infoWriter(format), issuerURL, scopes, discovery); err != nil {
+					return err
+				}
+			}
+
+			token, err := runGrant(ctx, cmd, grantType, backend, clientId, clientSecret, authStyle, scopes)
+			if err != nil {
+				return err
+			}
+			issued = token
+			if err := printToken(cmd, token); err != nil {
+				return err
+			}
+			copyTokenToClipboard(cmd, token)
+			if out, _ := cmd.Flags().GetString("out"); out != "" {
+				if err := writeTokenFile(out, token); err != nil {
+					return err
+				}
+			}
+			if err := checkJWTAccessTokenAudFlag(cmd, infoWriter(format), token); err != nil {
+				return err
+			}
+			if grantType == "refresh_token" {
+				// The refresh token grant does not request scopes, the token keeps the scopes of the original grant.
+				return nil
+			}
+			return checkGrantedScopes(scopes, token, assertScopes)
+		}
+
+		switch offlineScope, _ := cmd.Flags().GetString("offline-scope"); offlineScope {
+		case "keep":
+		case "offline", "offline_access":
+			scopes = replaceOfflineScope(scopes, offlineScope)
+		case "auto":
+			scopes, discovery = autoOfflineScope(ctx, issuerURL, scopes, discovery, cmd.Flags().Changed("offline-scope"))
+		default:
+			return newExitError(exitCodeConfig, errors.Errorf(`Unknown value "%s" for flag --offline-scope, expected one of: auto, offline, offline_access, keep`, offlineScope))
+		}
+
+		if ok, _ := cmd.Flags().GetBool("scopes-supported-check"); ok {
+			if discovery, err = checkScopesSupported(ctx, infoWriter(format), issuerURL, scopes, discovery); err != nil {
+				return err
+			}
+		}
+
+		// The default redirect url is served by the callback listener of this command and is known to work.
+		if w := schemeWarning(redirectUrl, frontend); w != "" && (cmd.Flags().Changed("redirect") || setByRequestConfig(cmd, "redirect")) {
+			warn(w)
+		}
+
+		keepOpen, _ := cmd.Flags().GetBool("keep-server-open")
+		if keepOpen {
+			// These flags check or post-process the single token of a flow.
+			for _, name := range []string{"manual", "code-fifo", "print-authorize-only", "code-only", "response-type", "prefer-refresh", "verify", "verify-via-introspection", "userinfo", "assert-fresh", "assert-scopes", "expect-consent", "expect-no-consent", "bundle-out", "claims-schema"} {
+				if cmd.Flags().Changed(name) {
+					return newExitError(exitCodeConfig, errors.Errorf("Flag --keep-server-open can not be used with --%s", name))
+				}
+			}
+		}
+
+		verify, _ := cmd.Flags().GetBool("verify")
+		if keyID, _ := cmd.Flags().GetString("expected-kid"); keyID != "" && !verify {
+			return newExitError(exitCodeConfig, errors.New("Flag --expected-kid can only be used with --verify, the kid of the ID token is printed either way"))
 		}
 
 		conf := oauth2.Config{
@@ -79,83 +460,1022 @@ var tokenUserCmd = &cobra.Command{
 			Scopes:      scopes,
 		}
 
-		state, err := sequence.RuneSequence(24, sequence.AlphaLower)
-		pkg.Must(err, "Could not generate random state: %s", err)
+		if ok, _ := cmd.Flags().GetBool("print-client-config"); ok {
+			printClientConfig(os.Stderr, &conf, authStyle, sources)
+		}
+
+		out, _ := cmd.Flags().GetString("out")
+		requireIDToken, _ := cmd.Flags().GetBool("require-id-token")
+		codeOnly, _ := cmd.Flags().GetBool("code-only")
+		responseType, _ := cmd.Flags().GetString("response-type")
+		switch responseType {
+		case "code":
+		case "none":
+			// The flow ends with the authorize response, there is no code to exchange.
+			codeOnly = true
+		default:
+			return newExitError(exitCodeConfig, errors.Errorf(`Unknown value "%s" for flag --response-type, expected one of: code, none`, responseType))
+		}
+		if ok, _ := cmd.Flags().GetBool("prefer-refresh"); ok && printAuthorizeOnly {
+			return newExitError(exitCodeConfig, errors.New("Flags --prefer-refresh and --print-authorize-only can not be used together"))
+		} else if ok && codeOnly {
+			return newExitError(exitCodeConfig, errors.New("Flags --prefer-refresh and --code-only can not be used together"))
+		} else if ok && out != "" {
+			if stored, err := readTokenFile(out); err != nil {
+				warn("Could not read stored token, falling back to the browser flow: %s", err)
+			} else if stored.RefreshToken == "" {
+				warn("Stored token in %s has no refresh token, falling back to the browser flow.", out)
+			} else if token, err := conf.TokenSource(ctx, &oauth2.Token{RefreshToken: stored.RefreshToken}).Token(); err != nil {
+				warn("Could not refresh the stored token, falling back to the browser flow: %s", describeTokenError(err))
+			} else if requireIDToken && idTokenMissingAfterRefresh(token, stored) {
+				warn("The server did not issue a new ID token when refreshing the stored token, falling back to the browser flow because of --require-id-token.")
+			} else {
+				if idTokenMissingAfterRefresh(token, stored) {
+					printIDTokenRefreshNote(infoWriter(format), token)
+				}
+				issued = token
+				if err := printToken(cmd, token); err != nil {
+					return err
+				}
+				copyTokenToClipboard(cmd, token)
+				return writeTokenFile(out, token)
+			}
+		}
 
-		nonce, err := sequence.RuneSequence(24, sequence.AlphaLower)
-		pkg.Must(err, "Could not generate random state: %s", err)
+		var cachePath string
+		var cacheKey tokenCacheKey
+		useCache := tokenCacheEligible(cmd)
+		if useCache {
+			if cachePath, err = tokenCachePath(cmd); err != nil {
+				warn("Not using the token cache: %s", err)
+				useCache = false
+			}
+		}
+		if useCache {
+			audiences, _ := cmd.Flags().GetStringSlice("audience")
+			resources, _ := cmd.Flags().GetStringSlice("resource")
+			tokenParams, _ := cmd.Flags().GetStringArray("token-param")
+			cacheKey = newTokenCacheKey(backend, clientId, scopes, audiences, resources, tokenParams)
+			if token := lookupTokenCache(ctx, infoWriter(format), cachePath, cacheKey, &conf); token != nil {
+				issued = token
+				if err := printToken(cmd, token); err != nil {
+					return err
+				}
+				copyTokenToClipboard(cmd, token)
+				if out != "" {
+					return writeTokenFile(out, token)
+				}
+				return nil
+			}
+		}
 
-		location := conf.AuthCodeURL(string(state)) + "&nonce=" + string(nonce)
+		state, nonce := newAuthorizeSecrets()
 
-		if ok, _ := cmd.Flags().GetBool("no-open"); !ok {
-			webbrowser.Open(location)
+		var opts []oauth2.AuthCodeOption
+		if responseType != "code" {
+			opts = append(opts, oauth2.SetAuthURLParam("response_type", responseType))
 		}
+		if hint, _ := cmd.Flags().GetString("login-hint"); hint != "" {
+			opts = append(opts, oauth2.SetAuthURLParam("login_hint", hint))
+		}
+		maxAge, _ := cmd.Flags().GetInt("max-age")
+		assertFresh, _ := cmd.Flags().GetBool("assert-fresh")
+		if maxAge >= 0 {
+			opts = append(opts, oauth2.SetAuthURLParam("max_age", strconv.Itoa(maxAge)))
+		}
+		callbackResponse, _ := cmd.Flags().GetString("callback-response")
+		if callbackResponse != "html" && callbackResponse != "json" {
+			return newExitError(exitCodeConfig, errors.Errorf(`Unknown value "%s" for flag --callback-response, expected one of: html, json`, callbackResponse))
+		}
+		if display, _ := cmd.Flags().GetString("display"); display != "" {
+			switch display {
+			case "page", "popup", "touch", "wap":
+				opts = append(opts, oauth2.SetAuthURLParam("display", display))
+			default:
+				return newExitError(exitCodeConfig, errors.Errorf(`Unknown value "%s" for flag --display, expected one of: page, popup, touch, wap`, display))
+			}
+		}
+		if locales, _ := cmd.Flags().GetString("claims-locales"); locales != "" {
+			opts = append(opts, oauth2.SetAuthURLParam("claims_locales", locales))
+		}
+		audiences, _ := cmd.Flags().GetStringSlice("audience")
+		if len(audiences) > 0 {
+			opts = append(opts, oauth2.SetAuthURLParam("audience", strings.Join(audiences, " ")))
+		}
+		idTokenHint, _ := cmd.Flags().GetString("id-token-hint")
+		hintFile, _ := cmd.Flags().GetString("id-token-hint-file")
+		if idTokenHint, err = readIDTokenHint(idTokenHint, hintFile); err != nil {
+			return newExitError(exitCodeConfig, err)
+		}
+		if idTokenHint != "" {
+			opts = append(opts, oauth2.SetAuthURLParam("id_token_hint", idTokenHint))
+		}
+		if prompt, _ := cmd.Flags().GetString("prompt"); prompt != "" {
+			if given := authParams.Get("prompt"); given != "" {
+				return newExitError(exitCodeConfig, errors.Errorf("Flags --prompt and --auth-param prompt=%s can not be used together", given))
+			}
+			if err := checkPrompt(prompt); err != nil {
+				return newExitError(exitCodeConfig, err)
+			}
+			authParams.Set("prompt", prompt)
+		}
+		silent, _ := cmd.Flags().GetBool("silent")
+		if silent {
+			if prompt := authParams.Get("prompt"); prompt != "" && prompt != "none" {
+				return newExitError(exitCodeConfig, errors.Errorf(`Flag --silent sends prompt=none and can not be used with prompt=%s`, prompt))
+			}
+			opts = append(opts, oauth2.SetAuthURLParam("prompt", "none"))
+		}
+		// oauth2.SetAuthURLParam sets a single value, the other values of a repeated --auth-param are added below.
+		repeatedParams := url.Values{}
+		for key, values := range authParams {
+			opts = append(opts, oauth2.SetAuthURLParam(key, values[0]))
+			if len(values) > 1 {
+				repeatedParams[key] = values[1:]
+			}
+		}
+
+		resources, _ := cmd.Flags().GetStringSlice("resource")
+		keyPath, _ := cmd.Flags().GetString("request-object-key")
+		par, _ := cmd.Flags().GetBool("par")
+
+		// authorizeURL returns the authorization url of a flow using state and nonce. --keep-server-open builds a new
+		// one for every flow so that no state or nonce is accepted twice.
+		authorizeURL := func(state, nonce string) (string, error) {
+			authOpts := append([]oauth2.AuthCodeOption{oauth2.SetAuthURLParam("nonce", nonce)}, opts...)
+			location := conf.AuthCodeURL(state, authOpts...)
+			if len(resources) > 0 || len(repeatedParams) > 0 {
+				// The resource parameter of RFC 8707 is repeated, which oauth2.SetAuthURLParam does not support.
+				u, err := url.Parse(location)
+				if err != nil {
+					return "", newExitError(exitCodeConfig, errors.Wrap(err, "Could not parse the authorization url"))
+				}
+				query := u.Query()
+				for _, resource := range resources {
+					query.Add("resource", resource)
+				}
+				for key, values := range repeatedParams {
+					query[key] = append(query[key], values...)
+				}
+				u.RawQuery = query.Encode()
+				location = u.String()
+			}
+
+			if keyPath != "" {
+				kid, _ := cmd.Flags().GetString("request-object-kid")
+				request, err := signRequestObject(location, issuerURL, keyPath, kid)
+				if err != nil {
+					return "", newExitError(exitCodeConfig, errors.Wrap(err, "could not sign request object"))
+				}
+				location = conf.AuthCodeURL(state, append(authOpts, oauth2.SetAuthURLParam("request", request))...)
+			}
 
-		fmt.Println("Setting up callback listener on http://localhost:4445/callback")
-		fmt.Println("Press ctrl + c on Linux / Windows or cmd + c on OSX to end the process.")
-		fmt.Printf("If your browser does not open automatically, navigate to:\n\n\t%s\n\n", location)
+			if par {
+				if discovery == nil {
+					var err error
+					if discovery, err = fetchDiscovery(ctx, issuerURL); err != nil {
+						return "", newExitError(exitCodeConfig, err)
+					}
+				}
+				if discovery.PushedAuthorizationRequestEndpoint == "" {
+					return "", newExitError(exitCodeConfig, errors.New("the discovery document does not advertise a pushed_authorization_request_endpoint"))
+				}
+				pushed, err := pushAuthorizationRequest(ctx, discovery.PushedAuthorizationRequestEndpoint, location, clientId, clientSecret, authStyle, assertion)
+				if err != nil {
+					return "", newExitError(exitCodeConfig, err)
+				}
+				location = pushed
+			}
+			return location, nil
+		}
+
+		location, err := authorizeURL(state, nonce)
+		if err != nil {
+			return err
+		}
+
+		if printAuthorizeOnly {
+			fmt.Println(location)
+			return nil
+		}
 
-		r := httprouter.New()
-		server := &http.Server{Addr: ":4445", Handler: r}
-		r.GET("/callback", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-			if r.URL.Query().Get("error") != "" {
-				message := fmt.Sprintf("Got error: %s", r.URL.Query().Get("error_description"))
-				fmt.Println(message)
+		expectConsent, _ := cmd.Flags().GetBool("expect-consent")
+		expectNoConsent, _ := cmd.Flags().GetBool("expect-no-consent")
+		if expectConsent && expectNoConsent {
+			return newExitError(exitCodeConfig, errors.New("Flags --expect-consent and --expect-no-consent can not be used together"))
+		}
 
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(message))
-				return
+		// Pushed authorization requests are not checked because the request_uri may only be used once.
+		if skip, _ := cmd.Flags().GetBool("skip-preflight"); !skip && !par {
+			if err := preflightAuthorize(ctx, location, redirectUrl); err != nil {
+				return newExitError(exitCodeConfig, err)
 			}
+		}
+
+		presented := time.Now()
+		if ok, _ := cmd.Flags().GetBool("no-open"); !ok {
+			openBrowser(cmd, location)
+		}
 
-			if r.URL.Query().Get("state") != string(state) {
-				message := fmt.Sprintf("States do not match. Expected %s, got %s", string(state), r.URL.Query().Get("state"))
-				fmt.Println(message)
+		info := infoWriter(format)
+		if maxAge == 0 && !assertFresh {
+			fmt.Fprintln(info, "Note: --max-age 0 forces the user to authenticate again, use --assert-fresh to fail if the server reused the session instead.")
+		}
+
+		// flowLock guards state, nonce and location, which --keep-server-open replaces after every flow.
+		var flowLock sync.Mutex
+
+		// complete validates the authorize response and exchanges the authorization code for a token.
+		complete := func(query url.Values) callbackResult {
+			if query.Get("error") != "" {
+				message := fmt.Sprintf("Got error: %s", query.Get("error_description"))
+				if silent {
+					message = silentAuthFailure(query.Get("error"), query.Get("error_description"))
+				}
+				if uri := query.Get("error_uri"); uri != "" {
+					message = fmt.Sprintf("%s (see %s)", message, uri)
+				}
+				if format == "json" {
+					printJSON(&callbackErrorOutput{
+						Error:            query.Get("error"),
+						ErrorDescription: query.Get("error_description"),
+						ErrorURI:         query.Get("error_uri"),
+					})
+				}
+				return callbackResult{err: newExitError(exitCodeCallbackError, errors.New(message))}
+			}
 
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(message))
-				return
+			flowLock.Lock()
+			expected := state
+			flowLock.Unlock()
+			if query.Get("state") != expected {
+				message := fmt.Sprintf("States do not match. Expected %s, got %s", expected, query.Get("state"))
+				return callbackResult{err: newExitError(exitCodeStateMismatch, errors.New(message))}
 			}
 
-			code := r.URL.Query().Get("code")
+			code := query.Get("code")
+			if responseType == "none" && code != "" {
+				warn("The authorize response contains a code although response_type=none was requested")
+			}
+			if codeOnly {
+				return callbackResult{code: code, sessionState: query.Get("session_state")}
+			}
 			token, err := conf.Exchange(ctx, code)
-			pkg.Must(err, "Could not exchange code for token: %s", err)
+			if err != nil {
+				message := fmt.Sprintf("Could not exchange code for token: %s", describeTokenError(err))
+				return callbackResult{err: newContextExitError(ctx, exitCodeExchange, errors.New(message))}
+			}
+			return callbackResult{token: token, code: code, sessionState: query.Get("session_state")}
+		}
+
+		var result callbackResult
+		if fifo != "" {
+			fmt.Fprintf(info, "Navigate to the following url and log in:\n\n\t%s\n\n", location)
+			fmt.Fprintf(os.Stderr, "Waiting for the authorize response to be written to %s\n", fifo)
+
+			line, err := readFIFO(ctx, fifo)
+			if err != nil && strings.TrimSpace(line) == "" {
+				return newContextExitError(ctx, exitCodeCallbackError, errors.Wrapf(err, "Could not read the authorize response from %s", fifo))
+			}
+			query, err := fifoResponseParams(strings.TrimSpace(line), responseType)
+			if err != nil {
+				return newExitError(exitCodeCallbackError, err)
+			}
+			result = complete(query)
+		} else if manual {
+			fmt.Fprintf(info, "Navigate to the following url and log in:\n\n\t%s\n\n", location)
+			fmt.Fprintln(os.Stderr, "Paste the url your browser was redirected to and press enter:")
+
+			line, err := readLine(ctx, os.Stdin)
+			if err != nil && strings.TrimSpace(line) == "" {
+				return newContextExitError(ctx, exitCodeCallbackError, errors.Wrap(err, "Could not read the redirect url"))
+			}
+			query, err := authorizeResponseParams(strings.TrimSpace(line), responseType)
+			if err != nil {
+				return newExitError(exitCodeCallbackError, err)
+			}
+			result = complete(query)
+		} else {
+			var trace io.Writer
+			if ok, _ := cmd.Flags().GetBool("trace"); ok {
+				trace = os.Stderr
+			}
+
+			// The spinner would garble the trace output and machine readable output is not meant for humans.
+			quiet, _ := cmd.Flags().GetBool("quiet")
+			progress := !quiet && trace == nil && format == "text" && !keepOpen
+
+			var each func(callbackResult) bool
+			var keepOpenErr error
+			if keepOpen {
+				var lock sync.Mutex
+				captured := 0
+				each = func(result callbackResult) bool {
+					lock.Lock()
+					defer lock.Unlock()
+					if result.err != nil {
+						warn("The callback failed: %s", result.err)
+					} else {
+						captured++
+						fmt.Fprintf(info, "Token %d:\n\n", captured)
+						if err := printTokenOutput(cmd, result.token, &TokenExtras{SessionState: result.sessionState}); err != nil {
+							warn("%s", err)
+						}
+						copyTokenToClipboard(cmd, result.token)
+						if out != "" {
+							if err := writeTokenFile(out, result.token); err != nil {
+								warn("Could not write token file: %s", err)
+							}
+						}
+					}
+
+					// A callback with a wrong state does not end the flow of the current url.
+					if e, ok := result.err.(*exitError); ok && e.code == exitCodeStateMismatch {
+						fmt.Fprintf(info, "The callback listener is still running, navigate to the following url to acquire another token or press ctrl + c to stop:\n\n\t%s\n\n", location)
+						return true
+					}
+
+					nextState, nextNonce := newAuthorizeSecrets()
+					next, err := authorizeURL(nextState, nextNonce)
+					if err != nil {
+						keepOpenErr = err
+						return false
+					}
+					flowLock.Lock()
+					state, nonce, location = nextState, nextNonce, next
+					flowLock.Unlock()
+					fmt.Fprintf(info, "The callback listener is still running, navigate to the following url to acquire another token or press ctrl + c to stop:\n\n\t%s\n\n", next)
+					return true
+				}
+			}
+			result = waitForCallback(ctx, info, trace, progress, listener, location, callbackResponse, complete, each)
+			if keepOpenErr != nil {
+				return errors.Wrap(keepOpenErr, "Could not build the authorization url of the next flow")
+			}
+		}
+
+		if result.err != nil {
+			return result.err
+		}
+		if codeOnly {
+			if responseType == "none" {
+				fmt.Fprintln(info, "The authorization server redirected back without error as expected for response_type=none.")
+				fmt.Fprintln(info)
+			}
+			printCodeOutput(format, &codeOutput{Code: result.code, State: state, SessionState: result.sessionState})
+			return nil
+		}
+		issued = result.token
+		elapsed := time.Since(presented)
+
+		if err := printTokenOutput(cmd, result.token, &TokenExtras{SessionState: result.sessionState}); err != nil {
+			return err
+		}
+		copyTokenToClipboard(cmd, result.token)
+		if silent {
+			fmt.Fprintln(info, "Silent authentication succeeded, the session at the server is still active.")
+			fmt.Fprintln(info)
+			if idTokenHint != "" {
+				if err := checkHintSubject(idTokenHint, result.token); err != nil {
+					return newExitError(exitCodeVerification, err)
+				}
+			}
+		}
+		if idt, ok := result.token.Extra("id_token").(string); ok && idt != "" {
+			fmt.Fprintf(info, "Key ID:\n\t%s\n\n", orNone(tokenKeyID(idt)))
+			if err := checkTokenHashes(info, idt, result.token.AccessToken, result.code, verify); err != nil {
+				return err
+			}
+		}
+		if out != "" {
+			if err := writeTokenFile(out, result.token); err != nil {
+				return err
+			}
+		}
+		if useCache {
+			if err := updateTokenCache(cachePath, cacheKey, newTokenOutput(result.token)); err != nil {
+				warn("Could not update the token cache: %s", err)
+			}
+		}
+		if err := checkGrantedScopes(scopes, result.token, assertScopes); err != nil {
+			return err
+		}
+		if (expectConsent || expectNoConsent) && manual {
+			warn("Flags --expect-consent and --expect-no-consent are ignored with --manual because pasting the url takes too long to detect whether consent was skipped.")
+		} else {
+			threshold, _ := cmd.Flags().GetDuration("consent-threshold")
+			if err := checkConsentExpectation(elapsed, threshold, expectConsent, expectNoConsent); err != nil {
+				return err
+			}
+		}
+		if bundleOut, _ := cmd.Flags().GetString("bundle-out"); bundleOut != "" {
+			if discovery == nil {
+				if discovery, err = fetchDiscovery(ctx, issuerURL); err != nil {
+					warn("The bundle will not contain the discovery document: %s", err)
+				}
+			}
+			if err := writeBundleFile(bundleOut, newTokenBundle(clientId, scopes, result.token, discovery)); err != nil {
+				return err
+			}
+		}
+
+		if ok, _ := cmd.Flags().GetBool("dry-verify"); ok {
+			printUnverifiedIDToken(info, result.token)
+		}
+
+		if assertFresh {
+			if err := checkFreshLogin(result.token, presented.Add(-freshLoginSkew)); err != nil {
+				return newExitError(exitCodeVerification, errors.Wrap(err, "The server did not authenticate the user again"))
+			}
+			fmt.Fprintln(info, "The auth_time of the ID token shows that the user authenticated during this flow.")
+			fmt.Fprintln(info)
+		}
+
+		if verify {
+			jwksURL, _ := cmd.Flags().GetString("jwks-url")
+			jwksFile, _ := cmd.Flags().GetString("jwks-file")
+			if jwksURL == "" {
+				jwksURL = endpoints.JWKs
+			}
+			audiences, _ := cmd.Flags().GetStringSlice("expected-audience")
+			signingAlg, _ := cmd.Flags().GetString("id-token-signing-alg")
+			subject, _ := cmd.Flags().GetString("expected-subject")
+			keyID, _ := cmd.Flags().GetString("expected-kid")
+			issuer, _ := cmd.Flags().GetString("expected-issuer")
+			if issuer == "" {
+				if discovery == nil {
+					if discovery, err = fetchDiscovery(ctx, issuerURL); err != nil {
+						return newExitError(exitCodeVerification, errors.Wrap(err, "Could not determine the expected issuer, use --expected-issuer"))
+					}
+				}
+				issuer = discovery.Issuer
+			}
 
-			fmt.Printf("Access Token:\n\t%s\n", token.AccessToken)
-			fmt.Printf("Refresh Token:\n\t%s\n\n", token.RefreshToken)
-			fmt.Printf("Expires in:\n\t%s\n\n", token.Expiry)
+			verification, claims := verifyToken(ctx, result.token, verifyOptions{
+				JWKsURL:           jwksURL,
+				JWKsFile:          jwksFile,
+				ClientID:          clientId,
+				Nonce:             nonce,
+				Issuer:            issuer,
+				ExpectedAudiences: audiences,
+				SigningAlg:        signingAlg,
+				Subject:           subject,
+				KeyID:             keyID,
+			})
+			verification.report(info)
+			if claims != nil {
+				fmt.Fprintf(info, "Subject:\n\t%v\n\n", claims["sub"])
+			}
+
+			if claims != nil {
+				out, err := json.MarshalIndent(claims, "\t", "\t")
+				pkg.Must(err, "Could not encode ID token claims: %s", err)
+				fmt.Fprintf(info, "ID Token Claims:\n\t%s\n\n", out)
+				if localized := localizedClaims(claims); len(localized) > 0 {
+					fmt.Fprintf(info, "Localized Claims:\n\t%s\n\n", strings.Join(localized, "\n\t"))
+				}
+			}
+
+			if verification.failed() {
+				return newExitError(exitCodeVerification, errors.New("The token verification failed"))
+			}
+		}
+
+		if claimsSchema != nil {
+			if err := checkIDTokenClaimsSchema(info, claimsSchema, result.token); err != nil {
+				return newExitError(exitCodeVerification, err)
+			}
+		}
 
-			w.Write([]byte(fmt.Sprintf(`
+		if ok, _ := cmd.Flags().GetBool("verify-via-introspection"); ok {
+			introspection, err := introspectAccessToken(cmd, result.token.AccessToken)
+			if introspection != "" {
+				fmt.Fprintf(info, "Introspection:\n\t%s\n\n", introspection)
+			}
+			if err != nil {
+				return newExitError(exitCodeVerification, err)
+			}
+		}
+
+		if ok, _ := cmd.Flags().GetBool("userinfo"); ok {
+			endpoint, _ := cmd.Flags().GetString("userinfo-url")
+			if endpoint == "" {
+				if discovery == nil {
+					if discovery, err = fetchDiscovery(ctx, issuerURL); err != nil {
+						warn("Could not fetch the discovery document, falling back to %s: %s", endpoints.Userinfo, err)
+					}
+				}
+				if discovery != nil && discovery.UserinfoEndpoint != "" {
+					endpoint = discovery.UserinfoEndpoint
+				} else {
+					endpoint = endpoints.Userinfo
+				}
+			}
+			acceptLanguage, _ := cmd.Flags().GetString("accept-language")
+			userinfo, err := fetchUserinfo(ctx, endpoint, result.token.AccessToken, acceptLanguage)
+			if err != nil {
+				return newContextExitError(ctx, exitCodeVerification, err)
+			}
+			userinfo.report(info)
+		}
+
+		if err := checkJWTAccessTokenAudFlag(cmd, info, result.token); err != nil {
+			return err
+		}
+
+		if resource, _ := cmd.Flags().GetString("probe-resource"); resource != "" {
+			follow, _ := cmd.Flags().GetBool("probe-follow-redirects")
+			probe, err := probeResource(ctx, resource, result.token.AccessToken, follow)
+			if err != nil {
+				return newContextExitError(ctx, exitCodeProbe, err)
+			}
+			probe.report(info)
+			if !probe.succeeded() {
+				return newExitError(exitCodeProbe, errors.Errorf("The resource responded with status %s", probe.Status))
+			}
+		}
+		return nil
+	},
+}
+
+const (
+	// defaultCallbackPort is the port of the default redirect url.
+	defaultCallbackPort = 4445
+
+	// callbackPortFallbacks is the number of ports after defaultCallbackPort tried if it is in use.
+	callbackPortFallbacks = 10
+)
+
+// newAuthorizeSecrets returns a random state and nonce for an authorization request.
+func newAuthorizeSecrets() (string, string) {
+	state, err := sequence.RuneSequence(24, sequence.AlphaLower)
+	pkg.Must(err, "Could not generate random state: %s", err)
+
+	nonce, err := sequence.RuneSequence(24, sequence.AlphaLower)
+	pkg.Must(err, "Could not generate random nonce: %s", err)
+	return string(state), string(nonce)
+}
+
+// startCallbackListener binds the callback listener, either the socket passed by --listen-fd or the default callback
+// port. If that port is in use and --redirect is not set, the listener falls back to the next free port and the
+// returned redirect url points to it.
+func startCallbackListener(cmd *cobra.Command, info io.Writer, redirectURL string) (net.Listener, string, error) {
+	if fd, _ := cmd.Flags().GetInt("listen-fd"); fd >= 0 {
+		listener, err := net.FileListener(os.NewFile(uintptr(fd), "listen-fd"))
+		if err != nil {
+			return nil, "", newExitError(exitCodeConfig, errors.Wrapf(err, "Could not use file descriptor %d passed by --listen-fd as callback listener", fd))
+		}
+		fmt.Fprintf(info, "Using the socket passed as file descriptor %d for the callback listener\n", fd)
+		return listener, redirectURL, nil
+	}
+
+	bindAll, _ := cmd.Flags().GetBool("bind-all")
+	// Other ports are only tried for the default redirect url, which can be changed to match the port.
+	fallback := !cmd.Flags().Changed("redirect") && !setByRequestConfig(cmd, "redirect")
+	listener, port, err := listenCallback(bindAll, defaultCallbackPort, fallback)
+	if err != nil {
+		return nil, "", newExitError(exitCodeConfig, errors.Wrap(err, "Could not start the callback listener"))
+	}
+	if port != defaultCallbackPort {
+		redirectURL = fmt.Sprintf("http://localhost:%d/callback", port)
+		fmt.Fprintf(info, "Port %d is in use, using port %d for the callback listener instead\n", defaultCallbackPort, port)
+		warn("The redirect url is %s, it must be registered for the client if the server matches redirect urls strictly.", redirectURL)
+	}
+	fmt.Fprintf(info, "Setting up callback listener on http://localhost:%d/callback\n", port)
+	return listener, redirectURL, nil
+}
+
+// listenCallback binds the callback listener to port. If fallback is set and port can not be bound, for example
+// because another instance of this command is running, the next callbackPortFallbacks ports are tried.
+func listenCallback(bindAll bool, port int, fallback bool) (net.Listener, int, error) {
+	listener, err := net.Listen("tcp", callbackAddress(bindAll, strconv.Itoa(port)))
+	if err == nil || !fallback {
+		return listener, port, err
+	}
+
+	for next := port + 1; next <= port+callbackPortFallbacks; next++ {
+		if listener, err := net.Listen("tcp", callbackAddress(bindAll, strconv.Itoa(next))); err == nil {
+			return listener, next, nil
+		}
+	}
+	return nil, 0, errors.Wrapf(err, "ports %d to %d are in use", port, port+callbackPortFallbacks)
+}
+
+// callbackAddress returns the address local listeners bind. Only the loopback interface is bound unless bindAll
+// is set, so that other hosts on the network can not send requests to the listener.
+func callbackAddress(bindAll bool, port string) string {
+	if bindAll {
+		return net.JoinHostPort("", port)
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// waitForCallback serves the callback listener until the browser was redirected to it once. Every request
+// received by the listener is logged to trace if it is set, a spinner is shown while waiting if progress is set.
+// The server is stopped and listener closed once the flow finished. The browser is shown an HTML page unless
+// response is "json". If each is set, it is called with every result and the listener keeps serving as long as
+// it returns true.
+func waitForCallback(ctx context.Context, info, trace io.Writer, progress bool, listener net.Listener, location, response string, complete func(url.Values) callbackResult, each func(callbackResult) bool) callbackResult {
+	fmt.Fprintln(info, "Press ctrl + c on Linux / Windows or cmd + c on OSX to end the process.")
+	fmt.Fprintf(info, "If your browser does not open automatically, navigate to:\n\n\t%s\n\n", location)
+
+	results := make(chan callbackResult, 1)
+	finish := func(result callbackResult) {
+		select {
+		case results <- result:
+		default:
+			// Another callback has already finished the flow.
+		}
+	}
+
+	r := httprouter.New()
+	server := &http.Server{Handler: traceCallback(trace, r)}
+	r.GET("/callback", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		result := complete(r.URL.Query())
+		if response == "json" {
+			writeCallbackJSON(w, result)
+		} else {
+			writeCallbackHTML(w, result)
+		}
+		if each != nil && each(result) {
+			return
+		}
+		finish(result)
+	})
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			finish(callbackResult{err: errors.Wrap(err, "Could not start the callback listener")})
+		}
+	}()
+
+	var s *spinner
+	if progress {
+		s = startSpinner("Waiting for authorization...")
+	}
+	var result callbackResult
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		result = callbackResult{err: newContextExitError(ctx, exitCodeCallbackError, errors.Wrap(ctx.Err(), "Stopped waiting for the callback"))}
+	}
+	s.stop()
+
+	shutdown, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	server.Shutdown(shutdown)
+	return result
+}
+
+// writeCallbackHTML shows the result of the flow in the browser.
+func writeCallbackHTML(w http.ResponseWriter, result callbackResult) {
+	if result.err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(result.err.Error()))
+		return
+	}
+
+	token := result.token
+	if token == nil && result.code == "" {
+		w.Write([]byte("<html><head></head><body>The authorization server redirected back without error, you may close this window.</body></html>"))
+		return
+	} else if token == nil {
+		w.Write([]byte(fmt.Sprintf("<html><head></head><body>Authorization Code: <code>%s</code></body></html>", result.code)))
+		return
+	}
+	w.Write([]byte(fmt.Sprintf(`
 <html><head></head><body>
 <ul>
 	<li>Access Token: <code>%s</code></li>
+	<li>Access Token Format: <code>%s</code></li>
 	<li>Refresh Token: <code>%s</code></li>
 	<li>Expires in: <code>%s</code></li>
-`, token.AccessToken, token.RefreshToken, token.Expiry)))
+`, token.AccessToken, accessTokenFormat(token.AccessToken), token.RefreshToken, token.Expiry)))
 
-			idt := token.Extra("id_token")
-			if idt != nil {
-				w.Write([]byte(fmt.Sprintf(`<li>ID Token: <code>%s</code></li>`, idt)))
-				fmt.Printf("ID Token:\n\t%s\n\n", idt)
-			}
-			w.Write([]byte("</ul></body></html>"))
+	idt := token.Extra("id_token")
+	if idt != nil {
+		w.Write([]byte(fmt.Sprintf(`<li>ID Token: <code>%s</code></li>`, idt)))
+	}
+	w.Write([]byte("</ul></body></html>"))
+}
 
-			go func() {
-				time.Sleep(time.Second * 1)
-				ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
-				server.Shutdown(ctx)
-			}()
-		})
-		server.ListenAndServe()
-	},
+// writeCallbackJSON returns the result of the flow as JSON, for headless browsers driven by test harnesses.
+func writeCallbackJSON(w http.ResponseWriter, result callbackResult) {
+	out := &callbackResponseOutput{Status: "success", Code: result.code, SessionState: result.sessionState}
+	w.Header().Set("Content-Type", "application/json")
+	if result.err != nil {
+		out = &callbackResponseOutput{Status: "error", Error: result.err.Error()}
+		w.WriteHeader(http.StatusInternalServerError)
+	} else if result.token != nil {
+		out.Token = newTokenOutputWithExtras(result.token, &TokenExtras{SessionState: result.sessionState})
+	}
+	writeJSON(w, out)
+}
+
+// traceCallback logs the method, the path and the names of the query parameters of each request. Query values
+// are never logged because they contain the authorization code.
+func traceCallback(trace io.Writer, next http.Handler) http.Handler {
+	if trace == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := make([]string, 0, len(r.URL.Query()))
+		for key := range r.URL.Query() {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) == 0 {
+			keys = append(keys, "none")
+		}
+		fmt.Fprintf(trace, "Callback listener received %s %s (query parameters: %s)\n", r.Method, r.URL.Path, strings.Join(keys, ", "))
+		for _, identifier := range consentIdentifiers(r) {
+			fmt.Fprintf(trace, "Callback listener observed %s\n", identifier)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// consentParameters are the query parameters which identify a login or consent request in the consent database.
+// The consent flow of this version uses "consent", later versions use the challenges.
+var consentParameters = []string{"consent", "login_challenge", "consent_challenge"}
+
+// consentIdentifiers returns the login and consent request identifiers found in the callback query or its
+// Referer header, which browsers may set to the page of the consent app. Only identifiers which are actually
+// present are returned.
+func consentIdentifiers(r *http.Request) []string {
+	identifiers := findConsentIdentifiers(r.URL.Query(), "in the callback query")
+	if referer, err := url.Parse(r.Referer()); err == nil && r.Referer() != "" {
+		location := fmt.Sprintf("in the Referer header %s://%s%s", referer.Scheme, referer.Host, referer.Path)
+		identifiers = append(identifiers, findConsentIdentifiers(referer.Query(), location)...)
+	}
+	return identifiers
+}
+
+func findConsentIdentifiers(query url.Values, location string) []string {
+	var identifiers []string
+	for _, key := range consentParameters {
+		if value := query.Get(key); value != "" {
+			identifiers = append(identifiers, fmt.Sprintf("%s %s %s", key, value, location))
+		}
+	}
+	return identifiers
+}
+
+// readLine reads a line from r, it gives up once ctx is done.
+func readLine(ctx context.Context, r io.Reader) (string, error) {
+	type line struct {
+		value string
+		err   error
+	}
+
+	lines := make(chan line, 1)
+	go func() {
+		value, err := bufio.NewReader(r).ReadString('\n')
+		lines <- line{value: value, err: err}
+	}()
+
+	select {
+	case l := <-lines:
+		return l.value, l.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// isAuthorizeResponse tells if query is an authorize response: it contains a code or an error, or only the state
+// for the successful response of response_type=none.
+func isAuthorizeResponse(query url.Values, responseType string) bool {
+	if query.Get("code") != "" || query.Get("error") != "" {
+		return true
+	}
+	return responseType == "none" && query.Get("state") != ""
+}
+
+// authorizeResponseParams extracts the authorize response parameters from a pasted redirect url. They are
+// read from the query or, for response_mode=fragment, from the fragment.
+func authorizeResponseParams(redirect, responseType string) (url.Values, error) {
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not parse the redirect url")
+	}
+
+	query := u.Query()
+	if !isAuthorizeResponse(query, responseType) && u.Fragment != "" {
+		if query, err = url.ParseQuery(u.Fragment); err != nil {
+			return nil, errors.Wrap(err, "Could not parse the fragment of the redirect url")
+		}
+	}
+	if !isAuthorizeResponse(query, responseType) {
+		if responseType == "none" {
+			return nil, errors.New("The redirect url contains neither a state nor an error parameter")
+		}
+		return nil, errors.New("The redirect url contains neither a code nor an error parameter")
+	}
+	return query, nil
+}
+
+// readFIFO reads a line from the named pipe at path, it gives up once ctx is done. Opening a named pipe blocks
+// until the other process opened it for writing, so the file is opened in the background as well.
+func readFIFO(ctx context.Context, path string) (string, error) {
+	type line struct {
+		value string
+		err   error
+	}
+
+	lines := make(chan line, 1)
+	go func() {
+		f, err := os.Open(path)
+		if err != nil {
+			lines <- line{err: errors.WithStack(err)}
+			return
+		}
+		defer f.Close()
+		value, err := bufio.NewReader(f).ReadString('\n')
+		lines <- line{value: value, err: err}
+	}()
+
+	select {
+	case l := <-lines:
+		return l.value, l.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// fifoResponseParams accepts either the url the browser was redirected to or only its query, for example
+// "code=...&state=...", as written to --code-fifo by the process which captured the redirect.
+func fifoResponseParams(response, responseType string) (url.Values, error) {
+	if strings.ContainsAny(response, "?#") {
+		return authorizeResponseParams(response, responseType)
+	}
+
+	query, err := url.ParseQuery(response)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not parse the authorize response")
+	}
+	if !isAuthorizeResponse(query, responseType) {
+		if responseType == "none" {
+			return nil, errors.New("The authorize response contains neither a state nor an error parameter")
+		}
+		return nil, errors.New("The authorize response contains neither a code nor an error parameter")
+	}
+	return query, nil
+}
+
+// checkJWTAccessTokenAudFlag runs --jwt-access-token-aud-check: the aud claim of a JWT access token must contain
+// every value of --audience and --resource. A mismatch is a warning unless --jwt-access-token-aud-strict is set.
+func checkJWTAccessTokenAudFlag(cmd *cobra.Command, info io.Writer, token *oauth2.Token) error {
+	if ok, _ := cmd.Flags().GetBool("jwt-access-token-aud-check"); !ok {
+		return nil
+	}
+
+	audiences, _ := cmd.Flags().GetStringSlice("audience")
+	resources, _ := cmd.Flags().GetStringSlice("resource")
+	aud, missing, isJWT := checkAccessTokenAudience(token.AccessToken, append(append([]string{}, audiences...), resources...))
+	if !isJWT {
+		fmt.Fprintln(info, "Note: The access token is opaque, its audience can only be checked using introspection.")
+		fmt.Fprintln(info)
+		return nil
+	}
+
+	fmt.Fprintf(info, "Access Token Audience:\n\t%s\n\n", strings.Join(aud, "\n\t"))
+	if len(missing) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("The audience of the access token does not contain the requested audiences: %s", strings.Join(missing, ", "))
+	if strict, _ := cmd.Flags().GetBool("jwt-access-token-aud-strict"); strict {
+		return newExitError(exitCodeVerification, errors.New(message))
+	}
+	warn(message)
+	return nil
+}
+
+// checkBrowserCommand rejects a --browser-command which consists of whitespace only, openBrowser could not run it.
+func checkBrowserCommand(cmd *cobra.Command) error {
+	if command, _ := cmd.Flags().GetString("browser-command"); command != "" && strings.TrimSpace(command) == "" {
+		return errors.New("Flag --browser-command must contain a command")
+	}
+	return nil
+}
+
+// openBrowser opens the location using --browser-command or the default browser.
+func openBrowser(cmd *cobra.Command, location string) {
+	if command, _ := cmd.Flags().GetString("browser-command"); strings.TrimSpace(command) != "" {
+		parts := strings.Fields(command)
+		if err := exec.Command(parts[0], append(parts[1:], location)...).Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open browser using command \"%s\": %s\n", command, err)
+		}
+		return
+	}
+	webbrowser.Open(location)
+}
+
+// callbackResult is the outcome of a request to the callback listener.
+type callbackResult struct {
+	// token is nil with --code-only and --response-type none.
+	token *oauth2.Token
+	code  string
+	err   error
+
+	// sessionState is used by OpenID Connect Session Management to check the session using the check_session_iframe.
+	sessionState string
 }
 
 func init() {
 	tokenCmd.AddCommand(tokenUserCmd)
+	tokenUserCmd.Flags().String("grant-type", "authorization_code", "Select the flow, one of: authorization_code, client_credentials, refresh_token, device")
+	tokenUserCmd.Flags().String("refresh-token", "", "With --grant-type refresh_token, refresh this token instead of the one stored in --out")
+	tokenUserCmd.Flags().String("device-auth-url", "", "With --grant-type device, force the device authorization url, defaults to /oauth2/device/auth of the cluster url value from config file")
+	tokenUserCmd.Flags().Bool("create-client", false, "Create a throwaway OAuth 2.0 Client allowed to use --redirect, the scopes and the grant type, using the credentials from the config file")
+	tokenUserCmd.Flags().String("client-config-out", "", "With --create-client, write the created client to this file in the format of \"hydra clients import\", or reuse the client stored in it")
+	tokenUserCmd.Flags().Bool("cleanup-client", false, "With --create-client, delete the client and --client-config-out once the flow finished")
+	tokenUserCmd.Flags().Bool("interactive", false, "Pick the client and toggle its scopes in the terminal, at least one scope must be selected. The clients are listed using the credentials from the config file")
 	tokenUserCmd.Flags().Bool("no-open", false, "Do not open the browser window automatically")
-	tokenUserCmd.Flags().StringSlice("scopes", []string{"hydra", "offline", "openid"}, "Force scopes")
+	tokenUserCmd.Flags().Bool("print-authorize-only", false, "Only print the authorization url to stdout and exit, neither the browser nor the callback listener are started")
+	tokenUserCmd.Flags().Int("listen-fd", -1, "Serve the callback on the already bound socket with this file descriptor instead of binding port 4445, for example 3 with systemd socket activation")
+	tokenUserCmd.Flags().Bool("bind-all", false, "Bind the callback listener on all interfaces instead of 127.0.0.1 only, making it reachable from other hosts")
+	tokenUserCmd.Flags().Bool("trace", false, "Log every request received by the callback listener to stderr, query values are not logged except for login and consent identifiers")
+	tokenUserCmd.Flags().Bool("quiet", false, "Do not show a progress spinner while waiting for the callback")
+	tokenUserCmd.Flags().String("response-type", "code", "The response_type, one of: code, none. With none no tokens are issued and the flow succeeds once the server redirected back without error")
+	tokenUserCmd.Flags().Bool("code-only", false, "Print the authorization code and state from the callback without exchanging the code, to test the authorization endpoint on its own")
+	tokenUserCmd.Flags().Bool("manual", false, "Do not start the callback listener, instead paste the url the browser was redirected to")
+	tokenUserCmd.Flags().String("code-fifo", "", "Do not start the callback listener, instead read the redirect url or its query from this named pipe, for example created with mkfifo")
+	tokenUserCmd.Flags().String("browser-command", "", "Open the authorization url using this command instead of the default browser, the url is appended as the last argument")
+	tokenUserCmd.Flags().String("request-config", "", "Read the scopes, audience, resource, claims, prompt, acr_values and other request parameters from this JSON file, flags win over the file")
+	tokenUserCmd.Flags().StringSlice("scopes", []string{"hydra", "offline", "openid"}, "Force scopes, defaults to default_scopes from the config file if set")
+	tokenUserCmd.Flags().StringArray("scope", []string{}, "Request this scope, can be repeated and is merged with --scopes. The default of --scopes is not used when only --scope is set")
+	tokenUserCmd.Flags().String("scopes-from-token", "", "Request the scopes granted to the token stored in this file by a previous run with --out instead of --scopes")
+	tokenUserCmd.Flags().Bool("scopes-supported-check", false, "Warn before the flow if a requested scope is not advertised in scopes_supported of the discovery document, fail instead if --fail-on-warning is set")
+	tokenUserCmd.Flags().String("offline-scope", "auto", `How to request a refresh token, one of: auto, offline, offline_access, keep. Hydra uses "offline" while OpenID Connect defines "offline_access", "auto" picks the one in scopes_supported of the discovery document`)
 	tokenUserCmd.Flags().String("id", "", "Force a client id, defaults to value from config file")
 	tokenUserCmd.Flags().String("secret", "", "Force a client secret, defaults to value from config file")
+	tokenUserCmd.Flags().String("secret-keyring", "", "Read the client secret stored under this name from the keyring of the operating system instead of --secret or the config file")
 	tokenUserCmd.Flags().String("redirect", "http://localhost:4445/callback", "Force a redirect url")
 	tokenUserCmd.Flags().String("auth-url", c.ClusterURL, "Force the authorization url. The authorization url is the URL that the user will open in the browser, defaults to the cluster url value from config file")
 	tokenUserCmd.Flags().String("token-url", c.ClusterURL, "Force a token url. The token url is used to exchange the auth code, defaults to the cluster url value from config file")
+	tokenUserCmd.Flags().String("auth-style", "header", "Set how client credentials are sent to the token endpoint, one of: header (client_secret_basic), body (client_secret_post), private_key_jwt")
+	tokenUserCmd.Flags().String("client-key", "", "With --auth-style private_key_jwt, sign the client assertion with this PEM encoded private key, for example one printed by \"hydra token gen-key\"")
+	tokenUserCmd.Flags().String("client-key-id", "", "With --auth-style private_key_jwt, the key id of the --client-key as registered in the client's JSON Web Key Set")
+	tokenUserCmd.Flags().Duration("client-assertion-lifetime", time.Minute, "With --auth-style private_key_jwt, the lifetime (exp - iat) of the client assertion")
+	tokenUserCmd.Flags().String("client-assertion-aud", "", "With --auth-style private_key_jwt, the audience of the client assertion, for example the issuer, defaults to the url of the token endpoint")
+	tokenUserCmd.Flags().String("format", "text", "Set the output format, one of: text, json, env, curl, kubectl, nagios. The kubectl format prints an ExecCredential for client-go credential plugins, the nagios format makes the command a monitoring plugin")
+	tokenUserCmd.Flags().StringArray("field", []string{}, "Print only this field of the token instead, one per line or as JSON object with --format json, can be repeated. Dotted paths select claims of the ID token or the access token, for example claims.sub or access_token_claims.scp")
+	tokenUserCmd.Flags().Bool("clipboard", false, "Copy the access token to the clipboard of the system once it was issued, for example to paste it into an API client")
+	tokenUserCmd.Flags().Bool("id-token-only", false, "With --clipboard, copy the ID token instead of the access token")
+	tokenUserCmd.Flags().Bool("stdout-token-only", false, `Print only the access token to stdout and everything else to stderr, for example for TOKEN=$(hydra token user --stdout-token-only)`)
+	tokenUserCmd.Flags().Duration("nagios-warning", 10*time.Minute, "With --format nagios, report WARNING if the access token expires within this duration")
+	tokenUserCmd.Flags().Duration("nagios-critical", time.Minute, "With --format nagios, report CRITICAL if the access token expires within this duration")
+	tokenUserCmd.Flags().String("resource-url", "", "The resource url used in the example request printed by --format curl")
+	tokenUserCmd.Flags().String("out", "", "Write the token as JSON to this file")
+	tokenUserCmd.Flags().Bool("no-cache", false, "Neither reuse nor update the token cache, which stores tokens keyed by client id, scopes and audience to skip the browser flow while the cached token is valid or can be refreshed")
+	tokenUserCmd.Flags().Bool("clear-cache", false, "Remove all tokens from the token cache and exit")
+	tokenUserCmd.Flags().String("token-cache", "", "The token cache file, defaults to hydra/tokens.json in the user cache directory")
+	tokenUserCmd.Flags().Bool("prefer-refresh", false, "Try to refresh the token stored in --out before falling back to the browser flow")
+	tokenUserCmd.Flags().Bool("require-id-token", false, "With --prefer-refresh, run the browser flow if refreshing the stored token did not issue a new ID token")
+	tokenUserCmd.Flags().Bool("verify", false, "Verify the signature and the claims of the ID token after the flow completed, a mismatching at_hash or c_hash claim fails with exit code 7 instead of a warning")
+	tokenUserCmd.Flags().String("assert-scopes", "", "Fail if the granted scopes do not contain the requested scopes, or with --assert-scopes=exact if they are not exactly the requested scopes")
+	tokenUserCmd.Flags().Lookup("assert-scopes").NoOptDefVal = "contains"
+	tokenUserCmd.Flags().Bool("print-client-config", false, "Print the resolved client configuration to stderr before starting the flow, the client secret is redacted")
+	tokenUserCmd.Flags().Bool("expect-consent", false, "Fail if the consent screen was most likely skipped, detected by the callback arriving within --consent-threshold")
+	tokenUserCmd.Flags().Bool("expect-no-consent", false, "Fail if the consent screen was most likely shown, for example to check that consent was pre-granted, detected by the callback taking longer than --consent-threshold")
+	tokenUserCmd.Flags().Duration("consent-threshold", 3*time.Second, "Callbacks arriving faster than this after the authorization url was opened are considered to have skipped consent")
+	tokenUserCmd.Flags().String("bundle-out", "", "Write the token, the decoded ID token claims, the discovery document and the requested client id and scopes to this file, the client secret is never included")
+	tokenUserCmd.Flags().String("audit-log", "", "Append a JSON line with the client id, the requested and granted scopes, the subject and the result of every invocation to this file, tokens are never logged")
+	tokenUserCmd.Flags().String("metrics-file", "", "Write the result of the flow labeled with client_id and scopes to this file in the Prometheus text format")
+	tokenUserCmd.Flags().String("claims-schema", "", "Validate the claims of the ID token against the JSON Schema stored in this file and fail with exit code 7 if they do not conform, for example to check custom claims in CI. The supported keywords are listed by \"hydra token validate --help\"")
+	tokenUserCmd.Flags().Bool("dry-verify", false, "Decode and print the header and claims of the ID token WITHOUT verifying its signature")
+	tokenUserCmd.Flags().StringSlice("audience", []string{}, "Request an access token for these audiences using the audience parameter")
+	tokenUserCmd.Flags().StringSlice("resource", []string{}, "Request an access token for these resources using the resource parameter of RFC 8707")
+	tokenUserCmd.Flags().Bool("jwt-access-token-aud-check", false, "Print the aud claim of JWT access tokens and check that it contains --audience and --resource")
+	tokenUserCmd.Flags().Bool("jwt-access-token-aud-strict", false, "Fail with exit code 7 instead of warning if the check of --jwt-access-token-aud-check fails")
+	tokenUserCmd.Flags().String("probe-resource", "", "Request this url with the access token as bearer token after the flow completed and report the response, fails unless the status is 2xx")
+	tokenUserCmd.Flags().Bool("probe-follow-redirects", false, "Follow redirects of the --probe-resource url instead of reporting the redirect")
+	tokenUserCmd.Flags().String("jwks-url", "", "Force the JSON Web Key Set url used by --verify, defaults to /.well-known/jwks.json of the cluster url value from config file")
+	tokenUserCmd.Flags().String("jwks-file", "", "Verify the ID token with the JSON Web Key Set stored in this file instead of fetching it from --jwks-url, for verification without network access to the cluster")
+	tokenUserCmd.Flags().StringSlice("expected-audience", []string{}, "With --verify, additionally require these audiences in the ID token and in JWT access tokens")
+	tokenUserCmd.Flags().String("prompt", "", `Set the prompt parameter of the authorization url, one or more space separated values of: none, login, consent, select_account, create. Use create to send the user to the registration of the login provider`)
+	tokenUserCmd.Flags().String("id-token-hint", "", "Send this previously issued ID token as id_token_hint, for example with --silent to check that the session is still active")
+	tokenUserCmd.Flags().String("id-token-hint-file", "", "Send the ID token of this token file written by --out as id_token_hint")
+	tokenUserCmd.Flags().Bool("silent", false, "Attempt a silent authentication with prompt=none and report whether it succeeded or the server requires the user to log in")
+	tokenUserCmd.Flags().StringArray("auth-param", []string{}, "Add a key=value parameter to the authorization url, can be repeated, also with the same key. Use --token-param for parameters of the token request")
+	tokenUserCmd.Flags().StringArray("token-param", []string{}, "Add a key=value parameter to the token request which exchanges the code, for example audience=https://api, can be repeated. Unlike --auth-param it does not change the authorization url")
+	tokenUserCmd.Flags().String("id-token-signing-alg", "", "With --verify, require the ID token to be signed using this algorithm, for example RS256 or ES256. Unsigned ID tokens are always rejected")
+	tokenUserCmd.Flags().String("expected-subject", "", "With --verify, require the sub claim of the ID token to be exactly this value, for example the test user logged in with --login-hint")
+	tokenUserCmd.Flags().String("expected-kid", "", "With --verify, require the ID token to be signed using the key with this kid, for example to confirm which key is in use during a key rotation. The kid of the ID token is printed either way")
+	tokenUserCmd.Flags().Int("max-age", -1, "Send this max_age in seconds in the authorization request, 0 forces the user to authenticate again")
+	tokenUserCmd.Flags().Bool("assert-fresh", false, "Fail unless the auth_time of the ID token shows that the user authenticated during this flow, for example to check --max-age 0")
+	tokenUserCmd.Flags().String("login-hint", "", "Send this login_hint in the authorization request, for example the username of a test user")
+	tokenUserCmd.Flags().String("expected-issuer", "", "With --verify, require the iss claim of the ID token to be exactly this value, defaults to the issuer of the discovery document")
+	tokenUserCmd.Flags().Bool("verify-via-introspection", false, "Introspect the access token using the credentials from the config file and fail unless it is active, works for opaque access tokens too")
+	tokenUserCmd.Flags().Bool("userinfo", false, "Request the userinfo endpoint with the access token and print the claims")
+	tokenUserCmd.Flags().String("userinfo-url", "", "With --userinfo, force the userinfo endpoint, defaults to the userinfo_endpoint of the discovery document")
+	tokenUserCmd.Flags().String("accept-language", "", "With --userinfo, send this Accept-Language header to request localized claims using HTTP content negotiation (e.g. \"de-DE, en;q=0.5\")")
+	tokenUserCmd.Flags().String("display", "", "Send this display parameter to test how the login and consent screens render, one of: page, popup, touch, wap")
+	tokenUserCmd.Flags().String("claims-locales", "", "Request claims in these languages, a space-separated list of BCP47 language tags (e.g. \"de-DE en\")")
+	tokenUserCmd.Flags().String("request-object-key", "", "Sign the authorization parameters with this PEM encoded private key and send them as a request object")
+	tokenUserCmd.Flags().String("request-object-kid", "", "The key id of the --request-object-key as registered in the client's JSON Web Key Set")
+	tokenUserCmd.Flags().Bool("keep-server-open", false, "Keep the callback listener running after the first token was issued and print every token acquired by opening the new authorization url printed after each token, until ctrl + c is pressed. Every flow uses its own state and nonce")
+	tokenUserCmd.Flags().String("callback-response", "html", "Set what the callback returns to the browser, one of: html, json. Use json for headless browsers which parse the callback response")
+	tokenUserCmd.Flags().Bool("skip-preflight", false, "Do not request the authorization url before opening it in the browser, which detects errors such as an unregistered redirect url that the server can not redirect back")
+	tokenUserCmd.Flags().Bool("par", false, "Push the authorization parameters to the pushed_authorization_request_endpoint advertised by OpenID Connect Discovery and only send the request_uri to the browser, the client authenticates as set by --auth-style")
 }
@@ -0,0 +1,157 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestAuthorizeResponseParams(t *testing.T) {
+	query, err := authorizeResponseParams("http://localhost:4445/callback?code=abc&state=xyz", "code")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", query.Get("code"))
+
+	query, err = authorizeResponseParams("http://localhost:4445/callback#code=abc&state=xyz", "code")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", query.Get("code"))
+
+	query, err = authorizeResponseParams("http://localhost:4445/callback?error=access_denied&state=xyz", "code")
+	require.NoError(t, err)
+	assert.Equal(t, "access_denied", query.Get("error"))
+
+	_, err = authorizeResponseParams("http://localhost:4445/callback?state=xyz", "code")
+	assert.Error(t, err)
+
+	query, err = authorizeResponseParams("http://localhost:4445/callback?state=xyz&session_state=abc", "none")
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", query.Get("state"))
+	assert.Empty(t, query.Get("code"))
+
+	query, err = authorizeResponseParams("http://localhost:4445/callback#state=xyz", "none")
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", query.Get("state"))
+
+	_, err = authorizeResponseParams("http://localhost:4445/callback", "none")
+	assert.Error(t, err)
+
+	query, err = fifoResponseParams("state=xyz", "none")
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", query.Get("state"))
+
+	_, err = fifoResponseParams("state=xyz", "code")
+	assert.Error(t, err)
+}
+
+func TestFifoResponseParams(t *testing.T) {
+	for k, tc := range []struct {
+		response     string
+		responseType string
+		expectCode   string
+		expectError  string
+		expectErr    bool
+	}{
+		{response: "code=abc&state=xyz", responseType: "code", expectCode: "abc"},
+		{response: "http://localhost:4445/callback?code=abc&state=xyz", responseType: "code", expectCode: "abc"},
+		{response: "http://localhost:4445/callback#code=abc&state=xyz", responseType: "code", expectCode: "abc"},
+		{response: "error=access_denied&state=xyz", responseType: "code", expectError: "access_denied"},
+		{response: "state=xyz", responseType: "none"},
+		{response: "state=xyz", responseType: "code", expectErr: true},
+		{response: "http://localhost:4445/callback?state=xyz", responseType: "code", expectErr: true},
+		{response: "", responseType: "none", expectErr: true},
+		{response: "code=%zz", responseType: "code", expectErr: true},
+	} {
+		query, err := fifoResponseParams(tc.response, tc.responseType)
+		if tc.expectErr {
+			assert.Error(t, err, "case %d", k)
+			continue
+		}
+		require.NoError(t, err, "case %d", k)
+		assert.Equal(t, tc.expectCode, query.Get("code"), "case %d", k)
+		assert.Equal(t, tc.expectError, query.Get("error"), "case %d", k)
+	}
+}
+
+func TestCheckBrowserCommand(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		valid   bool
+	}{
+		{command: "", valid: true},
+		{command: "firefox --private-window", valid: true},
+		{command: " "},
+		{command: "\t "},
+	} {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("browser-command", "", "")
+		require.NoError(t, cmd.Flags().Set("browser-command", tc.command))
+		assert.Equal(t, tc.valid, checkBrowserCommand(cmd) == nil, "%q", tc.command)
+	}
+}
+
+func TestWriteCallbackJSON(t *testing.T) {
+	token := (&oauth2.Token{AccessToken: "access-token", TokenType: "bearer"}).WithExtra(map[string]interface{}{"id_token": "id-token"})
+
+	for k, tc := range []struct {
+		result       callbackResult
+		expectStatus int
+		expect       callbackResponseOutput
+	}{
+		{
+			result:       callbackResult{token: token, code: "code", sessionState: "session"},
+			expectStatus: http.StatusOK,
+			expect: callbackResponseOutput{Status: "success", Code: "code", SessionState: "session", Token: &tokenOutput{
+				AccessToken: "access-token", AccessTokenFormat: "opaque", TokenType: "bearer", IDToken: "id-token", SessionState: "session",
+			}},
+		},
+		{
+			// --code-only does not exchange the code.
+			result:       callbackResult{code: "code"},
+			expectStatus: http.StatusOK,
+			expect:       callbackResponseOutput{Status: "success", Code: "code"},
+		},
+		{
+			result:       callbackResult{code: "code", err: errors.New("States do not match")},
+			expectStatus: http.StatusInternalServerError,
+			expect:       callbackResponseOutput{Status: "error", Error: "States do not match"},
+		},
+	} {
+		w := httptest.NewRecorder()
+		writeCallbackJSON(w, tc.result)
+		assert.Equal(t, tc.expectStatus, w.Code, "case %d", k)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"), "case %d", k)
+
+		var out callbackResponseOutput
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out), "case %d", k)
+		if out.Token != nil {
+			out.Token.Expiry = tc.expect.Token.Expiry
+		}
+		assert.Equal(t, tc.expect, out, "case %d", k)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/ory/hydra/pkg"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/oauth2"
+)
+
+// userinfoResponse holds the claims returned by the userinfo endpoint requested by --userinfo.
+type userinfoResponse struct {
+	Claims map[string]interface{}
+
+	// ContentLanguage is the Content-Language header of the response, the language the server says it used.
+	ContentLanguage string
+
+	// Signed is set if the response was a JWT, its signature is not verified.
+	Signed bool
+}
+
+func (u *userinfoResponse) report(w io.Writer) {
+	out, err := json.MarshalIndent(u.Claims, "\t", "\t")
+	pkg.Must(err, "Could not encode userinfo claims: %s", err)
+	label := ""
+	if u.Signed {
+		label = " (signed, UNVERIFIED)"
+	}
+	fmt.Fprintf(w, "Userinfo%s:\n\t%s\n\n", label, out)
+
+	if u.ContentLanguage != "" {
+		fmt.Fprintf(w, "Userinfo Language:\n\t%s\n\n", u.ContentLanguage)
+	}
+	if localized := localizedClaims(u.Claims); len(localized) > 0 {
+		fmt.Fprintf(w, "Localized Userinfo Claims:\n\t%s\n\n", strings.Join(localized, "\n\t"))
+	}
+}
+
+// fetchUserinfo requests the userinfo endpoint with the access token as bearer token. acceptLanguage is sent as
+// Accept-Language header if it is not empty, which lets servers localize claims using HTTP content negotiation.
+func fetchUserinfo(ctx context.Context, endpoint, accessToken, acceptLanguage string) (*userinfoResponse, error) {
+	client, _ := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+
+	res, err := ctxhttp.Do(ctx, client, req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not request userinfo endpoint %s", endpoint)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("userinfo endpoint responded with status %s: %s", res.Status, trimProbeBody(string(body)))
+	}
+
+	userinfo := &userinfoResponse{ContentLanguage: res.Header.Get("Content-Language")}
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/jwt") {
+		userinfo.Signed = true
+		if _, userinfo.Claims, err = decodeJWT(strings.TrimSpace(string(body))); err != nil {
+			return nil, errors.Wrap(err, "could not decode the signed userinfo response")
+		}
+		return userinfo, nil
+	}
+
+	if err := json.Unmarshal(body, &userinfo.Claims); err != nil {
+		return nil, errors.Wrap(err, "could not decode the userinfo response")
+	}
+	return userinfo, nil
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchUserinfo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_token"}`))
+			return
+		}
+		if r.Header.Get("Accept-Language") == "de" {
+			w.Header().Set("Content-Language", "de")
+		}
+		w.Write([]byte(`{"sub":"foo","name#de":"Benutzer"}`))
+	}))
+	defer ts.Close()
+
+	userinfo, err := fetchUserinfo(context.Background(), ts.URL, "access", "de")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", userinfo.Claims["sub"])
+	assert.Equal(t, "de", userinfo.ContentLanguage)
+	assert.False(t, userinfo.Signed)
+
+	userinfo, err = fetchUserinfo(context.Background(), ts.URL, "access", "")
+	require.NoError(t, err)
+	assert.Empty(t, userinfo.ContentLanguage)
+
+	_, err = fetchUserinfo(context.Background(), ts.URL, "invalid", "")
+	assert.Error(t, err)
+}
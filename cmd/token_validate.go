@@ -21,17 +21,70 @@
 package cmd
 
 import (
+	"time"
+
+	"github.com/ory/hydra/pkg"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 // validateCmd represents the validate command
 var tokenValidatorCmd = &cobra.Command{
-	Use:   "validate <token>",
+	Use:   "validate [<token>]",
 	Short: "Check if an access token is valid",
-	Run:   cmdHandler.Warden.IsAuthorized,
+	Long: `This command introspects the token using the OAuth 2.0 Token Introspection endpoint (RFC 7662).
+
+Standard introspection only needs the token itself and optionally a token_type_hint. Deployments which gate
+introspection behind additional parameters can be tested by adding them to the request body with
+--introspect-param, for example:
+
+	$ hydra token validate --introspect-param token_type_hint=access_token --introspect-param tenant=a <token>
+
+Instead of passing the token as argument, the access token of a token file written by "hydra token user --out"
+can be introspected with --token-file. A warning is printed if it expired according to the local clock, the token
+is introspected anyway to let the server decide, for example to diagnose clock skew.
+
+The introspection response can be validated against a JSON Schema with --claims-schema, which makes the command
+fail with exit code 7 and list the failed assertions if the claims do not have the expected shape. Supported are the "type",
+"enum", "const", "properties", "required", "additionalProperties", "items", "minItems", "maxItems", "contains",
+"minLength", "maxLength", "pattern", "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "allOf",
+"anyOf", "oneOf" and "not" keywords of JSON Schema draft 7.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if path, _ := cmd.Flags().GetString("token-file"); path != "" && len(args) == 0 {
+			ignoreExpiry, _ := cmd.Flags().GetBool("ignore-expiry")
+			token, err := readStoredAccessToken(path, ignoreExpiry, time.Now())
+			pkg.Must(err, "Could not read the token of --token-file: %s", err)
+			args = []string{token}
+		}
+		cmdHandler.Warden.IsAuthorized(cmd, args)
+	},
+
+	Annotations: map[string]string{watchdogAnnotation: "true"},
+}
+
+// readStoredAccessToken reads the access token of a token file written by "hydra token user --out". Unlike
+// checkStoredTokenExpiry, an access token which expired according to the local clock only causes a warning
+// because introspection tells whether it is still active. ignoreExpiry omits the warning.
+func readStoredAccessToken(path string, ignoreExpiry bool, now time.Time) (string, error) {
+	stored, err := readTokenFile(path)
+	if err != nil {
+		return "", err
+	}
+	if stored.AccessToken == "" {
+		return "", errors.Errorf("token file %s does not contain an access token", path)
+	}
+	if expired := storedTokenExpiry(stored, now); expired != "" && !ignoreExpiry {
+		warn("%s, introspecting it anyway to let the server decide. Use --ignore-expiry to omit this warning.", expired)
+	}
+	return stored.AccessToken, nil
 }
 
 func init() {
 	tokenCmd.AddCommand(tokenValidatorCmd)
 	tokenValidatorCmd.Flags().StringSlice("scopes", []string{""}, "Additionally check if scope was granted")
+	tokenValidatorCmd.Flags().Bool("decode-timestamps", false, "Print exp, iat, nbf and auth_time as RFC3339 dates and the remaining lifetime of the token")
+	tokenValidatorCmd.Flags().String("token-file", "", "Introspect the access token of a token file written by \"hydra token user --out\"")
+	tokenValidatorCmd.Flags().Bool("ignore-expiry", false, "Do not warn if the access token of --token-file expired according to the local clock")
+	tokenValidatorCmd.Flags().StringArray("introspect-param", []string{}, "Add a key=value field to the body of the introspection request, can be repeated")
+	tokenValidatorCmd.Flags().String("claims-schema", "", "Validate the introspection response against the JSON Schema stored in this file and exit with code 7 if it does not conform")
 }
@@ -0,0 +1,435 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ory/hydra/pkg"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/square/go-jose"
+	"golang.org/x/oauth2"
+)
+
+// verificationCheck is the outcome of a single check performed by --verify.
+type verificationCheck struct {
+	name string
+	err  error
+}
+
+// tokenVerification collects the checks performed by --verify.
+type tokenVerification struct {
+	checks []verificationCheck
+}
+
+func (v *tokenVerification) check(name string, err error) {
+	v.checks = append(v.checks, verificationCheck{name: name, err: err})
+}
+
+func (v *tokenVerification) failed() bool {
+	for _, c := range v.checks {
+		if c.err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *tokenVerification) report(w io.Writer) {
+	fmt.Fprintln(w, "Verification:")
+	for _, c := range v.checks {
+		if c.err != nil {
+			fmt.Fprintf(w, "\tFAIL %s: %s\n", c.name, c.err)
+		} else {
+			fmt.Fprintf(w, "\tPASS %s\n", c.name)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// verifyOptions configures verifyToken.
+type verifyOptions struct {
+	JWKsURL           string
+	JWKsFile          string
+	ClientID          string
+	Nonce             string
+	Issuer            string
+	ExpectedAudiences []string
+
+	// Subject is the expected "sub" claim of the ID token, it is not checked if empty.
+	Subject string
+
+	// SigningAlg is the expected "alg" header of the ID token. Unsigned tokens are rejected even if it is empty.
+	SigningAlg string
+
+	// KeyID is the expected "kid" header of the ID token, it is not checked if empty.
+	KeyID string
+}
+
+// verifyToken verifies the signature and the claims of the ID token and checks the audience of JWT access tokens.
+// It returns the verified ID token claims.
+func verifyToken(ctx context.Context, token *oauth2.Token, opts verifyOptions) (*tokenVerification, map[string]interface{}) {
+	v := new(tokenVerification)
+
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		v.check("id_token is present", errors.New("the token response did not contain an ID token"))
+		return v, nil
+	}
+
+	name := "id_token is signed"
+	if opts.SigningAlg != "" {
+		name = fmt.Sprintf("id_token signing algorithm is %s", opts.SigningAlg)
+	}
+	if err := checkSigningAlg(idToken, opts.SigningAlg); err != nil {
+		v.check(name, err)
+		return v, nil
+	}
+	v.check(name, nil)
+	if opts.KeyID != "" {
+		v.check(fmt.Sprintf("id_token key id is %s", opts.KeyID), checkKeyID(idToken, opts.KeyID))
+	}
+
+	jwks := getJWKSClient(opts.JWKsURL)
+	if opts.JWKsFile != "" {
+		jwks = getJWKSFile(opts.JWKsFile)
+	}
+	keys, err := jwks.keySet(ctx)
+	if err != nil {
+		v.check("fetch JSON Web Keys", err)
+		return v, nil
+	}
+
+	claims, err := jwks.verify(ctx, idToken, keys)
+	v.check("id_token signature", err)
+	if err != nil {
+		return v, nil
+	}
+
+	warnReplay("ID token", claims, opts.Nonce)
+	v.check("id_token is not expired", checkExpiry(claims, time.Now()))
+	if opts.Issuer != "" {
+		v.check(fmt.Sprintf("id_token issuer is %s", opts.Issuer), checkClaim(claims, "iss", opts.Issuer))
+	}
+	v.check(fmt.Sprintf("id_token audience contains %s", opts.ClientID), checkAudience(claims, opts.ClientID))
+	if opts.Nonce != "" {
+		v.check("id_token nonce", checkClaim(claims, "nonce", opts.Nonce))
+	}
+	if opts.Subject != "" {
+		v.check(fmt.Sprintf("id_token subject is %s", opts.Subject), checkClaim(claims, "sub", opts.Subject))
+	}
+
+	var accessTokenClaims map[string]interface{}
+	if accessTokenFormat(token.AccessToken) == accessTokenFormatJWT {
+		_, accessTokenClaims, _ = decodeJWT(token.AccessToken)
+		if accessTokenClaims != nil {
+			warnReplay("access token", accessTokenClaims, "")
+		}
+	}
+	for _, aud := range opts.ExpectedAudiences {
+		v.check(fmt.Sprintf("id_token audience contains %s", aud), checkAudience(claims, aud))
+		if accessTokenClaims != nil {
+			v.check(fmt.Sprintf("access_token audience contains %s", aud), checkAudience(accessTokenClaims, aud))
+		}
+	}
+
+	return v, claims
+}
+
+// introspectAccessToken introspects the access token using the introspection client of "hydra token validate" and
+// returns the indented introspection response. It fails unless the token is active, which also covers opaque
+// access tokens whose signature can not be verified locally.
+func introspectAccessToken(cmd *cobra.Command, accessToken string) (string, error) {
+	result, response, err := newClientAPI(cmd).IntrospectOAuth2Token(accessToken, "")
+	if err != nil {
+		return "", errors.Wrap(err, "Could not introspect the access token")
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", errors.Errorf("Could not introspect the access token, expected status code %d but got %d: %s", http.StatusOK, response.StatusCode, response.Payload)
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, response.Payload, "\t", "\t"); err != nil {
+		return "", errors.Wrap(err, "Could not decode the introspection response")
+	}
+	if !result.Active {
+		return out.String(), errors.New("The introspection endpoint reports the access token as inactive")
+	}
+	return out.String(), nil
+}
+
+// verifyJWTSignature verifies the signature of a compact serialized JWT using the key referenced by the "kid"
+// header, or every key in the set if the token has no "kid". Keys with an "alg" other than the one of the token
+// are skipped.
+func verifyJWTSignature(token string, keys *jose.JSONWebKeySet) (map[string]interface{}, error) {
+	sig, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(sig.Signatures) != 1 {
+		return nil, errors.Errorf("expected exactly one signature but got %d", len(sig.Signatures))
+	}
+
+	candidates := keys.Keys
+	kid := sig.Signatures[0].Header.KeyID
+	if kid != "" {
+		candidates = keys.Key(kid)
+		if len(candidates) == 0 {
+			return nil, &unknownKeyIDError{kid: kid}
+		}
+	}
+
+	alg, matched := sig.Signatures[0].Header.Algorithm, false
+	for _, key := range candidates {
+		if key.Algorithm != "" && key.Algorithm != alg {
+			continue
+		}
+		matched = true
+
+		payload, err := sig.Verify(&key)
+		if err != nil {
+			continue
+		}
+
+		var claims map[string]interface{}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return claims, nil
+	}
+	if !matched {
+		if kid != "" {
+			return nil, errors.Errorf("the JSON Web Key with kid %s can not be used with alg %s", kid, alg)
+		}
+		return nil, errors.Errorf("none of the JSON Web Keys can be used with alg %s", alg)
+	}
+	return nil, errors.New("the signature could not be verified with any of the JSON Web Keys")
+}
+
+// checkSigningAlg checks the "alg" header of a JWT. Tokens using "none" are always rejected because anyone can
+// create them, which is what downgrade attacks rely on.
+func checkSigningAlg(token, expected string) error {
+	header, _, err := decodeJWT(token)
+	if err != nil {
+		return err
+	}
+
+	alg, _ := header["alg"].(string)
+	if alg == "" || strings.EqualFold(alg, "none") {
+		return errors.Errorf(`the token is not signed, its alg header is "%s"`, alg)
+	}
+	if expected != "" && alg != expected {
+		return errors.Errorf("expected the token to be signed using %s but it is signed using %s", expected, alg)
+	}
+	return nil
+}
+
+// tokenKeyID returns the "kid" header of a JWT, or an empty string if it has none.
+func tokenKeyID(token string) string {
+	header, _, err := decodeJWT(token)
+	if err != nil {
+		return ""
+	}
+	kid, _ := header["kid"].(string)
+	return kid
+}
+
+// checkKeyID checks the "kid" header of a JWT, for example to confirm which of several active signing keys is in
+// use during a key rotation.
+func checkKeyID(token, expected string) error {
+	if kid := tokenKeyID(token); kid != expected {
+		if kid == "" {
+			return errors.Errorf("expected the token to be signed using the key %s but it has no kid header", expected)
+		}
+		return errors.Errorf("expected the token to be signed using the key %s but it is signed using the key %s", expected, kid)
+	}
+	return nil
+}
+
+func checkExpiry(claims map[string]interface{}, now time.Time) error {
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return errors.New("claim exp is missing")
+	}
+	if expiry := time.Unix(exp, 0); now.After(expiry) {
+		return errors.Errorf("token expired at %s", expiry.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// freshLoginSkew is the clock skew tolerated by checkFreshLogin, auth_time only has a precision of seconds.
+const freshLoginSkew = 5 * time.Second
+
+// checkFreshLogin checks that the auth_time claim of the ID token is not before notBefore. The ID token is decoded
+// without verifying its signature.
+func checkFreshLogin(token *oauth2.Token, notBefore time.Time) error {
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return errors.New("the token response did not contain an ID token, make sure to request the \"openid\" scope")
+	}
+	_, claims, err := decodeJWT(idToken)
+	if err != nil {
+		return errors.Wrap(err, "could not decode the ID token")
+	}
+
+	authTime, ok := numericClaim(claims, "auth_time")
+	if !ok {
+		return errors.New("claim auth_time is missing from the ID token")
+	}
+	if at := time.Unix(authTime, 0); at.Before(notBefore) {
+		return errors.Errorf("the user authenticated at %s, before the flow started at %s", at.UTC().Format(time.RFC3339), notBefore.Add(freshLoginSkew).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func checkAudience(claims map[string]interface{}, expected string) error {
+	audiences := stringsClaim(claims, "aud")
+	for _, aud := range audiences {
+		if aud == expected {
+			return nil
+		}
+	}
+	return errors.Errorf("expected audience %s but got %v", expected, audiences)
+}
+
+func checkClaim(claims map[string]interface{}, name, expected string) error {
+	if actual := fmt.Sprintf("%v", claims[name]); actual != expected {
+		return errors.Errorf("expected %s to be %s but got %s", name, expected, actual)
+	}
+	return nil
+}
+
+// checkAccessTokenAudience returns the aud claim of a JWT access token and the expected audiences it lacks.
+// isJWT is false for opaque access tokens, whose audience is unknown.
+func checkAccessTokenAudience(accessToken string, expected []string) (aud []string, missing []string, isJWT bool) {
+	if accessTokenFormat(accessToken) != accessTokenFormatJWT {
+		return nil, nil, false
+	}
+	_, claims, err := decodeJWT(accessToken)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	aud = stringsClaim(claims, "aud")
+	for _, e := range expected {
+		if checkAudience(claims, e) != nil {
+			missing = append(missing, e)
+		}
+	}
+	return aud, missing, true
+}
+
+// numericClaim returns a NumericDate claim such as exp in seconds since the unix epoch.
+func numericClaim(claims map[string]interface{}, name string) (int64, bool) {
+	switch v := claims[name].(type) {
+	case float64:
+		return int64(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// stringsClaim returns a claim which may either be a single string or a list of strings, such as aud.
+func stringsClaim(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// localizedClaims returns the claims carrying a language tag, for example "name#de", as requested by
+// claims_locales.
+func localizedClaims(claims map[string]interface{}) []string {
+	var localized []string
+	for name, value := range claims {
+		if strings.Contains(name, "#") {
+			localized = append(localized, fmt.Sprintf("%s: %v", name, value))
+		}
+	}
+	sort.Strings(localized)
+	return localized
+}
+
+// printUnverifiedIDToken prints the decoded header and claims of the ID token without checking the signature.
+func printUnverifiedIDToken(w io.Writer, token *oauth2.Token) {
+	idt, _ := token.Extra("id_token").(string)
+	if idt == "" {
+		fmt.Fprintln(w, "The token response does not contain an ID token, nothing to decode.")
+		return
+	}
+
+	header, claims, err := decodeJWT(idt)
+	if err != nil {
+		fmt.Fprintf(w, "Could not decode ID token: %s\n", err)
+		return
+	}
+
+	for _, part := range []struct {
+		name  string
+		value map[string]interface{}
+	}{{"Header", header}, {"Claims", claims}} {
+		out, err := json.MarshalIndent(part.value, "\t", "\t")
+		pkg.Must(err, "Could not encode ID token %s: %s", part.name, err)
+		fmt.Fprintf(w, "ID Token %s (UNVERIFIED):\n\t%s\n\n", part.name, out)
+	}
+	fmt.Fprintln(w, "The signature of the ID token was NOT verified, use --verify to validate it.")
+}
+
+// checkIDTokenClaimsSchema validates the claims of the ID token against schema and prints the failed assertions.
+// The signature is not checked here, combine it with --verify for that.
+func checkIDTokenClaimsSchema(w io.Writer, schema *pkg.JSONSchema, token *oauth2.Token) error {
+	idt, _ := token.Extra("id_token").(string)
+	if idt == "" {
+		return errors.New(`The token response does not contain an ID token to validate against --claims-schema, make sure to request the "openid" scope`)
+	}
+	_, claims, err := decodeJWT(idt)
+	if err != nil {
+		return errors.Wrap(err, "Could not decode the ID token")
+	}
+
+	failures := schema.Validate(claims)
+	if len(failures) == 0 {
+		fmt.Fprintf(w, "ID Token Claims Schema:\n\tThe claims conform to the schema.\n\n")
+		return nil
+	}
+	fmt.Fprintf(w, "ID Token Claims Schema:\n\t%s\n\n", strings.Join(failures, "\n\t"))
+	return errors.Errorf("The claims of the ID token do not conform to the schema, %d assertions failed", len(failures))
+}
@@ -23,7 +23,6 @@ package config
 import (
 	"context"
 	"crypto/sha256"
-	"crypto/tls"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -54,10 +53,11 @@ import (
 
 type Config struct {
 	// These are used by client commands
-	ClusterURL            string `mapstructure:"CLUSTER_URL" yaml:"cluster_url"`
-	ClientID              string `mapstructure:"CLIENT_ID" yaml:"client_id,omitempty"`
-	ClientSecret          string `mapstructure:"CLIENT_SECRET" yaml:"client_secret,omitempty"`
-	SignedUpForNewsletter bool   `yaml:"signed_up_for_newsletter,omitempty"`
+	ClusterURL            string   `mapstructure:"CLUSTER_URL" yaml:"cluster_url"`
+	ClientID              string   `mapstructure:"CLIENT_ID" yaml:"client_id,omitempty"`
+	ClientSecret          string   `mapstructure:"CLIENT_SECRET" yaml:"client_secret,omitempty"`
+	DefaultScopes         []string `mapstructure:"DEFAULT_SCOPES" yaml:"default_scopes,omitempty"`
+	SignedUpForNewsletter bool     `yaml:"signed_up_for_newsletter,omitempty"`
 
 	// These are used by the host command
 	BindPort                         int    `mapstructure:"PORT" yaml:"-"`
@@ -323,12 +323,16 @@ func (c *Config) Resolve(join ...string) *url.URL {
 type transporter struct {
 	*http.Transport
 	FakeTLSTermination bool
+	UserAgent          string
 }
 
 func (t *transporter) RoundTrip(req *http.Request) (*http.Response, error) {
 	if t.FakeTLSTermination {
 		req.Header.Set("X-Forwarded-Proto", "https")
 	}
+	if t.UserAgent != "" {
+		req.Header.Set("User-Agent", t.UserAgent)
+	}
 
 	return t.Transport.RoundTrip(req)
 }
@@ -346,25 +350,30 @@ func (c *Config) OAuth2Client(cmd *cobra.Command) *http.Client {
 	}
 
 	fakeTlsTermination, _ := cmd.Flags().GetBool("fake-tls-termination")
+	userAgent, _ := cmd.Flags().GetString("user-agent")
+	skipTLSVerify, _ := cmd.Flags().GetBool("skip-tls-verify")
+	tlsMinVersion, _ := cmd.Flags().GetString("tls-min-version")
+	tlsMaxVersion, _ := cmd.Flags().GetString("tls-max-version")
+	tlsCipherSuites, _ := cmd.Flags().GetStringSlice("tls-cipher-suites")
+	tlsConfig, err := pkg.TLSConfig(skipTLSVerify, tlsMinVersion, tlsMaxVersion, tlsCipherSuites)
+	pkg.Must(err, "Could not configure TLS: %s", err)
+	rawProxyURL, _ := cmd.Flags().GetString("proxy-url")
+	proxyURL, err := pkg.ParseProxyURL(rawProxyURL)
+	pkg.Must(err, "Could not configure the proxy: %s", err)
+
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
 	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
 		Transport: &transporter{
 			FakeTLSTermination: fakeTlsTermination,
-			Transport:          &http.Transport{},
+			UserAgent:          userAgent,
+			Transport:          transport,
 		},
 	})
 
-	if ok, _ := cmd.Flags().GetBool("skip-tls-verify"); ok {
-		// fmt.Println("Warning: Skipping TLS Certificate Verification.")
-		ctx = context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
-			Transport: &transporter{
-				FakeTLSTermination: fakeTlsTermination,
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-				},
-			},
-		})
-	}
-
 	c.oauth2Client = oauthConfig.Client(ctx)
 	if _, err := c.oauth2Client.Get(c.ClusterURL); err != nil {
 		fmt.Printf("Could not authenticate, because: %s\n", err)
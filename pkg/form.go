@@ -0,0 +1,89 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package pkg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseFormParams parses the key=value pairs of repeatable flags such as --token-param or --introspect-param. Keys
+// may be repeated, values may be empty or contain "=".
+func ParseFormParams(pairs []string) (url.Values, error) {
+	values := url.Values{}
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf(`expected a parameter in the form key=value but got "%s"`, pair)
+		}
+		values.Add(parts[0], parts[1])
+	}
+	return values, nil
+}
+
+// RewriteForm lets rewrite change the form of req if it is a form encoded POST request and replaces the body with
+// the rewritten form unless rewrite returns false. Other requests, and bodies which are not a valid form, are left
+// unchanged.
+func RewriteForm(req *http.Request, rewrite func(url.Values) (bool, error)) error {
+	if req.Method != "POST" || req.Body == nil || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if form, err := url.ParseQuery(string(body)); err == nil {
+		changed, err := rewrite(form)
+		if err != nil {
+			return err
+		}
+		if changed {
+			body = []byte(form.Encode())
+		}
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// AddFormParams adds params to the form of req, see RewriteForm.
+func AddFormParams(req *http.Request, params url.Values) error {
+	if len(params) == 0 {
+		return nil
+	}
+	return RewriteForm(req, func(form url.Values) (bool, error) {
+		for key, values := range params {
+			form[key] = append(form[key], values...)
+		}
+		return true, nil
+	})
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package pkg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormParams(t *testing.T) {
+	values, err := ParseFormParams([]string{"audience=https://api", "foo=a=b", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{"audience": {"https://api"}, "foo": {"a=b"}, "empty": {""}}, values)
+
+	_, err = ParseFormParams([]string{"foo"})
+	assert.Error(t, err)
+	_, err = ParseFormParams([]string{"=bar"})
+	assert.Error(t, err)
+}
+
+func TestAddFormParams(t *testing.T) {
+	params := url.Values{"tenant": {"a"}}
+
+	for k, tc := range []struct {
+		method      string
+		contentType string
+		body        string
+		expect      string
+	}{
+		{method: "POST", contentType: "application/x-www-form-urlencoded", body: "token=foo", expect: "tenant=a&token=foo"},
+		{method: "POST", contentType: "application/json", body: `{"token":"foo"}`, expect: `{"token":"foo"}`},
+		{method: "PUT", contentType: "application/x-www-form-urlencoded", body: "token=foo", expect: "token=foo"},
+	} {
+		req, err := http.NewRequest(tc.method, "http://hydra/oauth2/introspect", strings.NewReader(tc.body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", tc.contentType)
+
+		require.NoError(t, AddFormParams(req, params), "case %d", k)
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expect, string(body), "case %d", k)
+		assert.EqualValues(t, len(body), req.ContentLength, "case %d", k)
+	}
+}
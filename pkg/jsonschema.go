@@ -0,0 +1,366 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// JSONSchema is a JSON Schema used to check the shape of token claims. Only a subset of draft 7 is implemented,
+// schemas using other assertion keywords such as "$ref" or "format" are rejected by ParseJSONSchema instead of
+// silently passing.
+type JSONSchema struct {
+	schema interface{}
+}
+
+// jsonSchemaKeywords are the supported keywords, annotations such as "title" are accepted and ignored.
+var jsonSchemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true, "contains": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true,
+	"$schema": false, "$id": false, "$comment": false, "title": false, "description": false, "default": false, "examples": false,
+}
+
+// ReadJSONSchema reads and parses the JSON Schema stored in path.
+func ReadJSONSchema(path string) (*JSONSchema, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s, err := ParseJSONSchema(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid JSON Schema %s", path)
+	}
+	return s, nil
+}
+
+// ParseJSONSchema parses a JSON Schema and checks that it only uses supported keywords.
+func ParseJSONSchema(raw []byte) (*JSONSchema, error) {
+	var schema interface{}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := checkJSONSchema(schema, "#"); err != nil {
+		return nil, err
+	}
+	return &JSONSchema{schema: schema}, nil
+}
+
+func checkJSONSchema(schema interface{}, path string) error {
+	if _, ok := schema.(bool); ok {
+		return nil
+	}
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		return errors.Errorf("%s: a schema must be an object or a boolean", path)
+	}
+
+	for keyword, value := range s {
+		if _, ok := jsonSchemaKeywords[keyword]; !ok {
+			return errors.Errorf(`%s: the keyword "%s" is not supported`, path, keyword)
+		}
+
+		switch keyword {
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				return errors.Errorf("%s/properties: must be an object", path)
+			}
+			for name, property := range properties {
+				if err := checkJSONSchema(property, path+"/properties/"+name); err != nil {
+					return err
+				}
+			}
+		case "additionalProperties", "items", "contains", "not":
+			if err := checkJSONSchema(value, path+"/"+keyword); err != nil {
+				return err
+			}
+		case "allOf", "anyOf", "oneOf":
+			schemas, ok := value.([]interface{})
+			if !ok || len(schemas) == 0 {
+				return errors.Errorf("%s/%s: must be a non-empty array", path, keyword)
+			}
+			for k, sub := range schemas {
+				if err := checkJSONSchema(sub, fmt.Sprintf("%s/%s/%d", path, keyword, k)); err != nil {
+					return err
+				}
+			}
+		case "required":
+			names, ok := value.([]interface{})
+			if !ok {
+				return errors.Errorf("%s/required: must be an array of strings", path)
+			}
+			for _, name := range names {
+				if _, ok := name.(string); !ok {
+					return errors.Errorf("%s/required: must be an array of strings", path)
+				}
+			}
+		case "type":
+			if _, ok := value.(string); !ok {
+				if _, ok := value.([]interface{}); !ok {
+					return errors.Errorf("%s/type: must be a string or an array of strings", path)
+				}
+			}
+		case "enum":
+			if _, ok := value.([]interface{}); !ok {
+				return errors.Errorf("%s/enum: must be an array", path)
+			}
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				return errors.Errorf("%s/pattern: must be a string", path)
+			}
+			if _, err := regexp.Compile(pattern); err != nil {
+				return errors.Wrapf(err, "%s/pattern", path)
+			}
+		case "minItems", "maxItems", "minLength", "maxLength", "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			if _, ok := value.(float64); !ok {
+				return errors.Errorf("%s/%s: must be a number", path, keyword)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks v, a value decoded by encoding/json, against the schema and returns the assertions which
+// failed. Each failure starts with the JSON Pointer of the offending value, for example
+// `/email: expected type "string" but got "number"`.
+func (s *JSONSchema) Validate(v interface{}) []string {
+	return validateJSONSchema(s.schema, v, "")
+}
+
+func validateJSONSchema(schema interface{}, v interface{}, path string) []string {
+	pointer := path
+	if pointer == "" {
+		pointer = "/"
+	}
+
+	if b, ok := schema.(bool); ok {
+		if !b {
+			return []string{pointer + ": no value is allowed here"}
+		}
+		return nil
+	}
+
+	s := schema.(map[string]interface{})
+	var failures []string
+	fail := func(format string, args ...interface{}) {
+		failures = append(failures, pointer+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t, ok := s["type"]; ok {
+		var types []string
+		if name, ok := t.(string); ok {
+			types = []string{name}
+		} else {
+			for _, name := range t.([]interface{}) {
+				types = append(types, fmt.Sprint(name))
+			}
+		}
+		if !matchesJSONType(v, types) {
+			fail(`expected type "%s" but got "%s"`, strings.Join(types, `" or "`), jsonType(v))
+			// The other assertions of this schema would only repeat the type mismatch.
+			return failures
+		}
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, value := range enum {
+			if reflect.DeepEqual(value, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("%s is not one of the allowed values %s", encodeJSONValue(v), encodeJSONValue(enum))
+		}
+	}
+	if value, ok := s["const"]; ok && !reflect.DeepEqual(value, v) {
+		fail("expected %s but got %s", encodeJSONValue(value), encodeJSONValue(v))
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if required, ok := s["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := value[name.(string)]; !ok {
+					fail(`the required property "%s" is missing`, name)
+				}
+			}
+		}
+
+		properties, _ := s["properties"].(map[string]interface{})
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := properties[name]; ok {
+				failures = append(failures, validateJSONSchema(property, value[name], path+"/"+escapeJSONPointer(name))...)
+			} else if additional, ok := s["additionalProperties"]; ok {
+				if b, ok := additional.(bool); ok && !b {
+					fail(`the property "%s" is not allowed`, name)
+				} else {
+					failures = append(failures, validateJSONSchema(additional, value[name], path+"/"+escapeJSONPointer(name))...)
+				}
+			}
+		}
+	case []interface{}:
+		if min, ok := s["minItems"].(float64); ok && float64(len(value)) < min {
+			fail("expected at least %v items but got %d", min, len(value))
+		}
+		if max, ok := s["maxItems"].(float64); ok && float64(len(value)) > max {
+			fail("expected at most %v items but got %d", max, len(value))
+		}
+		if items, ok := s["items"]; ok {
+			for k, item := range value {
+				failures = append(failures, validateJSONSchema(items, item, fmt.Sprintf("%s/%d", path, k))...)
+			}
+		}
+		if contains, ok := s["contains"]; ok {
+			found := false
+			for _, item := range value {
+				if len(validateJSONSchema(contains, item, "")) == 0 {
+					found = true
+					break
+				}
+			}
+			if !found {
+				fail(`no item matches the "contains" schema`)
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(value))
+		if min, ok := s["minLength"].(float64); ok && length < min {
+			fail("expected at least %v characters but got %v", min, length)
+		}
+		if max, ok := s["maxLength"].(float64); ok && length > max {
+			fail("expected at most %v characters but got %v", max, length)
+		}
+		if pattern, ok := s["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(value) {
+			fail(`"%s" does not match the pattern "%s"`, value, pattern)
+		}
+	case float64:
+		if min, ok := s["minimum"].(float64); ok && value < min {
+			fail("%v is less than the minimum %v", value, min)
+		}
+		if max, ok := s["maximum"].(float64); ok && value > max {
+			fail("%v is greater than the maximum %v", value, max)
+		}
+		if min, ok := s["exclusiveMinimum"].(float64); ok && value <= min {
+			fail("%v is not greater than the exclusive minimum %v", value, min)
+		}
+		if max, ok := s["exclusiveMaximum"].(float64); ok && value >= max {
+			fail("%v is not less than the exclusive maximum %v", value, max)
+		}
+	}
+
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			failures = append(failures, validateJSONSchema(sub, v, path)...)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		if matchingJSONSchemas(anyOf, v) == 0 {
+			fail(`the value does not match any schema of "anyOf"`)
+		}
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		if n := matchingJSONSchemas(oneOf, v); n != 1 {
+			fail(`expected the value to match exactly one schema of "oneOf" but it matches %d`, n)
+		}
+	}
+	if not, ok := s["not"]; ok && len(validateJSONSchema(not, v, path)) == 0 {
+		fail(`the value must not match the "not" schema`)
+	}
+	return failures
+}
+
+func matchingJSONSchemas(schemas []interface{}, v interface{}) int {
+	var n int
+	for _, sub := range schemas {
+		if len(validateJSONSchema(sub, v, "")) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func matchesJSONType(v interface{}, types []string) bool {
+	actual := jsonType(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type of a value decoded by encoding/json, numbers without a fractional part
+// are integers.
+func jsonType(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func encodeJSONValue(v interface{}) string {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(out)
+}
+
+// escapeJSONPointer escapes a property name as a JSON Pointer reference token (RFC 6901).
+func escapeJSONPointer(name string) string {
+	return strings.Replace(strings.Replace(name, "~", "~0", -1), "/", "~1", -1)
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(`{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"required": ["sub", "email", "groups"],
+		"properties": {
+			"sub": {"type": "string", "minLength": 1},
+			"email": {"type": "string", "pattern": "@example\\.com$"},
+			"email_verified": {"const": true},
+			"groups": {"type": "array", "minItems": 1, "items": {"enum": ["admin", "dev"]}},
+			"exp": {"type": "integer", "minimum": 0},
+			"acr": {"oneOf": [{"const": "1"}, {"const": "2"}]}
+		}
+	}`))
+	require.NoError(t, err)
+
+	decode := func(raw string) interface{} {
+		var v interface{}
+		require.NoError(t, json.Unmarshal([]byte(raw), &v))
+		return v
+	}
+
+	assert.Empty(t, schema.Validate(decode(`{"sub": "user", "email": "a@example.com", "email_verified": true, "groups": ["dev"], "exp": 1500000000, "acr": "2", "extra": 1}`)))
+	assert.Equal(t, []string{
+		`/: the required property "groups" is missing`,
+		`/acr: expected the value to match exactly one schema of "oneOf" but it matches 0`,
+		`/email: "a@example.org" does not match the pattern "@example\.com$"`,
+		`/email_verified: expected true but got "true"`,
+		`/exp: expected type "integer" but got "number"`,
+		`/sub: expected type "string" but got "integer"`,
+	}, schema.Validate(decode(`{"sub": 1, "email": "a@example.org", "email_verified": "true", "exp": 1.5, "acr": "3"}`)))
+	assert.Equal(t, []string{`/groups/1: "root" is not one of the allowed values ["admin","dev"]`}, schema.Validate(decode(`{"sub": "user", "email": "a@example.com", "groups": ["dev", "root"]}`)))
+
+	closed, err := ParseJSONSchema([]byte(`{"properties": {"a/b": {"type": "string"}}, "additionalProperties": false}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`/a~1b: expected type "string" but got "boolean"`, `/: the property "c" is not allowed`}, closed.Validate(decode(`{"a/b": true, "c": 1}`)))
+
+	for k, raw := range []string{
+		`[]`,
+		`{"$ref": "#/definitions/claims"}`,
+		`{"properties": {"email": {"format": "email"}}}`,
+		`{"pattern": "("}`,
+		`{"required": [1]}`,
+	} {
+		_, err := ParseJSONSchema([]byte(raw))
+		assert.Error(t, err, "Case %d", k)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ */
+
+package pkg
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConfig(t *testing.T) {
+	config, err := TLSConfig(true, "1.1", "1.2", nil)
+	require.NoError(t, err)
+	assert.True(t, config.InsecureSkipVerify)
+	assert.Equal(t, uint16(tls.VersionTLS11), config.MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MaxVersion)
+
+	config, err = TLSConfig(false, "", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, &tls.Config{}, config)
+
+	config, err = TLSConfig(false, "", "1.2", []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", " TLS_RSA_WITH_AES_128_CBC_SHA"})
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_CBC_SHA}, config.CipherSuites)
+
+	for k, c := range []struct {
+		min, max string
+		suites   []string
+	}{
+		{min: "1.3"},
+		{max: "TLS1.2"},
+		{min: "1.2", max: "1.1"},
+		{suites: []string{"TLS_FOO"}},
+		{suites: []string{"TLS_AES_128_GCM_SHA256"}},
+	} {
+		_, err := TLSConfig(false, c.min, c.max, c.suites)
+		assert.Error(t, err, "Case %d", k)
+	}
+
+	_, err = TLSConfig(false, "", "", []string{"TLS_FOO"})
+	assert.Contains(t, err.Error(), "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+}